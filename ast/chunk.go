@@ -0,0 +1,215 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpCode is an instruction of the VM. Operands follow the opcode in the
+// code of a chunk, constant indices, slots and jump targets take two
+// bytes, big endian, and argument counts one byte.
+type OpCode byte
+
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+
+	OpDefineGlobal
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpFloorDivide
+	OpModulo
+	OpPower
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpIs
+	OpNegate
+	OpNot
+
+	OpJump
+	OpJumpIfFalse
+	OpJumpIfTrue
+	OpJumpIfNotNil
+	OpCall
+	OpReturn
+
+	OpPrint
+	OpEcho
+)
+
+var opNames = [...]string{
+	OpConstant:     "OpConstant",
+	OpNil:          "OpNil",
+	OpTrue:         "OpTrue",
+	OpFalse:        "OpFalse",
+	OpPop:          "OpPop",
+	OpDefineGlobal: "OpDefineGlobal",
+	OpGetGlobal:    "OpGetGlobal",
+	OpSetGlobal:    "OpSetGlobal",
+	OpGetLocal:     "OpGetLocal",
+	OpSetLocal:     "OpSetLocal",
+	OpAdd:          "OpAdd",
+	OpSubtract:     "OpSubtract",
+	OpMultiply:     "OpMultiply",
+	OpDivide:       "OpDivide",
+	OpFloorDivide:  "OpFloorDivide",
+	OpModulo:       "OpModulo",
+	OpPower:        "OpPower",
+	OpEqual:        "OpEqual",
+	OpNotEqual:     "OpNotEqual",
+	OpGreater:      "OpGreater",
+	OpGreaterEqual: "OpGreaterEqual",
+	OpLess:         "OpLess",
+	OpLessEqual:    "OpLessEqual",
+	OpIs:           "OpIs",
+	OpNegate:       "OpNegate",
+	OpNot:          "OpNot",
+	OpJump:         "OpJump",
+	OpJumpIfFalse:  "OpJumpIfFalse",
+	OpJumpIfTrue:   "OpJumpIfTrue",
+	OpJumpIfNotNil: "OpJumpIfNotNil",
+	OpCall:         "OpCall",
+	OpReturn:       "OpReturn",
+	OpPrint:        "OpPrint",
+	OpEcho:         "OpEcho",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+
+	return fmt.Sprintf("OpCode(%d)", byte(op))
+}
+
+var binaryOps = map[TokenType]OpCode{
+	Plus:         OpAdd,
+	Minus:        OpSubtract,
+	Star:         OpMultiply,
+	Slash:        OpDivide,
+	SlashSlash:   OpFloorDivide,
+	Percent:      OpModulo,
+	StarStar:     OpPower,
+	EqualEqual:   OpEqual,
+	NotEqual:     OpNotEqual,
+	Greater:      OpGreater,
+	GreaterEqual: OpGreaterEqual,
+	Less:         OpLess,
+	LessEqual:    OpLessEqual,
+	Is:           OpIs,
+}
+
+// Chunk is a sequence of bytecode with the constants it refers to.
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+	Tokens    []Token // source of each byte of code, locating runtime errors
+}
+
+func (c *Chunk) write(b byte, t Token) {
+	c.Code = append(c.Code, b)
+	c.Tokens = append(c.Tokens, t)
+}
+
+// operand reads the two bytes operand at offset.
+func (c *Chunk) operand(offset int) int {
+	return int(c.Code[offset])<<8 | int(c.Code[offset+1])
+}
+
+// patch overwrites the two bytes operand at offset.
+func (c *Chunk) patch(offset int, operand int) {
+	c.Code[offset] = byte(operand >> 8)
+	c.Code[offset+1] = byte(operand)
+}
+
+// Disassemble lists the instructions of chunk one per line, with their
+// offset and operands, followed by the chunks of the functions it
+// declares.
+func Disassemble(chunk *Chunk) string {
+	var sb strings.Builder
+	var functions []*CompiledFunction
+
+	for offset := 0; offset < len(chunk.Code); {
+		op := OpCode(chunk.Code[offset])
+		line := fmt.Sprintf("%04d %-14s", offset, op)
+
+		switch op {
+		case OpConstant, OpDefineGlobal, OpGetGlobal, OpSetGlobal:
+			{
+				index := chunk.operand(offset + 1)
+				constant := chunk.Constants[index]
+				line += fmt.Sprintf(" %d %s", index, repr(constant))
+
+				if f, ok := constant.(*CompiledFunction); ok {
+					functions = append(functions, f)
+				}
+
+				offset += 3
+			}
+		case OpGetLocal, OpSetLocal:
+			{
+				line += fmt.Sprintf(" %d", chunk.operand(offset+1))
+				offset += 3
+			}
+		case OpJump, OpJumpIfFalse, OpJumpIfTrue, OpJumpIfNotNil:
+			{
+				// targets are absolute
+				line += fmt.Sprintf(" -> %04d", chunk.operand(offset+1))
+				offset += 3
+			}
+		case OpCall:
+			{
+				line += fmt.Sprintf(" %d", chunk.Code[offset+1])
+				offset += 2
+			}
+		default:
+			{
+				offset++
+			}
+		}
+
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	for _, f := range functions {
+		fmt.Fprintf(&sb, "\n%s\n%s", f, Disassemble(f.Chunk))
+	}
+
+	return sb.String()
+}
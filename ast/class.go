@@ -22,19 +22,146 @@
 
 package ast
 
+// ClassValue is the value a class declaration evaluates to, methods are
+// closed over the scope of the declaration.
+type ClassValue struct {
+	Name       string
+	Superclass *ClassValue // nil unless the class inherits
+	Methods    map[string]*Function
+	Statics    map[string]*Function // methods of the class itself
+	Fields     []Declaration        // initialized on every new instance
+	Closure    *Environment         // of the field initializers
+}
+
+// method looks up a method in the class and then in its superclasses.
+func (c *ClassValue) method(name string) (*Function, bool) {
+	for class := c; class != nil; class = class.Superclass {
+		if m, ok := class.Methods[name]; ok {
+			return m, true
+		}
+	}
+
+	return nil, false
+}
+
+// static looks up a static method in the class and then in its
+// superclasses.
+func (c *ClassValue) static(name string) (*Function, bool) {
+	for class := c; class != nil; class = class.Superclass {
+		if m, ok := class.Statics[name]; ok {
+			return m, true
+		}
+	}
+
+	return nil, false
+}
+
+// inherits reports whether the class is other or one of its subclasses.
+func (c *ClassValue) inherits(other *ClassValue) bool {
+	for class := c; class != nil; class = class.Superclass {
+		if class == other {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Arity is the arity of the initializer, if any.
+func (c *ClassValue) Arity() int {
+	if init, ok := c.method("init"); ok {
+		return init.Arity()
+	}
+
+	return 0
+}
+
+// checkArguments checks the number of arguments n of a call of the class
+// against the initializer.
+func (c *ClassValue) checkArguments(paren Token, n int) error {
+	if init, ok := c.method("init"); ok {
+		return init.checkArguments(paren, n)
+	}
+
+	if n != 0 {
+		return errorAt(paren, "expected 0 arguments but got %d", n)
+	}
+
+	return nil
+}
+
+// Call creates an instance and runs the initializer on it.
+func (c *ClassValue) Call(i *Interpreter, arguments []Expr) (Literal, error) {
+	i.stats.allocation()
+	instance := &ClassInstance{c, make(map[string]Literal)}
+	if err := c.initialize(i, instance); err != nil {
+		return Literal{}, err
+	}
+
+	if init, ok := c.method("init"); ok {
+		if _, err := init.bind(instance).Call(i, arguments); err != nil {
+			return Literal{}, err
+		}
+	}
+
+	return Literal{instance}, nil
+}
+
+// initialize sets the fields of a new instance, those of the superclasses
+// first so that subclasses can refer to them.
+func (c *ClassValue) initialize(i *Interpreter, instance *ClassInstance) error {
+	if c.Superclass != nil {
+		if err := c.Superclass.initialize(i, instance); err != nil {
+			return err
+		}
+	}
+
+	if len(c.Fields) == 0 {
+		return nil
+	}
+
+	// this takes the only slot, as for bound methods
+	environment := NewEnvironment(c.Closure)
+	environment.Define(0, Literal{instance})
+
+	enclosing := i.Environment
+	i.Environment = environment
+	defer func() { i.Environment = enclosing }()
+
+	for _, field := range c.Fields {
+		value := Literal{}
+		if field.Expr != nil {
+			l, err := i.Evaluate(field.Expr)
+			if err != nil {
+				return err
+			}
+
+			value = l
+		}
+
+		instance.Set(field.Token, value)
+	}
+
+	return nil
+}
+
+func (c *ClassValue) String() string {
+	return "<class " + c.Name + ">"
+}
+
 type ClassInstance struct {
-	ClassStmt
+	Class  *ClassValue
 	Fields map[string]Literal
 }
 
-func (c ClassInstance) Get(t Token) Literal {
+func (c *ClassInstance) Get(t Token) Literal {
 	return c.Fields[t.Lexeme]
 }
 
-func (c ClassInstance) Set(t Token, l Literal) {
+func (c *ClassInstance) Set(t Token, l Literal) {
 	c.Fields[t.Lexeme] = l
 }
 
-func (c ClassInstance) String() string {
-	return c.Name.Lexeme
+func (c *ClassInstance) String() string {
+	return "<" + c.Class.Name + " instance>"
 }
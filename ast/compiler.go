@@ -0,0 +1,792 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "fmt"
+
+// Compiler lowers a program into bytecode for the VM. Every function is
+// compiled by a compiler of its own, locals live on the stack of the VM
+// in the slots the compiler allocates for them.
+type Compiler struct {
+	// Echo echoes top-level expression statements, like ReplMode does
+	Echo bool
+
+	enclosing *Compiler
+	function  *CompiledFunction
+	names     map[string]int // constant indices of the global names
+	locals    []local
+	depth     int // of the current scope, zero is the global one
+	loops     []*loop
+}
+
+type local struct {
+	name  string
+	depth int
+}
+
+// loop collects the jumps out of a loop body, which are patched once the
+// whole loop is compiled.
+type loop struct {
+	depth     int
+	breaks    []int
+	continues []int
+}
+
+const maxOperand = 1<<16 - 1
+
+// unsupported reports a construct the compiler cannot lower yet.
+func unsupported(construct string) error {
+	return fmt.Errorf("cannot compile %s to bytecode", construct)
+}
+
+// Compile compiles a program into the chunk of a script.
+func (c *Compiler) Compile(stmts []Stmt) (*Chunk, error) {
+	c.function = &CompiledFunction{"", 0, &Chunk{}}
+	c.locals = []local{{"", 0}} // the script takes the first slot
+
+	for _, stmt := range stmts {
+		if e, ok := uncommented(stmt).(ExprStmt); ok && c.Echo {
+			if _, ok := e.Expr.(Assign); !ok {
+				if err := e.Expr.Accept(c); err != nil {
+					return nil, err
+				}
+
+				c.emit(OpEcho, exprToken(e.Expr))
+				continue
+			}
+		}
+
+		if err := stmt.Accept(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.end(); err != nil {
+		return nil, err
+	}
+
+	return c.function.Chunk, nil
+}
+
+// end terminates the code of the function being compiled.
+func (c *Compiler) end() error {
+	c.emit(OpNil, Token{})
+	c.emit(OpReturn, Token{})
+
+	// jump targets must fit in an operand
+	if len(c.chunk().Code) > maxOperand {
+		return unsupported("functions this long")
+	}
+
+	return nil
+}
+
+func (c *Compiler) chunk() *Chunk {
+	return c.function.Chunk
+}
+
+func (c *Compiler) emit(op OpCode, t Token) {
+	c.chunk().write(byte(op), t)
+}
+
+// emitOperand emits an instruction with a two bytes operand.
+func (c *Compiler) emitOperand(op OpCode, operand int, t Token) {
+	c.emit(op, t)
+	c.chunk().write(byte(operand>>8), t)
+	c.chunk().write(byte(operand), t)
+}
+
+// emitJump emits a jump to be patched and returns the offset of its
+// operand.
+func (c *Compiler) emitJump(op OpCode, t Token) int {
+	c.emitOperand(op, 0, t)
+	return len(c.chunk().Code) - 2
+}
+
+// patchJump makes the jump with the operand at offset target the next
+// instruction emitted.
+func (c *Compiler) patchJump(offset int) {
+	c.chunk().patch(offset, len(c.chunk().Code))
+}
+
+func (c *Compiler) constant(value interface{}, t Token) error {
+	chunk := c.chunk()
+	if len(chunk.Constants) > maxOperand {
+		return unsupported("functions with this many constants")
+	}
+
+	chunk.Constants = append(chunk.Constants, value)
+	c.emitOperand(OpConstant, len(chunk.Constants)-1, t)
+
+	return nil
+}
+
+// name returns the index of the constant holding the name of a global.
+func (c *Compiler) name(t Token) (int, error) {
+	if index, ok := c.names[t.Lexeme]; ok {
+		return index, nil
+	}
+
+	chunk := c.chunk()
+	if len(chunk.Constants) > maxOperand {
+		return 0, unsupported("functions with this many constants")
+	}
+
+	if c.names == nil {
+		c.names = make(map[string]int)
+	}
+
+	chunk.Constants = append(chunk.Constants, t.Lexeme)
+	c.names[t.Lexeme] = len(chunk.Constants) - 1
+
+	return len(chunk.Constants) - 1, nil
+}
+
+// global emits an instruction operating on the global named by t.
+func (c *Compiler) global(op OpCode, t Token) error {
+	index, err := c.name(t)
+	if err != nil {
+		return err
+	}
+
+	c.emitOperand(op, index, t)
+
+	return nil
+}
+
+// resolve returns the slot of the local variable named by t, or -1 for a
+// global variable.
+func (c *Compiler) resolve(t Token) (int, error) {
+	for j := len(c.locals) - 1; j >= 0; j-- {
+		if c.locals[j].name == t.Lexeme {
+			return j, nil
+		}
+	}
+
+	for e := c.enclosing; e != nil; e = e.enclosing {
+		for _, l := range e.locals {
+			if l.name == t.Lexeme && l.depth > 0 {
+				return 0, unsupported("closures")
+			}
+		}
+	}
+
+	return -1, nil
+}
+
+// define binds the value on top of the stack to the name declared by t.
+func (c *Compiler) define(t Token) error {
+	if c.depth == 0 {
+		return c.global(OpDefineGlobal, t)
+	}
+
+	if len(c.locals) > maxOperand {
+		return unsupported("functions with this many locals")
+	}
+
+	// the value stays on the stack as the local
+	c.locals = append(c.locals, local{t.Lexeme, c.depth})
+
+	return nil
+}
+
+func (c *Compiler) beginScope() {
+	c.depth++
+}
+
+func (c *Compiler) endScope() {
+	c.depth--
+
+	for len(c.locals) > 0 && c.locals[len(c.locals)-1].depth > c.depth {
+		c.emit(OpPop, Token{})
+		c.locals = c.locals[:len(c.locals)-1]
+	}
+}
+
+// discard pops the locals deeper than depth off the stack, leaving them
+// declared, before jumping out of their scope.
+func (c *Compiler) discard(depth int) {
+	for j := len(c.locals) - 1; j >= 0 && c.locals[j].depth > depth; j-- {
+		c.emit(OpPop, Token{})
+	}
+}
+
+// branch compiles the body of an if or of a loop.
+func (c *Compiler) branch(stmt Stmt) error {
+	n := len(c.locals)
+
+	if err := stmt.Accept(c); err != nil {
+		return err
+	}
+
+	// a local declared only on a branch would unbalance the stack
+	if len(c.locals) != n {
+		return unsupported("declarations outside of a block")
+	}
+
+	return nil
+}
+
+// loop compiles the body of a loop jumping back to start, continue jumps
+// to the increment clause, if any. The breaks are left to the caller to
+// patch past the loop exit.
+func (c *Compiler) loop(start int, body Stmt, increment Expr) (*loop, error) {
+	l := &loop{c.depth, nil, nil}
+	c.loops = append(c.loops, l)
+
+	if err := c.branch(body); err != nil {
+		return nil, err
+	}
+
+	c.loops = c.loops[:len(c.loops)-1]
+
+	for _, offset := range l.continues {
+		c.patchJump(offset)
+	}
+
+	if increment != nil {
+		if err := increment.Accept(c); err != nil {
+			return nil, err
+		}
+
+		c.emit(OpPop, Token{})
+	}
+
+	c.emitOperand(OpJump, start, Token{})
+
+	return l, nil
+}
+
+func (c *Compiler) visitAssign(a Assign) error {
+	if err := a.Expr.Accept(c); err != nil {
+		return err
+	}
+
+	slot, err := c.resolve(a.Variable.Token)
+	if err != nil {
+		return err
+	}
+
+	if slot < 0 {
+		return c.global(OpSetGlobal, a.Variable.Token)
+	}
+
+	c.emitOperand(OpSetLocal, slot, a.Variable.Token)
+
+	return nil
+}
+
+func (c *Compiler) visitBinary(b Binary) error {
+	op, ok := binaryOps[b.Operator.TokenType]
+	if !ok {
+		return unsupported("operator " + b.Operator.Lexeme)
+	}
+
+	if err := b.Left.Accept(c); err != nil {
+		return err
+	}
+
+	if err := b.Right.Accept(c); err != nil {
+		return err
+	}
+
+	c.emit(op, b.Operator)
+
+	return nil
+}
+
+func (c *Compiler) visitCall(call Call) error {
+	if len(call.Arguments) > 255 {
+		return unsupported("calls with more than 255 arguments")
+	}
+
+	if err := call.Callee.Accept(c); err != nil {
+		return err
+	}
+
+	for _, argument := range call.Arguments {
+		if err := argument.Accept(c); err != nil {
+			return err
+		}
+	}
+
+	c.emit(OpCall, call.Paren)
+	c.chunk().write(byte(len(call.Arguments)), call.Paren)
+
+	return nil
+}
+
+func (c *Compiler) visitGet(g Get) error {
+	return unsupported("properties")
+}
+
+func (c *Compiler) visitGrouping(g Grouping) error {
+	return g.Expr.Accept(c)
+}
+
+func (c *Compiler) visitIndex(x Index) error {
+	return unsupported("indexing")
+}
+
+func (c *Compiler) visitIndexSet(x IndexSet) error {
+	return unsupported("indexing")
+}
+
+func (c *Compiler) visitLambda(l Lambda) error {
+	return unsupported("lambdas")
+}
+
+func (c *Compiler) visitList(l List) error {
+	return unsupported("lists")
+}
+
+func (c *Compiler) visitLiteral(l Literal) error {
+	switch l.Value {
+	case nil:
+		{
+			c.emit(OpNil, Token{})
+		}
+	case true:
+		{
+			c.emit(OpTrue, Token{})
+		}
+	case false:
+		{
+			c.emit(OpFalse, Token{})
+		}
+	default:
+		{
+			return c.constant(l.Value, Token{})
+		}
+	}
+
+	return nil
+}
+
+// visitLogical leaves the left operand on the stack when it decides the
+// result.
+func (c *Compiler) visitLogical(l Logical) error {
+	if err := l.Left.Accept(c); err != nil {
+		return err
+	}
+
+	op := OpJumpIfFalse
+	if l.Operator.TokenType == Or {
+		op = OpJumpIfTrue
+	} else if l.Operator.TokenType == QuestionQuestion {
+		op = OpJumpIfNotNil
+	}
+
+	end := c.emitJump(op, l.Operator)
+	c.emit(OpPop, Token{})
+
+	if err := l.Right.Accept(c); err != nil {
+		return err
+	}
+
+	c.patchJump(end)
+
+	return nil
+}
+
+func (c *Compiler) visitMap(m Map) error {
+	return unsupported("maps")
+}
+
+func (c *Compiler) visitRecord(r Record) error {
+	return unsupported("records")
+}
+
+func (c *Compiler) visitSet(s Set) error {
+	return unsupported("properties")
+}
+
+func (c *Compiler) visitSuperExpr(s SuperExpr) error {
+	return unsupported("classes")
+}
+
+func (c *Compiler) visitTernary(t Ternary) error {
+	if err := t.Condition.Accept(c); err != nil {
+		return err
+	}
+
+	otherwise := c.emitJump(OpJumpIfFalse, Token{})
+	c.emit(OpPop, Token{})
+
+	if err := t.Then.Accept(c); err != nil {
+		return err
+	}
+
+	end := c.emitJump(OpJump, Token{})
+	c.patchJump(otherwise)
+	c.emit(OpPop, Token{})
+
+	if err := t.Else.Accept(c); err != nil {
+		return err
+	}
+
+	c.patchJump(end)
+
+	return nil
+}
+
+func (c *Compiler) visitThisExpr(t ThisExpr) error {
+	return unsupported("classes")
+}
+
+func (c *Compiler) visitUnary(u Unary) error {
+	if err := u.Right.Accept(c); err != nil {
+		return err
+	}
+
+	switch u.Operator.TokenType {
+	case Not:
+		{
+			c.emit(OpNot, u.Operator)
+		}
+	case Minus:
+		{
+			c.emit(OpNegate, u.Operator)
+		}
+	default:
+		{
+			return unsupported("operator " + u.Operator.Lexeme)
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) visitVariable(v Variable) error {
+	slot, err := c.resolve(v.Token)
+	if err != nil {
+		return err
+	}
+
+	if slot < 0 {
+		return c.global(OpGetGlobal, v.Token)
+	}
+
+	c.emitOperand(OpGetLocal, slot, v.Token)
+
+	return nil
+}
+
+func (c *Compiler) visitBlock(b Block) error {
+	c.beginScope()
+
+	for _, stmt := range b.Stmts {
+		if err := stmt.Accept(c); err != nil {
+			return err
+		}
+	}
+
+	c.endScope()
+
+	return nil
+}
+
+func (c *Compiler) visitBreakStmt(b BreakStmt) error {
+	l := c.loops[len(c.loops)-1]
+	c.discard(l.depth)
+	l.breaks = append(l.breaks, c.emitJump(OpJump, b.Token))
+
+	return nil
+}
+
+func (c *Compiler) visitClassStmt(s ClassStmt) error {
+	return unsupported("classes")
+}
+
+func (c *Compiler) visitEnumStmt(e EnumStmt) error {
+	return unsupported("enums")
+}
+
+func (c *Compiler) visitContinueStmt(s ContinueStmt) error {
+	l := c.loops[len(c.loops)-1]
+	c.discard(l.depth)
+	l.continues = append(l.continues, c.emitJump(OpJump, s.Token))
+
+	return nil
+}
+
+func (c *Compiler) visitDeclaration(d Declaration) error {
+	if d.Expr != nil {
+		if err := d.Expr.Accept(c); err != nil {
+			return err
+		}
+	} else {
+		c.emit(OpNil, d.Token)
+	}
+
+	return c.define(d.Token)
+}
+
+func (c *Compiler) visitExprStmt(e ExprStmt) error {
+	if err := e.Expr.Accept(c); err != nil {
+		return err
+	}
+
+	c.emit(OpPop, Token{})
+
+	return nil
+}
+
+func (c *Compiler) visitForIn(f ForIn) error {
+	return unsupported("for..in loops")
+}
+
+func (c *Compiler) visitForStmt(f ForStmt) error {
+	// the initializer is declared in the enclosing scope
+	if f.Init != nil {
+		if err := f.Init.Accept(c); err != nil {
+			return err
+		}
+	}
+
+	start := len(c.chunk().Code)
+	exit := -1
+
+	if f.Condition != nil {
+		if err := f.Condition.Accept(c); err != nil {
+			return err
+		}
+
+		exit = c.emitJump(OpJumpIfFalse, Token{})
+		c.emit(OpPop, Token{})
+	}
+
+	l, err := c.loop(start, f.Body, f.Increment)
+	if err != nil {
+		return err
+	}
+
+	if exit >= 0 {
+		c.patchJump(exit)
+		c.emit(OpPop, Token{})
+	}
+
+	for _, offset := range l.breaks {
+		c.patchJump(offset)
+	}
+
+	return nil
+}
+
+func (c *Compiler) visitFunction(f Function) error {
+	for _, d := range f.Defaults {
+		if d != nil {
+			return unsupported("default arguments")
+		}
+	}
+
+	if f.Variadic {
+		return unsupported("variadic functions")
+	}
+
+	// a local function is declared before its body, which can refer to it
+	nested := c.depth > 0
+	if nested {
+		if err := c.define(f.Name); err != nil {
+			return err
+		}
+	}
+
+	inner := &Compiler{false, c, &CompiledFunction{f.Name.Lexeme, len(f.Arguments), &Chunk{}}, nil, []local{{"", 1}}, 1, nil}
+
+	// arguments follow the callee on the stack
+	for _, argument := range f.Arguments {
+		inner.locals = append(inner.locals, local{argument.Lexeme, 1})
+	}
+
+	for _, stmt := range f.Body {
+		if err := stmt.Accept(inner); err != nil {
+			return err
+		}
+	}
+
+	if err := inner.end(); err != nil {
+		return err
+	}
+
+	if err := c.constant(inner.function, f.Name); err != nil {
+		return err
+	}
+
+	if nested {
+		return nil
+	}
+
+	return c.define(f.Name)
+}
+
+func (c *Compiler) visitIfStmt(s IfStmt) error {
+	if err := s.Condition.Accept(c); err != nil {
+		return err
+	}
+
+	otherwise := c.emitJump(OpJumpIfFalse, Token{})
+	c.emit(OpPop, Token{})
+
+	if err := c.branch(s.Then); err != nil {
+		return err
+	}
+
+	end := c.emitJump(OpJump, Token{})
+	c.patchJump(otherwise)
+	c.emit(OpPop, Token{})
+
+	if s.Else != nil {
+		if err := c.branch(s.Else); err != nil {
+			return err
+		}
+	}
+
+	c.patchJump(end)
+
+	return nil
+}
+
+// visitImportStmt compiles the imported statements in the global scope.
+func (c *Compiler) visitImportStmt(s ImportStmt) error {
+	for _, stmt := range s.Stmts {
+		if err := stmt.Accept(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) visitLabeled(l Labeled) error {
+	return unsupported("labeled loops")
+}
+
+func (c *Compiler) visitPrintStmt(p PrintStmt) error {
+	if err := p.Expr.Accept(c); err != nil {
+		return err
+	}
+
+	c.emit(OpPrint, p.Token)
+
+	return nil
+}
+
+func (c *Compiler) visitReturnStmt(r ReturnStmt) error {
+	if c.enclosing == nil {
+		return unsupported("return outside of a function")
+	}
+
+	if r.Expr != nil {
+		if err := r.Expr.Accept(c); err != nil {
+			return err
+		}
+	} else {
+		c.emit(OpNil, r.Token)
+	}
+
+	c.emit(OpReturn, Token{})
+
+	return nil
+}
+
+func (c *Compiler) visitSwitchStmt(s SwitchStmt) error {
+	return unsupported("switch statements")
+}
+
+func (c *Compiler) visitThrowStmt(t ThrowStmt) error {
+	return unsupported("exceptions")
+}
+
+func (c *Compiler) visitTryStmt(t TryStmt) error {
+	return unsupported("exceptions")
+}
+
+func (c *Compiler) visitDeferStmt(d DeferStmt) error {
+	return unsupported("defer")
+}
+
+func (c *Compiler) visitDestructuring(d Destructuring) error {
+	return unsupported("destructuring")
+}
+
+// visitDoWhile compiles the body before the condition, continue jumps to
+// the condition.
+func (c *Compiler) visitDoWhile(d DoWhile) error {
+	start := len(c.chunk().Code)
+
+	l := &loop{c.depth, nil, nil}
+	c.loops = append(c.loops, l)
+
+	if err := c.branch(d.Body); err != nil {
+		return err
+	}
+
+	c.loops = c.loops[:len(c.loops)-1]
+
+	for _, offset := range l.continues {
+		c.patchJump(offset)
+	}
+
+	if err := d.Condition.Accept(c); err != nil {
+		return err
+	}
+
+	exit := c.emitJump(OpJumpIfFalse, Token{})
+	c.emit(OpPop, Token{})
+	c.emitOperand(OpJump, start, Token{})
+
+	c.patchJump(exit)
+	c.emit(OpPop, Token{})
+
+	for _, offset := range l.breaks {
+		c.patchJump(offset)
+	}
+
+	return nil
+}
+
+func (c *Compiler) visitWhileStmt(w WhileStmt) error {
+	start := len(c.chunk().Code)
+
+	if err := w.Condition.Accept(c); err != nil {
+		return err
+	}
+
+	exit := c.emitJump(OpJumpIfFalse, Token{})
+	c.emit(OpPop, Token{})
+
+	l, err := c.loop(start, w.Body, nil)
+	if err != nil {
+		return err
+	}
+
+	c.patchJump(exit)
+	c.emit(OpPop, Token{})
+
+	for _, offset := range l.breaks {
+		c.patchJump(offset)
+	}
+
+	return nil
+}
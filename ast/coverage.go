@@ -0,0 +1,331 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// Coverage returns how many times the statements starting at every line
+// ran, lines of statements that never ran are 0. It is nil unless
+// CoverageMode is set.
+func (i *Interpreter) Coverage() map[int]int {
+	if i.coverage == nil {
+		return nil
+	}
+
+	counts := make(map[int]int, len(i.coverage))
+	for line, n := range i.coverage {
+		counts[line] = n
+	}
+
+	return counts
+}
+
+// cover adds the lines of the statements of a program to the coverage,
+// keeping the counts of earlier runs.
+func (i *Interpreter) cover(stmts []Stmt) {
+	if i.coverage == nil {
+		i.coverage = make(map[int]int)
+	}
+
+	c := lines{i.coverage}
+	for _, stmt := range stmts {
+		c.stmt(stmt)
+	}
+}
+
+// lines collects the lines of all the statements of a program, nested
+// ones included, with a count of 0 unless already present.
+type lines struct {
+	counts map[int]int
+}
+
+func (c lines) stmt(stmt Stmt) {
+	if stmt == nil {
+		return
+	}
+
+	if line := stmtLine(stmt); line > 0 {
+		if _, ok := c.counts[line]; !ok {
+			c.counts[line] = 0
+		}
+	}
+
+	_ = stmt.Accept(c)
+}
+
+func (c lines) stmts(stmts []Stmt) {
+	for _, stmt := range stmts {
+		c.stmt(stmt)
+	}
+}
+
+// expr looks for lambdas, whose bodies hold statements.
+func (c lines) expr(expr Expr) {
+	if expr != nil {
+		_ = expr.Accept(c)
+	}
+}
+
+func (c lines) exprs(exprs []Expr) {
+	for _, expr := range exprs {
+		c.expr(expr)
+	}
+}
+
+func (c lines) function(f Function) {
+	c.exprs(f.Defaults)
+	c.stmts(f.Body)
+}
+
+func (c lines) visitAssign(a Assign) error {
+	c.expr(a.Expr)
+	return nil
+}
+
+func (c lines) visitBinary(b Binary) error {
+	c.expr(b.Left)
+	c.expr(b.Right)
+	return nil
+}
+
+func (c lines) visitCall(call Call) error {
+	c.expr(call.Callee)
+	c.exprs(call.Arguments)
+	return nil
+}
+
+func (c lines) visitGet(g Get) error {
+	c.expr(g.Object)
+	return nil
+}
+
+func (c lines) visitGrouping(g Grouping) error {
+	c.expr(g.Expr)
+	return nil
+}
+
+func (c lines) visitIndex(x Index) error {
+	c.expr(x.Object)
+	c.expr(x.Index)
+	return nil
+}
+
+func (c lines) visitIndexSet(x IndexSet) error {
+	c.expr(x.Object)
+	c.expr(x.Index)
+	c.expr(x.Value)
+	return nil
+}
+
+func (c lines) visitLambda(l Lambda) error {
+	c.exprs(l.Defaults)
+	c.stmts(l.Body)
+	return nil
+}
+
+func (c lines) visitList(l List) error {
+	c.exprs(l.Elements)
+	return nil
+}
+
+func (c lines) visitLiteral(l Literal) error {
+	return nil
+}
+
+func (c lines) visitLogical(l Logical) error {
+	c.expr(l.Left)
+	c.expr(l.Right)
+	return nil
+}
+
+func (c lines) visitMap(m Map) error {
+	c.exprs(m.Keys)
+	c.exprs(m.Values)
+	return nil
+}
+
+func (c lines) visitRecord(r Record) error {
+	c.exprs(r.Values)
+	return nil
+}
+
+func (c lines) visitSet(s Set) error {
+	c.expr(s.Object)
+	c.expr(s.Value)
+	return nil
+}
+
+func (c lines) visitSuperExpr(s SuperExpr) error {
+	return nil
+}
+
+func (c lines) visitTernary(t Ternary) error {
+	c.expr(t.Condition)
+	c.expr(t.Then)
+	c.expr(t.Else)
+	return nil
+}
+
+func (c lines) visitThisExpr(t ThisExpr) error {
+	return nil
+}
+
+func (c lines) visitUnary(u Unary) error {
+	c.expr(u.Right)
+	return nil
+}
+
+func (c lines) visitVariable(v Variable) error {
+	return nil
+}
+
+func (c lines) visitBlock(b Block) error {
+	c.stmts(b.Stmts)
+	return nil
+}
+
+func (c lines) visitBreakStmt(b BreakStmt) error {
+	return nil
+}
+
+func (c lines) visitClassStmt(s ClassStmt) error {
+	for _, field := range s.Fields {
+		c.expr(field.Expr)
+	}
+
+	for _, method := range s.Methods {
+		c.function(method)
+	}
+
+	for _, method := range s.Statics {
+		c.function(method)
+	}
+
+	return nil
+}
+
+func (c lines) visitContinueStmt(s ContinueStmt) error {
+	return nil
+}
+
+func (c lines) visitDeclaration(d Declaration) error {
+	c.expr(d.Expr)
+	return nil
+}
+
+func (c lines) visitDeferStmt(d DeferStmt) error {
+	c.stmt(d.Deferred)
+	return nil
+}
+
+func (c lines) visitDestructuring(d Destructuring) error {
+	c.expr(d.Expr)
+	return nil
+}
+
+func (c lines) visitEnumStmt(e EnumStmt) error {
+	return nil
+}
+
+func (c lines) visitExprStmt(e ExprStmt) error {
+	c.expr(e.Expr)
+	return nil
+}
+
+func (c lines) visitForIn(f ForIn) error {
+	c.expr(f.Iterable)
+	c.stmt(f.Body)
+	return nil
+}
+
+// the clauses of a for loop are part of its line
+func (c lines) visitForStmt(f ForStmt) error {
+	c.stmt(f.Body)
+	return nil
+}
+
+func (c lines) visitFunction(f Function) error {
+	c.function(f)
+	return nil
+}
+
+// imported statements are on the lines of another file
+func (c lines) visitImportStmt(s ImportStmt) error {
+	return nil
+}
+
+// visitLabeled skips the line of the loop, the interpreter counts the line
+// of the label instead.
+func (c lines) visitLabeled(l Labeled) error {
+	return l.Loop.Accept(c)
+}
+
+func (c lines) visitIfStmt(s IfStmt) error {
+	c.expr(s.Condition)
+	c.stmt(s.Then)
+	c.stmt(s.Else)
+	return nil
+}
+
+func (c lines) visitPrintStmt(p PrintStmt) error {
+	c.expr(p.Expr)
+	return nil
+}
+
+func (c lines) visitReturnStmt(r ReturnStmt) error {
+	c.expr(r.Expr)
+	return nil
+}
+
+func (c lines) visitSwitchStmt(s SwitchStmt) error {
+	c.expr(s.Discriminant)
+	for _, clause := range s.Cases {
+		c.expr(clause.Value)
+		c.stmt(clause.Body)
+	}
+
+	c.stmt(s.Default)
+
+	return nil
+}
+
+func (c lines) visitThrowStmt(t ThrowStmt) error {
+	c.expr(t.Expr)
+	return nil
+}
+
+func (c lines) visitTryStmt(t TryStmt) error {
+	c.stmt(t.Body)
+	c.stmt(t.Catch)
+	return nil
+}
+
+func (c lines) visitDoWhile(d DoWhile) error {
+	c.stmt(d.Body)
+	c.expr(d.Condition)
+	return nil
+}
+
+func (c lines) visitWhileStmt(w WhileStmt) error {
+	c.expr(w.Condition)
+	c.stmt(w.Body)
+	return nil
+}
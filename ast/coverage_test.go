@@ -0,0 +1,69 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestInterpreter_Coverage(t *testing.T) {
+	source := `var n = 3;
+if (n > 5) {
+  print "big";
+  n = 5;
+} else {
+  print "small";
+}
+var double = fun (x) {
+  return x * 2;
+};
+for (var i = 0; i < 2; i = i + 1)
+  n = double(n);
+fun unused() {
+  print "never";
+}`
+
+	i := &Interpreter{CoverageMode: true}
+	i.SetOutput(&bytes.Buffer{})
+	if err := exec(i, source); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[int]int{1: 1, 2: 1, 3: 0, 4: 0, 6: 1, 8: 1, 9: 2, 11: 1, 12: 2, 13: 1, 14: 0}
+	if got := i.Coverage(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
+
+func TestInterpreter_CoverageDisabled(t *testing.T) {
+	i, err := run("var x = 1;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := i.Coverage(); got != nil {
+		t.Errorf("want no coverage, got %v", got)
+	}
+}
@@ -0,0 +1,256 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// VarInspector looks up the variables visible at a statement by name.
+type VarInspector interface {
+	Lookup(name string) (interface{}, bool)
+}
+
+// DebugHook is called with the line of the statement about to run and
+// the variables in its scope, it can implement breakpoints by blocking.
+type DebugHook func(line int, env VarInspector)
+
+// execute runs a statement, checking the budget of the run, calling the
+// debug hook and counting its line first.
+func (i *Interpreter) execute(stmt Stmt) error {
+	if i.limited() {
+		if err := i.budget(stmt); err != nil {
+			return err
+		}
+	}
+
+	if i.DebugHook != nil || i.coverage != nil {
+		if line := stmtLine(stmt); line > 0 {
+			if i.coverage != nil {
+				i.coverage[line]++
+			}
+
+			if i.DebugHook != nil {
+				i.DebugHook(line, i.Environment)
+			}
+		}
+	}
+
+	return stmt.Accept(i)
+}
+
+// defineLocal defines the local variable at index of the current scope,
+// its name is kept only for the debug hook.
+func (i *Interpreter) defineLocal(index int, name string, value Expr) {
+	i.Environment.Define(index, value)
+
+	if i.DebugHook != nil {
+		i.Environment.name(index, name)
+	}
+}
+
+// stmtLine returns the line a statement starts at, or 0 for blocks and
+// other statements that only group the ones they contain.
+func stmtLine(stmt Stmt) int {
+	return stmtToken(stmt).Line
+}
+
+// stmtToken returns the token a statement starts at, or none for blocks
+// and other statements that only group the ones they contain.
+func stmtToken(stmt Stmt) Token {
+	switch s := stmt.(type) {
+	case Commented:
+		{
+			return stmtToken(s.Stmt)
+		}
+	case ClassStmt:
+		{
+			return s.Name
+		}
+	case EnumStmt:
+		{
+			return s.Name
+		}
+	case Function:
+		{
+			return s.Name
+		}
+	case ExprStmt:
+		{
+			return exprToken(s.Expr)
+		}
+	case BreakStmt:
+		{
+			return s.Token
+		}
+	case ContinueStmt:
+		{
+			return s.Token
+		}
+	case Declaration:
+		{
+			return s.Token
+		}
+	case DeferStmt:
+		{
+			return s.Token
+		}
+	case Destructuring:
+		{
+			return s.Token
+		}
+	case ForIn:
+		{
+			return s.Token
+		}
+	case ForStmt:
+		{
+			return s.Token
+		}
+	case IfStmt:
+		{
+			return s.Token
+		}
+	case ImportStmt:
+		{
+			return s.Token
+		}
+	case Labeled:
+		{
+			return s.Label
+		}
+	case PrintStmt:
+		{
+			return s.Token
+		}
+	case ReturnStmt:
+		{
+			return s.Token
+		}
+	case SwitchStmt:
+		{
+			return s.Token
+		}
+	case ThrowStmt:
+		{
+			return s.Token
+		}
+	case WhileStmt:
+		{
+			return s.Token
+		}
+	case DoWhile:
+		{
+			return s.Token
+		}
+	}
+
+	return Token{}
+}
+
+// exprLine returns the line of the leftmost token of an expression, or 0
+// for literals, which have none.
+func exprLine(expr Expr) int {
+	return exprToken(expr).Line
+}
+
+// exprToken returns the leftmost token of an expression, or none for
+// literals.
+func exprToken(expr Expr) Token {
+	switch e := expr.(type) {
+	case Assign:
+		{
+			return e.Variable.Token
+		}
+	case Binary:
+		{
+			return exprToken(e.Left)
+		}
+	case Call:
+		{
+			return exprToken(e.Callee)
+		}
+	case CommentedExpr:
+		{
+			return exprToken(e.Expr)
+		}
+	case Get:
+		{
+			return exprToken(e.Object)
+		}
+	case Grouping:
+		{
+			return exprToken(e.Expr)
+		}
+	case Index:
+		{
+			return exprToken(e.Object)
+		}
+	case IndexSet:
+		{
+			return exprToken(e.Object)
+		}
+	case Lambda:
+		{
+			return e.Fun
+		}
+	case List:
+		{
+			return e.Bracket
+		}
+	case Logical:
+		{
+			return exprToken(e.Left)
+		}
+	case Map:
+		{
+			return e.Brace
+		}
+	case Record:
+		{
+			return e.Brace
+		}
+	case Set:
+		{
+			return exprToken(e.Object)
+		}
+	case SuperExpr:
+		{
+			return e.Token
+		}
+	case Ternary:
+		{
+			return exprToken(e.Condition)
+		}
+	case ThisExpr:
+		{
+			return e.Token
+		}
+	case Unary:
+		{
+			return e.Operator
+		}
+	case Variable:
+		{
+			return e.Token
+		}
+	}
+
+	return Token{}
+}
@@ -0,0 +1,105 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDebugHook_Lines(t *testing.T) {
+	source := `fun square(x) {
+  return x * x;
+}
+var total = 0;
+for (var i = 1; i <= 2; i = i + 1) {
+  total = total + square(i);
+}
+if (total > 10) {
+  print "big";
+} else print "small";`
+
+	var lines []int
+	i := &Interpreter{}
+	i.SetOutput(&bytes.Buffer{})
+	i.DebugHook = func(line int, env VarInspector) {
+		lines = append(lines, line)
+	}
+
+	if err := exec(i, source); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []int{1, 4, 5, 6, 2, 6, 2, 8, 10}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("want %v, got %v", want, lines)
+	}
+}
+
+func TestDebugHook_Lookup(t *testing.T) {
+	source := `var g = "global";
+fun f(a) {
+  var b = a + 1;
+  {
+    var a = 10;
+    print b;
+  }
+}
+f(1);`
+
+	type probe struct {
+		line int
+		a, b interface{}
+		g    interface{}
+	}
+
+	var probes []probe
+	i := &Interpreter{}
+	i.SetOutput(&bytes.Buffer{})
+	i.DebugHook = func(line int, env VarInspector) {
+		a, _ := env.Lookup("a")
+		b, _ := env.Lookup("b")
+		g, _ := env.Lookup("g")
+		probes = append(probes, probe{line, a, b, g})
+	}
+
+	if err := exec(i, source); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []probe{
+		{1, nil, nil, nil},
+		{2, nil, nil, "global"},
+		{9, nil, nil, "global"},
+		{3, 1.0, nil, "global"},
+		{5, 1.0, 2.0, "global"},
+		{6, 10.0, 2.0, "global"},
+	}
+	if !reflect.DeepEqual(probes, want) {
+		t.Errorf("want %v, got %v", want, probes)
+	}
+
+	if _, ok := i.Environment.Lookup("missing"); ok {
+		t.Error("want missing to be undefined")
+	}
+}
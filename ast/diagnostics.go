@@ -0,0 +1,171 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "sort"
+
+// Severity of a diagnostic, numbered like in the language server protocol.
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "information"
+	case SeverityHint:
+		return "hint"
+	}
+
+	return "unknown"
+}
+
+// Position is a location in the source, lines and columns start at 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Range spans the source from Start to End, which is excluded.
+type Range struct {
+	Start Position
+	End   Position
+}
+
+// Diagnostic is a problem found in a source, for editors to show.
+type Diagnostic struct {
+	Range    Range
+	Message  string
+	Severity Severity
+}
+
+// Diagnostics scans, parses and resolves a source and returns all the
+// problems found in source order, going on after each error as far as
+// possible.
+func Diagnostics(source string) []Diagnostic {
+	errs, warnings := check(source)
+
+	diagnostics := make([]Diagnostic, 0, len(errs))
+	for _, err := range errs {
+		diagnostics = append(diagnostics, diagnostic(err))
+	}
+
+	for _, w := range warnings {
+		start := Position{w.Line, w.Column}
+		diagnostics = append(diagnostics, Diagnostic{Range{start, end(start, w.Token.Lexeme)}, w.Message, SeverityWarning})
+	}
+
+	sort.SliceStable(diagnostics, func(a, b int) bool {
+		s, t := diagnostics[a].Range.Start, diagnostics[b].Range.Start
+		return s.Line < t.Line || s.Line == t.Line && s.Column < t.Column
+	})
+
+	return diagnostics
+}
+
+// Check scans, parses and resolves a source without running it and returns
+// the errors found in source order, nil if there are none. Unlike Run, it
+// has no effect on the outside world but reading the imported files.
+func Check(source string) []error {
+	errs, _ := check(source)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(errs, func(a, b int) bool {
+		s, t := diagnostic(errs[a]).Range.Start, diagnostic(errs[b]).Range.Start
+		return s.Line < t.Line || s.Line == t.Line && s.Column < t.Column
+	})
+
+	return errs
+}
+
+// check returns the errors and the warnings of a source, going on after
+// each error as far as possible.
+func check(source string) (ErrorList, []*Warning) {
+	var errs ErrorList
+
+	scanner := Scanner{source}
+	tokens, _ := scanner.scan(false, &errs)
+
+	parser := Parser{Tokens: tokens}
+	stmts := parser.parse()
+	errs = append(errs, parser.errors...)
+
+	resolver := Resolver{}
+	errs = append(errs, resolver.resolveAll(stmts)...)
+
+	return errs, resolver.Warnings
+}
+
+// diagnostic locates an error, spanning the token it was found at if any.
+func diagnostic(err error) Diagnostic {
+	var start Position
+	var message string
+	var token Token
+
+	switch e := err.(type) {
+	case *ScanError:
+		{
+			start, message = Position{e.Line, e.Column}, e.Message
+		}
+	case *ParseError:
+		{
+			start, message, token = Position{e.Line, e.Column}, e.Message, e.Token
+		}
+	default:
+		{
+			start, message = Position{1, 1}, err.Error()
+		}
+	}
+
+	return Diagnostic{Range{start, end(start, token.Lexeme)}, message, SeverityError}
+}
+
+// end returns the position after text starting at start, a single column
+// for an empty text so that the range is visible.
+func end(start Position, text string) Position {
+	if text == "" {
+		return Position{start.Line, start.Column + 1}
+	}
+
+	position := start
+	for _, r := range text {
+		if r == '\n' {
+			position = Position{position.Line + 1, 1}
+		} else {
+			position.Column++
+		}
+	}
+
+	return position
+}
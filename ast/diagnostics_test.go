@@ -0,0 +1,167 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	source := `var s = "bad \q escape";
+print 1 +;
+var x = 1 # 2;
+break;
+fun f() {
+  return this;
+}
+print "fine";
+continue;`
+
+	want := []Diagnostic{
+		{Range{Position{1, 14}, Position{1, 15}}, "invalid escape sequence '\\q'", SeverityError},
+		{Range{Position{2, 10}, Position{2, 11}}, "unknown token ';'", SeverityError},
+		{Range{Position{3, 11}, Position{3, 12}}, "unknown character '#'", SeverityError},
+		{Range{Position{3, 13}, Position{3, 14}}, "expected 'SEMICOLON'", SeverityError},
+		{Range{Position{4, 1}, Position{4, 6}}, "cannot break outside of a loop", SeverityError},
+		{Range{Position{6, 10}, Position{6, 14}}, "cannot use 'this' outside of a class", SeverityError},
+		{Range{Position{9, 1}, Position{9, 9}}, "cannot continue outside of a loop", SeverityError},
+	}
+
+	got := Diagnostics(source)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want:\n%v\ngot:\n%v", want, got)
+	}
+}
+
+func TestDiagnostics_None(t *testing.T) {
+	if got := Diagnostics("var a = 1;\nprint a;"); len(got) != 0 {
+		t.Errorf("want no diagnostics, got %v", got)
+	}
+}
+
+func TestDiagnostics_Warnings(t *testing.T) {
+	source := "fun f() {\n  var unused = 1;\n  print 1 +;\n}"
+
+	want := []Diagnostic{
+		{Range{Position{2, 7}, Position{2, 13}}, "local variable unused is never read", SeverityWarning},
+		{Range{Position{3, 12}, Position{3, 13}}, "unknown token ';'", SeverityError},
+	}
+
+	got := Diagnostics(source)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want:\n%v\ngot:\n%v", want, got)
+	}
+}
+
+func TestDiagnostics_WarningsBeforeError(t *testing.T) {
+	source := "{ var a = 1; }\nbreak;\n{ var b = 2; }"
+
+	want := []Diagnostic{
+		{Range{Position{1, 7}, Position{1, 8}}, "local variable a is never read", SeverityWarning},
+		{Range{Position{2, 1}, Position{2, 6}}, "cannot break outside of a loop", SeverityError},
+		{Range{Position{3, 7}, Position{3, 8}}, "local variable b is never read", SeverityWarning},
+	}
+
+	got := Diagnostics(source)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want:\n%v\ngot:\n%v", want, got)
+	}
+}
+
+func TestDiagnostics_Range(t *testing.T) {
+	table := []struct {
+		start Position
+		text  string
+		end   Position
+	}{
+		{Position{1, 1}, "", Position{1, 2}},
+		{Position{2, 3}, "while", Position{2, 8}},
+		{Position{1, 5}, "\"two\nlines\"", Position{2, 7}},
+		{Position{1, 1}, "héllo", Position{1, 6}},
+	}
+
+	for _, test := range table {
+		if got := end(test.start, test.text); got != test.end {
+			t.Errorf("%q: want %v, got %v", test.text, test.end, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	source := `print "side effect";
+fun f() {
+  print 1 +;
+}
+clock();
+break;
+var x = 1 # 2;
+return x;`
+
+	want := []string{
+		"error at line 3, col 12: unknown token ';'",
+		"error at line 6, col 1: cannot break outside of a loop",
+		"error at line 7, col 11: unknown character '#'",
+		"error at line 7, col 13: expected 'SEMICOLON'",
+		"error at line 8, col 1: cannot return from top-level code",
+	}
+
+	// nothing runs, so nothing is printed
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+
+	errs := Check(source)
+
+	os.Stdout = stdout
+	w.Close()
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 0 {
+		t.Errorf("want no output, got %q", out)
+	}
+
+	got := make([]string, len(errs))
+	for j, err := range errs {
+		got[j] = err.Error()
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want:\n%v\ngot:\n%v", want, got)
+	}
+}
+
+func TestCheck_None(t *testing.T) {
+	if errs := Check("fun f() { return 1; }\nprint f();"); errs != nil {
+		t.Errorf("want no errors, got %v", errs)
+	}
+}
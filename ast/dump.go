@@ -0,0 +1,465 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dumper renders the AST as an indented tree, one node per line with its
+// type, key tokens and their positions, e.g.
+//
+//	PrintStmt 1:1
+//	  Binary + 1:9
+//	    Literal 1
+//	    Literal 2
+type dumper struct {
+	b     strings.Builder
+	depth int
+	label string // of the next node, the field of its parent holding it
+}
+
+// Dump returns the tree of a program, meant to be read by humans.
+func Dump(stmts []Stmt) string {
+	d := &dumper{}
+	for _, stmt := range stmts {
+		_ = stmt.Accept(d)
+	}
+
+	return d.b.String()
+}
+
+// at is the position of a token as line:column.
+func at(t Token) string {
+	return fmt.Sprintf("%d:%d", t.Line, t.Column)
+}
+
+// node writes a line for a node, parts are its tokens and positions.
+func (d *dumper) node(name string, parts ...string) error {
+	d.b.WriteString(strings.Repeat("  ", d.depth))
+	if d.label != "" {
+		d.b.WriteString(d.label + ": ")
+		d.label = ""
+	}
+
+	d.b.WriteString(strings.Join(append([]string{name}, parts...), " "))
+	d.b.WriteString("\n")
+
+	return nil
+}
+
+// leaf writes a line for a node without children, like node, but one
+// level deeper.
+func (d *dumper) leaf(label string, name string, parts ...string) {
+	d.depth++
+	d.label = label
+	_ = d.node(name, parts...)
+	d.depth--
+}
+
+func (d *dumper) expr(label string, e Expr) {
+	if e == nil {
+		return
+	}
+
+	d.depth++
+	d.label = label
+	_ = e.Accept(d)
+	d.depth--
+}
+
+func (d *dumper) exprs(label string, exprs []Expr) {
+	for _, e := range exprs {
+		d.expr(label, e)
+	}
+}
+
+func (d *dumper) stmt(label string, s Stmt) {
+	if s == nil {
+		return
+	}
+
+	d.depth++
+	d.label = label
+	_ = s.Accept(d)
+	d.depth--
+}
+
+func (d *dumper) stmts(label string, stmts []Stmt) {
+	for _, s := range stmts {
+		d.stmt(label, s)
+	}
+}
+
+// function writes the arguments, defaults and body shared by functions,
+// methods and lambdas.
+func (d *dumper) function(arguments []Token, defaults []Expr, variadic bool, body []Stmt) {
+	for j, argument := range arguments {
+		name := argument.Lexeme
+		if variadic && j == len(arguments)-1 {
+			name = "..." + name
+		}
+
+		d.leaf("argument", name, at(argument))
+		if j < len(defaults) {
+			d.depth++
+			d.expr("default", defaults[j])
+			d.depth--
+		}
+	}
+
+	d.stmts("body", body)
+}
+
+func (d *dumper) visitAssign(a Assign) error {
+	_ = d.node("Assign", a.Variable.Lexeme, at(a.Variable.Token))
+	d.expr("value", a.Expr)
+	return nil
+}
+
+func (d *dumper) visitBinary(b Binary) error {
+	_ = d.node("Binary", b.Operator.Lexeme, at(b.Operator))
+	d.expr("left", b.Left)
+	d.expr("right", b.Right)
+	return nil
+}
+
+func (d *dumper) visitCall(c Call) error {
+	_ = d.node("Call", at(c.Paren))
+	d.expr("callee", c.Callee)
+	d.exprs("argument", c.Arguments)
+	return nil
+}
+
+func (d *dumper) visitGet(g Get) error {
+	name := "Get"
+	if g.Optional {
+		name = "Get?"
+	}
+
+	_ = d.node(name, g.Name.Lexeme, at(g.Name))
+	d.expr("object", g.Object)
+	return nil
+}
+
+func (d *dumper) visitGrouping(g Grouping) error {
+	_ = d.node("Grouping")
+	d.expr("", g.Expr)
+	return nil
+}
+
+func (d *dumper) visitIndex(x Index) error {
+	_ = d.node("Index", at(x.Bracket))
+	d.expr("object", x.Object)
+	d.expr("index", x.Index)
+	return nil
+}
+
+func (d *dumper) visitIndexSet(x IndexSet) error {
+	_ = d.node("IndexSet", strings.TrimSpace(x.Operator.Lexeme+"="), at(x.Bracket))
+	d.expr("object", x.Object)
+	d.expr("index", x.Index)
+	d.expr("value", x.Value)
+	return nil
+}
+
+func (d *dumper) visitLambda(l Lambda) error {
+	_ = d.node("Lambda", at(l.Fun))
+	d.function(l.Arguments, l.Defaults, l.Variadic, l.Body)
+	return nil
+}
+
+func (d *dumper) visitList(l List) error {
+	_ = d.node("List", at(l.Bracket))
+	d.exprs("element", l.Elements)
+	return nil
+}
+
+func (d *dumper) visitLiteral(l Literal) error {
+	switch v := l.Value.(type) {
+	case nil:
+		{
+			return d.node("Literal", "nil")
+		}
+	case string:
+		{
+			return d.node("Literal", strconv.Quote(v))
+		}
+	}
+
+	return d.node("Literal", l.String())
+}
+
+func (d *dumper) visitLogical(l Logical) error {
+	_ = d.node("Logical", l.Operator.Lexeme, at(l.Operator))
+	d.expr("left", l.Left)
+	d.expr("right", l.Right)
+	return nil
+}
+
+func (d *dumper) visitMap(m Map) error {
+	_ = d.node("Map", at(m.Brace))
+	for j := range m.Keys {
+		d.expr("key", m.Keys[j])
+		d.expr("value", m.Values[j])
+	}
+
+	return nil
+}
+
+func (d *dumper) visitRecord(r Record) error {
+	_ = d.node("Record", at(r.Brace))
+	for j, name := range r.Names {
+		d.leaf("field", name.Lexeme, at(name))
+		d.depth++
+		d.expr("value", r.Values[j])
+		d.depth--
+	}
+
+	return nil
+}
+
+func (d *dumper) visitSet(s Set) error {
+	_ = d.node("Set", s.Name.Lexeme, strings.TrimSpace(s.Operator.Lexeme+"="), at(s.Name))
+	d.expr("object", s.Object)
+	d.expr("value", s.Value)
+	return nil
+}
+
+func (d *dumper) visitSuperExpr(s SuperExpr) error {
+	return d.node("Super", s.Method.Lexeme, at(s.Token))
+}
+
+func (d *dumper) visitTernary(t Ternary) error {
+	_ = d.node("Ternary")
+	d.expr("condition", t.Condition)
+	d.expr("then", t.Then)
+	d.expr("else", t.Else)
+	return nil
+}
+
+func (d *dumper) visitThisExpr(t ThisExpr) error {
+	return d.node("This", at(t.Token))
+}
+
+func (d *dumper) visitUnary(u Unary) error {
+	_ = d.node("Unary", u.Operator.Lexeme, at(u.Operator))
+	d.expr("", u.Right)
+	return nil
+}
+
+func (d *dumper) visitVariable(v Variable) error {
+	return d.node("Variable", v.Lexeme, at(v.Token))
+}
+
+func (d *dumper) visitBlock(b Block) error {
+	_ = d.node("Block")
+	d.stmts("", b.Stmts)
+	return nil
+}
+
+func (d *dumper) visitBreakStmt(b BreakStmt) error {
+	if b.Label.Lexeme != "" {
+		return d.node("BreakStmt", b.Label.Lexeme, at(b.Token))
+	}
+
+	return d.node("BreakStmt", at(b.Token))
+}
+
+func (d *dumper) visitClassStmt(c ClassStmt) error {
+	_ = d.node("ClassStmt", c.Name.Lexeme, at(c.Name))
+	d.expr("superclass", c.Superclass)
+
+	for _, field := range c.Fields {
+		d.stmt("field", field)
+	}
+
+	for _, method := range c.Methods {
+		d.stmt("method", method)
+	}
+
+	for _, method := range c.Statics {
+		d.stmt("static", method)
+	}
+
+	return nil
+}
+
+func (d *dumper) visitContinueStmt(c ContinueStmt) error {
+	if c.Label.Lexeme != "" {
+		return d.node("ContinueStmt", c.Label.Lexeme, at(c.Token))
+	}
+
+	return d.node("ContinueStmt", at(c.Token))
+}
+
+func (d *dumper) visitDeclaration(v Declaration) error {
+	name := "Declaration"
+	if v.Const {
+		name = "Declaration const"
+	}
+
+	_ = d.node(name, v.Lexeme, at(v.Token))
+	d.expr("value", v.Expr)
+	return nil
+}
+
+func (d *dumper) visitDeferStmt(v DeferStmt) error {
+	_ = d.node("DeferStmt", at(v.Token))
+	d.stmt("", v.Deferred)
+	return nil
+}
+
+func (d *dumper) visitDestructuring(v Destructuring) error {
+	name := "Destructuring"
+	if v.Const {
+		name = "Destructuring const"
+	}
+
+	_ = d.node(name, at(v.Token))
+	for _, n := range v.Names {
+		d.leaf("name", n.Lexeme, at(n))
+	}
+	d.expr("value", v.Expr)
+	return nil
+}
+
+func (d *dumper) visitEnumStmt(e EnumStmt) error {
+	_ = d.node("EnumStmt", e.Name.Lexeme, at(e.Name))
+	for _, m := range e.Members {
+		d.leaf("member", m.Lexeme, at(m))
+	}
+	return nil
+}
+
+func (d *dumper) visitExprStmt(e ExprStmt) error {
+	_ = d.node("ExprStmt")
+	d.expr("", e.Expr)
+	return nil
+}
+
+func (d *dumper) visitForIn(f ForIn) error {
+	_ = d.node("ForIn", f.Name.Lexeme, at(f.Token))
+	d.expr("iterable", f.Iterable)
+	d.stmt("body", f.Body)
+	return nil
+}
+
+func (d *dumper) visitForStmt(f ForStmt) error {
+	_ = d.node("ForStmt", at(f.Token))
+	d.stmt("init", f.Init)
+	d.expr("condition", f.Condition)
+	d.expr("increment", f.Increment)
+	d.stmt("body", f.Body)
+	return nil
+}
+
+func (d *dumper) visitFunction(f Function) error {
+	name := "Function"
+	if f.Getter {
+		name = "Function getter"
+	}
+
+	_ = d.node(name, f.Name.Lexeme, at(f.Name))
+	d.function(f.Arguments, f.Defaults, f.Variadic, f.Body)
+	return nil
+}
+
+func (d *dumper) visitImportStmt(s ImportStmt) error {
+	return d.node("ImportStmt", strconv.Quote(s.Path), at(s.Token))
+}
+
+func (d *dumper) visitLabeled(l Labeled) error {
+	_ = d.node("Labeled", l.Label.Lexeme, at(l.Label))
+	d.stmt("", l.Loop)
+	return nil
+}
+
+func (d *dumper) visitIfStmt(s IfStmt) error {
+	_ = d.node("IfStmt", at(s.Token))
+	d.expr("condition", s.Condition)
+	d.stmt("then", s.Then)
+	d.stmt("else", s.Else)
+	return nil
+}
+
+func (d *dumper) visitPrintStmt(p PrintStmt) error {
+	_ = d.node("PrintStmt", at(p.Token))
+	d.expr("", p.Expr)
+	return nil
+}
+
+func (d *dumper) visitReturnStmt(r ReturnStmt) error {
+	_ = d.node("ReturnStmt", at(r.Token))
+	d.expr("", r.Expr)
+	return nil
+}
+
+func (d *dumper) visitSwitchStmt(s SwitchStmt) error {
+	_ = d.node("SwitchStmt", at(s.Token))
+	d.expr("discriminant", s.Discriminant)
+
+	for _, clause := range s.Cases {
+		d.leaf("", "CaseClause")
+		d.depth++
+		d.expr("value", clause.Value)
+		d.stmt("body", clause.Body)
+		d.depth--
+	}
+
+	d.stmt("default", s.Default)
+
+	return nil
+}
+
+func (d *dumper) visitThrowStmt(t ThrowStmt) error {
+	_ = d.node("ThrowStmt", at(t.Token))
+	d.expr("", t.Expr)
+	return nil
+}
+
+func (d *dumper) visitTryStmt(t TryStmt) error {
+	_ = d.node("TryStmt")
+	d.stmt("body", t.Body)
+	d.leaf("catch", t.Name.Lexeme, at(t.Name))
+	d.depth++
+	d.stmt("", t.Catch)
+	d.depth--
+	return nil
+}
+
+func (d *dumper) visitDoWhile(w DoWhile) error {
+	_ = d.node("DoWhile", at(w.Token))
+	d.stmt("body", w.Body)
+	d.expr("condition", w.Condition)
+	return nil
+}
+
+func (d *dumper) visitWhileStmt(w WhileStmt) error {
+	_ = d.node("WhileStmt", at(w.Token))
+	d.expr("condition", w.Condition)
+	d.stmt("body", w.Body)
+	return nil
+}
@@ -0,0 +1,68 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// EnumValue is the runtime value of an enum declaration, a read-only
+// object whose members are distinct values, equal only to themselves.
+type EnumValue struct {
+	Name    string
+	members map[string]*EnumMember
+	names   []string
+}
+
+// EnumMember is a member of an enum, compared by identity.
+type EnumMember struct {
+	Enum *EnumValue
+	Name string
+}
+
+func NewEnumValue(name string, members []string) *EnumValue {
+	e := &EnumValue{name, make(map[string]*EnumMember, len(members)), members}
+	for _, m := range members {
+		e.members[m] = &EnumMember{e, m}
+	}
+
+	return e
+}
+
+func (e *EnumValue) Get(t Token) (interface{}, error) {
+	m, ok := e.members[t.Lexeme]
+	if !ok {
+		return nil, errorAt(t, "undefined member %s of enum %s", t.Lexeme, e.Name)
+	}
+
+	return m, nil
+}
+
+// Names returns the names of the members in the order of the declaration.
+func (e *EnumValue) Names() []string {
+	return e.names
+}
+
+func (e *EnumValue) String() string {
+	return "<enum " + e.Name + ">"
+}
+
+func (m *EnumMember) String() string {
+	return m.Enum.Name + "." + m.Name
+}
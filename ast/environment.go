@@ -22,32 +22,65 @@
 
 package ast
 
-import "fmt"
+import "sync"
 
+// Environment holds the variables of a scope. Globals are looked up by
+// name in Scope, locals by the index the resolver gave them in Slots.
+//
+// The interpreter runs on a single goroutine, but natives may hand the
+// environment to others: access by name is guarded, so globals can be read
+// and assigned from any goroutine, while locals belong to the interpreter.
 type Environment struct {
 	Parent *Environment
 	Scope  map[string]interface{}
+	Slots  []interface{}
+	Names  []string // of the slots, recorded only while debugging
+
+	mu sync.RWMutex // guards Scope
+}
+
+// Slot locates a local variable at runtime, Depth environments up from
+// the current one at Index. The parser allocates a slot for every local
+// name and the resolver fills it in, a negative Depth is a global.
+type Slot struct {
+	Depth int
+	Index int
+}
+
+func newSlot() *Slot {
+	return &Slot{-1, 0}
+}
+
+func (s *Slot) local() bool {
+	return s != nil && s.Depth >= 0
 }
 
 func NewEnvironment(parent *Environment) *Environment {
-	return &Environment{parent, make(map[string]interface{})}
+	return &Environment{Parent: parent}
 }
 
 func (e *Environment) Assign(variable Variable, expr Expr) error {
+	e.mu.Lock()
 	if _, ok := e.Scope[variable.Lexeme]; ok {
 		e.Scope[variable.Lexeme] = expr
+		e.mu.Unlock()
 		return nil
 	}
+	e.mu.Unlock()
 
 	if e.Parent != nil {
 		return e.Parent.Assign(variable, expr)
 	}
 
-	return fmt.Errorf("error at line %d: undefined variable %v", variable.Line, variable.Lexeme)
+	return errorAt(variable.Token, "undefined variable %v", variable.Lexeme)
 }
 
-func (e Environment) Contains(variable Variable) bool {
-	if _, ok := e.Scope[variable.Lexeme]; ok {
+func (e *Environment) Contains(variable Variable) bool {
+	e.mu.RLock()
+	_, ok := e.Scope[variable.Lexeme]
+	e.mu.RUnlock()
+
+	if ok {
 		return true
 	}
 
@@ -59,28 +92,123 @@ func (e Environment) Contains(variable Variable) bool {
 }
 
 func (e *Environment) Declare(variable Variable, expr Expr) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.Scope == nil {
+		e.Scope = make(map[string]interface{})
+	}
+
 	e.Scope[variable.Lexeme] = expr
 	return nil
 }
 
-func (e Environment) Get(variable Variable, distance int) (interface{}, error) {
-	local := &e
+func (e *Environment) Get(variable Variable) (interface{}, error) {
+	e.mu.RLock()
+	expr, ok := e.Scope[variable.Lexeme]
+	e.mu.RUnlock()
 
-	for i := 0; i < distance; i++ {
-		local = local.Parent
-	}
-
-	if expr, ok := local.Scope[variable.Lexeme]; ok {
+	if ok {
 		return expr, nil
 	}
 
-	if local.Parent != nil {
-		return local.Parent.Get(variable, 0)
+	if e.Parent != nil {
+		return e.Parent.Get(variable)
 	}
 
-	return nil, fmt.Errorf("error at line %d: undefined variable %v", variable.Line, variable.Lexeme)
+	return nil, errorAt(variable.Token, "undefined variable %v", variable.Lexeme)
 }
 
 func (e *Environment) Set(name string, callable Callable) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.Scope == nil {
+		e.Scope = make(map[string]interface{})
+	}
+
 	e.Scope[name] = Literal{callable}
 }
+
+// Define sets the local variable at index of e.
+func (e *Environment) Define(index int, expr Expr) {
+	for len(e.Slots) <= index {
+		e.Slots = append(e.Slots, nil)
+	}
+
+	e.Slots[index] = expr
+}
+
+// name records the name of the local variable at index of e.
+func (e *Environment) name(index int, name string) {
+	for len(e.Names) <= index {
+		e.Names = append(e.Names, "")
+	}
+
+	e.Names[index] = name
+}
+
+// Lookup returns the value of the innermost variable called name, locals
+// are found only if their names were recorded.
+func (e *Environment) Lookup(name string) (interface{}, bool) {
+	for env := e; env != nil; env = env.Parent {
+		for j, n := range env.Names {
+			if n == name && j < len(env.Slots) && env.Slots[j] != nil {
+				return unwrap(env.Slots[j]), true
+			}
+		}
+
+		env.mu.RLock()
+		v, ok := env.Scope[name]
+		env.mu.RUnlock()
+
+		if ok {
+			return unwrap(v), true
+		}
+	}
+
+	return nil, false
+}
+
+// unwrap unwraps the literals variables are stored as.
+func unwrap(v interface{}) interface{} {
+	if l, ok := v.(Literal); ok {
+		return l.Value
+	}
+
+	return v
+}
+
+func (e *Environment) ancestor(depth int) *Environment {
+	local := e
+	for i := 0; i < depth; i++ {
+		local = local.Parent
+	}
+
+	return local
+}
+
+// GetAt returns the value of a local variable.
+func (e *Environment) GetAt(variable Variable) (interface{}, error) {
+	local := e.ancestor(variable.Slot.Depth)
+
+	// a declaration might have been skipped, like a function in an if
+	if variable.Slot.Index >= len(local.Slots) || local.Slots[variable.Slot.Index] == nil {
+		return nil, errorAt(variable.Token, "undefined variable %v", variable.Lexeme)
+	}
+
+	return local.Slots[variable.Slot.Index], nil
+}
+
+// AssignAt sets the value of a local variable.
+func (e *Environment) AssignAt(variable Variable, expr Expr) error {
+	local := e.ancestor(variable.Slot.Depth)
+
+	if variable.Slot.Index >= len(local.Slots) || local.Slots[variable.Slot.Index] == nil {
+		return errorAt(variable.Token, "undefined variable %v", variable.Lexeme)
+	}
+
+	local.Slots[variable.Slot.Index] = expr
+
+	return nil
+}
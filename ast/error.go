@@ -0,0 +1,158 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorList is a list of errors reported together, like the syntax errors
+// of a program.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	messages := make([]string, len(l))
+	for j, err := range l {
+		messages[j] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// As finds the first error of the list matching target, so that errors.As
+// sees through the list.
+func (l ErrorList) As(target interface{}) bool {
+	for _, err := range l {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScanError is an error scanning the source, like an unterminated string.
+type ScanError struct {
+	Line    int
+	Column  int
+	Message string
+	File    string // set by a #line directive
+}
+
+func (e *ScanError) Error() string {
+	return located(e.File, e.Line, e.Column, e.Message)
+}
+
+func scanError(line int, column int, format string, a ...interface{}) error {
+	return &ScanError{line, column, fmt.Sprintf(format, a...), ""}
+}
+
+// ParseError is an error found before running a program, a syntax error
+// or an error resolving its names, like a break outside of a loop.
+type ParseError struct {
+	Line    int
+	Column  int
+	Message string
+	Token   Token
+}
+
+func (e *ParseError) Error() string {
+	return located(e.Token.File, e.Line, e.Column, e.Message)
+}
+
+// parseError classifies the located errors of the parser and the resolver
+// as parse errors.
+func parseError(err error) error {
+	if e, ok := err.(*Error); ok {
+		return &ParseError{e.Line, e.Column, e.Message, e.Token}
+	}
+
+	return err
+}
+
+// Warning is a problem found before running a program that does not stop
+// it, like a local variable never read.
+type Warning struct {
+	Line    int
+	Column  int
+	Message string
+	Token   Token
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("warning at line %d, col %d: %s", w.Line, w.Column, w.Message)
+}
+
+func warningAt(t Token, format string, a ...interface{}) *Warning {
+	return &Warning{t.Line, t.Column, fmt.Sprintf(format, a...), t}
+}
+
+// Frame is an entry of the call stack, the called function and the line
+// of the call.
+type Frame struct {
+	Name string
+	Line int
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("at %s, line %d", f.Name, f.Line)
+}
+
+// maxTrace is the number of frames shown at each end of a long trace.
+const maxTrace = 10
+
+// RuntimeError is an error raised while running a program, Trace lists
+// the active calls from the innermost to the outermost.
+type RuntimeError struct {
+	Line    int
+	Column  int
+	Message string
+	Token   Token
+	Trace   []Frame
+}
+
+func (e *RuntimeError) Error() string {
+	return located(e.Token.File, e.Line, e.Column, e.Message)
+}
+
+// Stack formats the trace one frame per line, the middle of very deep
+// traces is elided.
+func (e *RuntimeError) Stack() string {
+	var lines []string
+
+	for j, frame := range e.Trace {
+		if j == maxTrace && len(e.Trace) > 2*maxTrace {
+			lines = append(lines, fmt.Sprintf("  ... %d more frames", len(e.Trace)-2*maxTrace))
+		}
+
+		if j >= maxTrace && j < len(e.Trace)-maxTrace {
+			continue
+		}
+
+		lines = append(lines, "  "+frame.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
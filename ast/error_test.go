@@ -0,0 +1,161 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeError_Stack(t *testing.T) {
+	e := &RuntimeError{1, 1, "stack overflow", Token{}, nil}
+	for j := 0; j < 25; j++ {
+		e.Trace = append(e.Trace, Frame{"<fn f>", j})
+	}
+
+	lines := strings.Split(e.Stack(), "\n")
+	if len(lines) != 21 {
+		t.Fatalf("want 21 lines, got %d: %q", len(lines), lines)
+	}
+
+	table := map[int]string{
+		0:  "  at <fn f>, line 0",
+		9:  "  at <fn f>, line 9",
+		10: "  ... 5 more frames",
+		11: "  at <fn f>, line 15",
+		20: "  at <fn f>, line 24",
+	}
+
+	for j, want := range table {
+		if lines[j] != want {
+			t.Errorf("line %d: want %q, got %q", j, want, lines[j])
+		}
+	}
+}
+
+func TestErrors_Kinds(t *testing.T) {
+	table := []struct {
+		in   string
+		kind string
+		err  string
+	}{
+		{"print \"open;", "scan", "error at line 1, col 7: unterminated string"},
+		{"var x = 1 @ 2;", "scan", "error at line 1, col 11: unknown character '@'"},
+		{"print 1 +;", "parse", "error at line 1, col 10: unknown token ';'"},
+		{"var = 1;\nvar = 2;", "parse", "error at line 1, col 5: expected 'IDENTIFIER'\nerror at line 2, col 5: expected 'IDENTIFIER'"},
+		{"break;", "parse", "error at line 1, col 1: cannot break outside of a loop"},
+		{"const c = 1; c = 2;", "parse", "error at line 1, col 14: cannot assign to constant c"},
+		{"print 1 / 0;", "runtime", "error at line 1, col 9: division by zero"},
+		{"print undefined;", "runtime", "error at line 1, col 7: undefined variable undefined"},
+		{"fun f() { return nil + 1; } f();", "runtime", "error at line 1, col 22: invalid operands for binary +: <nil>, float64"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("want %q, got %v", test.err, err)
+			}
+
+			var scan *ScanError
+			var parse *ParseError
+			var runtime *RuntimeError
+
+			kinds := map[string]bool{
+				"scan":    errors.As(err, &scan),
+				"parse":   errors.As(err, &parse),
+				"runtime": errors.As(err, &runtime),
+			}
+
+			for kind, ok := range kinds {
+				if ok != (kind == test.kind) {
+					t.Errorf("%s error: want %t, got %t (%T)", kind, kind == test.kind, ok, err)
+				}
+			}
+		})
+	}
+}
+
+func TestErrors_Token(t *testing.T) {
+	_, err := output("var x = 1;\nprint x +;")
+
+	var parse *ParseError
+	if !errors.As(err, &parse) {
+		t.Fatalf("want ParseError, got %T (%v)", err, err)
+	}
+
+	if parse.Token.TokenType != Semicolon || parse.Line != 2 || parse.Column != 10 {
+		t.Errorf("want ';' at line 2, col 10, got %v at line %d, col %d", parse.Token.TokenType, parse.Line, parse.Column)
+	}
+
+	_, err = output("var list = [1];\nprint list[3];")
+
+	var runtime *RuntimeError
+	if !errors.As(err, &runtime) {
+		t.Fatalf("want RuntimeError, got %T (%v)", err, err)
+	}
+
+	if runtime.Token.TokenType != LeftBracket || runtime.Line != 2 {
+		t.Errorf("want '[' at line 2, got %v at line %d", runtime.Token.TokenType, runtime.Line)
+	}
+}
+
+func TestErrors_LineDirective(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"#line 40 \"shop.dsl\"\nprint 1 / 0;", "error in shop.dsl at line 40, col 9: division by zero"},
+		{"print 1;\n#line 10 \"a.dsl\"\n\nvar x = nil;\nprint x.y;", "error in a.dsl at line 12, col 9: invalid property: y"},
+		{"#line 5 \"a.dsl\"\nprint 1;\n  #line 20\nprint 1 +;", "error in a.dsl at line 20, col 10: unknown token ';'"},
+		{"#line 7 \"a.dsl\"\nprint \"open;", "error in a.dsl at line 7, col 7: unterminated string"},
+		{"#line 7 \"a.dsl\"\nfun f() {\n#line 100 \"b.dsl\"\n  return nil + 1;\n}\nf();", "error in b.dsl at line 100, col 14: invalid operands for binary +: <nil>, float64"},
+		{"var x = 1; #line 3", "error at line 1, col 12: unknown character '#'"},
+		{"#define X", "error at line 1, col 1: unknown directive #define"},
+		{"#line x", "error at line 1, col 7: invalid line number in #line directive"},
+		{"#line 0", "error at line 1, col 7: invalid line number in #line directive"},
+		{"#line 3 \"a.dsl", "error at line 1, col 9: unterminated file name in #line directive"},
+		{"#line 3 \"a.dsl\" x", "error at line 1, col 17: unexpected 'x' after #line directive"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Fatalf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestErrors_LineDirectiveRuns(t *testing.T) {
+	out, err := output("#line 3 \"a.dsl\"\nprint 1;\n#line 1\nprint 2;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out != "1\n2\n" {
+		t.Errorf("want %q, got %q", "1\n2\n", out)
+	}
+}
@@ -25,6 +25,7 @@ package ast
 import (
 	"fmt"
 	"math"
+	"strconv"
 )
 
 type Expr interface {
@@ -37,9 +38,18 @@ type ExprVisitor interface {
 	visitCall(Call) error
 	visitGet(Get) error
 	visitGrouping(Grouping) error
+	visitIndex(Index) error
+	visitIndexSet(IndexSet) error
+	visitLambda(Lambda) error
+	visitList(List) error
 	visitLiteral(Literal) error
 	visitLogical(Logical) error
+	visitMap(Map) error
+	visitRecord(Record) error
 	visitSet(Set) error
+	visitSuperExpr(SuperExpr) error
+	visitTernary(Ternary) error
+	visitThisExpr(ThisExpr) error
 	visitUnary(Unary) error
 	visitVariable(Variable) error
 }
@@ -66,6 +76,7 @@ func (b Binary) Accept(visitor ExprVisitor) error {
 
 type Call struct {
 	Callee    Expr
+	Paren     Token
 	Arguments []Expr
 }
 
@@ -73,9 +84,24 @@ func (c Call) Accept(visitor ExprVisitor) error {
 	return visitor.visitCall(c)
 }
 
+// CommentedExpr attaches to an element of a list, a map, a record or an
+// argument list the comments preceding it and the ones following it on
+// its line, it stands for the element when visited. The parser only
+// produces it from tokens scanned with comments.
+type CommentedExpr struct {
+	Expr
+	Leading  []Token
+	Trailing []Token
+}
+
+func (c CommentedExpr) Accept(visitor ExprVisitor) error {
+	return c.Expr.Accept(visitor)
+}
+
 type Get struct {
-	Name   Token
-	Object Expr
+	Name     Token
+	Object   Expr
+	Optional bool // a?.b is nil when a is, and so is the rest of the chain
 }
 
 func (g Get) Accept(visitor ExprVisitor) error {
@@ -90,20 +116,74 @@ func (g Grouping) Accept(visitor ExprVisitor) error {
 	return visitor.visitGrouping(g)
 }
 
+type Index struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+}
+
+func (i Index) Accept(visitor ExprVisitor) error {
+	return visitor.visitIndex(i)
+}
+
+type IndexSet struct {
+	Object   Expr
+	Bracket  Token
+	Index    Expr
+	Value    Expr
+	Operator Token // binary operator of a compound assignment, if any
+}
+
+func (i IndexSet) Accept(visitor ExprVisitor) error {
+	return visitor.visitIndexSet(i)
+}
+
+type Lambda struct {
+	Fun       Token
+	Arguments []Token
+	Defaults  []Expr
+	Variadic  bool
+	Body      []Stmt
+}
+
+func (l Lambda) Accept(visitor ExprVisitor) error {
+	return visitor.visitLambda(l)
+}
+
+// function returns the anonymous function the lambda evaluates to.
+func (l Lambda) function(closure *Environment) *Function {
+	name := Token{Fun, "", "", l.Fun.Line, l.Fun.Column, l.Fun.File}
+	return &Function{name, closure, l.Arguments, l.Defaults, l.Variadic, false, l.Body, nil}
+}
+
+type List struct {
+	Bracket  Token
+	Elements []Expr
+}
+
+func (l List) Accept(visitor ExprVisitor) error {
+	return visitor.visitList(l)
+}
+
 type Literal struct {
 	Value interface{}
 }
 
+// Bool reports whether the literal is truthy, see truthy.
 func (l Literal) Bool() bool {
-	if b, ok := l.Value.(bool); ok {
+	return truthy(l.Value)
+}
+
+// truthy reports whether a value counts as true where a condition is
+// expected, like in if statements, loops, !, and, or and ?: or natives
+// like filter. Only false and nil are falsy, 0, "" and empty lists are
+// truthy.
+func truthy(v interface{}) bool {
+	if b, ok := v.(bool); ok {
 		return b
-	} else {
-		if l.Value == nil {
-			return false
-		} else {
-			return true
-		}
 	}
+
+	return v != nil
 }
 
 func (l Literal) String() string {
@@ -112,11 +192,13 @@ func (l Literal) String() string {
 	}
 
 	if f, ok := l.Value.(float64); ok {
-		if f == math.Trunc(f) {
-			return fmt.Sprintf("%d", int64(f))
+		// shortest representation that round-trips, in plain decimal
+		// notation unless the magnitude is very large or very small
+		if a := math.Abs(f); a == 0 || (a >= 1e-6 && a < 1e21) {
+			return strconv.FormatFloat(f, 'f', -1, 64)
 		}
 
-		return fmt.Sprintf("%f", f)
+		return strconv.FormatFloat(f, 'g', -1, 64)
 	}
 
 	if b, ok := l.Value.(bool); ok {
@@ -144,16 +226,68 @@ func (l Logical) Accept(visitor ExprVisitor) error {
 	return visitor.visitLogical(l)
 }
 
+type Map struct {
+	Brace  Token
+	Keys   []Expr
+	Values []Expr
+}
+
+func (m Map) Accept(visitor ExprVisitor) error {
+	return visitor.visitMap(m)
+}
+
+// Record is an object literal with named fields, like {x: 1, y: 2}.
+type Record struct {
+	Brace  Token
+	Names  []Token
+	Values []Expr
+}
+
+func (r Record) Accept(visitor ExprVisitor) error {
+	return visitor.visitRecord(r)
+}
+
 type Set struct {
-	Object Expr
-	Name   Token
-	Value  Expr
+	Object   Expr
+	Name     Token
+	Value    Expr
+	Operator Token // binary operator of a compound assignment, if any
 }
 
 func (s Set) Accept(visitor ExprVisitor) error {
 	return visitor.visitSet(s)
 }
 
+type Ternary struct {
+	Condition Expr
+	Then      Expr
+	Else      Expr
+}
+
+func (t Ternary) Accept(visitor ExprVisitor) error {
+	return visitor.visitTernary(t)
+}
+
+type SuperExpr struct {
+	Token
+	Method Token
+	Slot   *Slot // of the superclass
+	This   *Slot // of the instance bound to the method
+}
+
+func (s SuperExpr) Accept(visitor ExprVisitor) error {
+	return visitor.visitSuperExpr(s)
+}
+
+type ThisExpr struct {
+	Token
+	Slot *Slot // of the instance bound to the method
+}
+
+func (t ThisExpr) Accept(visitor ExprVisitor) error {
+	return visitor.visitThisExpr(t)
+}
+
 type Unary struct {
 	Operator Token
 	Right    Expr
@@ -165,6 +299,7 @@ func (u Unary) Accept(visitor ExprVisitor) error {
 
 type Variable struct {
 	Token
+	Slot *Slot
 }
 
 func (v Variable) Accept(visitor ExprVisitor) error {
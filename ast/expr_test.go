@@ -0,0 +1,98 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestLiteral_String(t *testing.T) {
+	table := []struct {
+		in  interface{}
+		out string
+	}{
+		{3.14, "3.14"},
+		{0.5, "0.5"},
+		{100.0, "100"},
+		{0.0, "0"},
+		{-2.5, "-2.5"},
+		{1.0 / 3.0, "0.3333333333333333"},
+		{1e20, "100000000000000000000"},
+		{1e21, "1e+21"},
+		{-1e300, "-1e+300"},
+		{0.000001, "0.000001"},
+		{1e-7, "1e-07"},
+		{math.MaxInt64 * 4.0, "36893488147419103000"},
+		{"text", "text"},
+		{true, "true"},
+		{nil, "nil"},
+	}
+
+	for _, test := range table {
+		t.Run(test.out, func(t *testing.T) {
+			if s := (Literal{test.in}).String(); s != test.out {
+				t.Errorf("want %v, got %v", test.out, s)
+			}
+		})
+	}
+}
+
+func TestLiteral_StringCycles(t *testing.T) {
+	l := &ListValue{[]interface{}{1.0}}
+	l.Elements = append(l.Elements, l, &ListValue{[]interface{}{l}})
+
+	m := NewMapValue()
+	m.Set(MapKey{"self"}, m)
+	m.Set(MapKey{"list"}, l)
+
+	r := NewRecordValue()
+	r.define("self", r)
+
+	// a collection only repeated, not nested in itself, is rendered in full
+	inner := &ListValue{[]interface{}{2.0}}
+	twice := &ListValue{[]interface{}{inner, inner}}
+
+	table := []struct {
+		in  interface{}
+		out string
+	}{
+		{l, "[1, [...], [[...]]]"},
+		{m, `{"self": {...}, "list": [1, [...], [[...]]]}`},
+		{r, "{self: {...}}"},
+		{twice, "[[2], [2]]"},
+	}
+
+	for _, test := range table {
+		t.Run(test.out, func(t *testing.T) {
+			if s := fmt.Sprint(test.in); s != test.out {
+				t.Errorf("want %v, got %v", test.out, s)
+			}
+
+			if s := (Literal{test.in}).String(); s != test.out {
+				t.Errorf("want %v, got %v", test.out, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// Folder is an optimization pass that replaces the unary, binary and
+// grouping expressions of literals by their value, e.g. 2 * 60 * 60 is
+// rewritten as 7200. Expressions that fail at runtime are left as they
+// are, so that the error is still reported when they are evaluated.
+type Folder struct {
+	expr Expr
+	stmt Stmt
+}
+
+// Fold returns the program with its constant expressions folded.
+func Fold(stmts []Stmt) []Stmt {
+	f := Folder{}
+	return f.stmts(stmts)
+}
+
+func (f *Folder) Expr(e Expr) Expr {
+	if e == nil {
+		return nil
+	}
+
+	_ = e.Accept(f)
+	return f.expr
+}
+
+func (f *Folder) Stmt(s Stmt) Stmt {
+	if s == nil {
+		return nil
+	}
+
+	// visiting it would leave the previous statement
+	if commentsOnly(s) {
+		return s
+	}
+
+	_ = s.Accept(f)
+	return f.stmt
+}
+
+func (f *Folder) exprs(exprs []Expr) []Expr {
+	if exprs == nil {
+		return nil
+	}
+
+	folded := make([]Expr, len(exprs))
+	for i, e := range exprs {
+		folded[i] = f.Expr(e)
+	}
+
+	return folded
+}
+
+func (f *Folder) stmts(stmts []Stmt) []Stmt {
+	if stmts == nil {
+		return nil
+	}
+
+	folded := make([]Stmt, len(stmts))
+	for i, s := range stmts {
+		folded[i] = f.Stmt(s)
+	}
+
+	return folded
+}
+
+func (f *Folder) block(b Block) Block {
+	return Block{f.stmts(b.Stmts)}
+}
+
+func (f *Folder) function(fn Function) Function {
+	return Function{fn.Name, fn.Closure, fn.Arguments, f.exprs(fn.Defaults), fn.Variadic, fn.Getter, f.stmts(fn.Body), fn.Slot}
+}
+
+// constant folds e, whose operands are literals, unless evaluating it
+// is an error.
+func (f *Folder) constant(e Expr) error {
+	i := Interpreter{}
+
+	if l, err := i.Evaluate(e); err == nil {
+		f.expr = l
+	} else {
+		f.expr = e
+	}
+
+	return nil
+}
+
+func isLiteral(e Expr) bool {
+	_, ok := e.(Literal)
+	return ok
+}
+
+func (f *Folder) visitAssign(a Assign) error {
+	f.expr = Assign{a.Variable, a.Token, f.Expr(a.Expr)}
+	return nil
+}
+
+func (f *Folder) visitBinary(b Binary) error {
+	folded := Binary{f.Expr(b.Left), b.Operator, f.Expr(b.Right)}
+	if isLiteral(folded.Left) && isLiteral(folded.Right) {
+		return f.constant(folded)
+	}
+
+	f.expr = folded
+	return nil
+}
+
+func (f *Folder) visitCall(c Call) error {
+	f.expr = Call{f.Expr(c.Callee), c.Paren, f.exprs(c.Arguments)}
+	return nil
+}
+
+func (f *Folder) visitGet(g Get) error {
+	f.expr = Get{g.Name, f.Expr(g.Object), g.Optional}
+	return nil
+}
+
+func (f *Folder) visitGrouping(g Grouping) error {
+	folded := Grouping{f.Expr(g.Expr)}
+	if isLiteral(folded.Expr) {
+		f.expr = folded.Expr
+		return nil
+	}
+
+	f.expr = folded
+	return nil
+}
+
+func (f *Folder) visitIndex(i Index) error {
+	f.expr = Index{f.Expr(i.Object), i.Bracket, f.Expr(i.Index)}
+	return nil
+}
+
+func (f *Folder) visitIndexSet(i IndexSet) error {
+	f.expr = IndexSet{f.Expr(i.Object), i.Bracket, f.Expr(i.Index), f.Expr(i.Value), i.Operator}
+	return nil
+}
+
+func (f *Folder) visitLambda(l Lambda) error {
+	f.expr = Lambda{l.Fun, l.Arguments, f.exprs(l.Defaults), l.Variadic, f.stmts(l.Body)}
+	return nil
+}
+
+func (f *Folder) visitList(l List) error {
+	f.expr = List{l.Bracket, f.exprs(l.Elements)}
+	return nil
+}
+
+func (f *Folder) visitLiteral(l Literal) error {
+	f.expr = l
+	return nil
+}
+
+func (f *Folder) visitLogical(l Logical) error {
+	f.expr = Logical{f.Expr(l.Left), l.Operator, f.Expr(l.Right)}
+	return nil
+}
+
+func (f *Folder) visitMap(m Map) error {
+	f.expr = Map{m.Brace, f.exprs(m.Keys), f.exprs(m.Values)}
+	return nil
+}
+
+func (f *Folder) visitRecord(r Record) error {
+	f.expr = Record{r.Brace, r.Names, f.exprs(r.Values)}
+	return nil
+}
+
+func (f *Folder) visitSet(s Set) error {
+	f.expr = Set{f.Expr(s.Object), s.Name, f.Expr(s.Value), s.Operator}
+	return nil
+}
+
+func (f *Folder) visitSuperExpr(s SuperExpr) error {
+	f.expr = s
+	return nil
+}
+
+func (f *Folder) visitTernary(t Ternary) error {
+	f.expr = Ternary{f.Expr(t.Condition), f.Expr(t.Then), f.Expr(t.Else)}
+	return nil
+}
+
+func (f *Folder) visitThisExpr(t ThisExpr) error {
+	f.expr = t
+	return nil
+}
+
+func (f *Folder) visitUnary(u Unary) error {
+	folded := Unary{u.Operator, f.Expr(u.Right)}
+	if isLiteral(folded.Right) {
+		return f.constant(folded)
+	}
+
+	f.expr = folded
+	return nil
+}
+
+func (f *Folder) visitVariable(v Variable) error {
+	f.expr = v
+	return nil
+}
+
+func (f *Folder) visitBlock(b Block) error {
+	f.stmt = f.block(b)
+	return nil
+}
+
+func (f *Folder) visitBreakStmt(b BreakStmt) error {
+	f.stmt = b
+	return nil
+}
+
+func (f *Folder) functions(functions []Function) []Function {
+	folded := make([]Function, len(functions))
+	for i, fn := range functions {
+		folded[i] = f.function(fn)
+	}
+
+	return folded
+}
+
+func (f *Folder) visitClassStmt(c ClassStmt) error {
+	fields := make([]Declaration, len(c.Fields))
+	for i, d := range c.Fields {
+		fields[i] = Declaration{d.Token, f.Expr(d.Expr), d.Const, d.Slot}
+	}
+
+	f.stmt = ClassStmt{c.Name, c.Superclass, f.functions(c.Methods), f.functions(c.Statics), fields, c.Slot}
+	return nil
+}
+
+func (f *Folder) visitEnumStmt(e EnumStmt) error {
+	f.stmt = e
+	return nil
+}
+
+func (f *Folder) visitContinueStmt(c ContinueStmt) error {
+	f.stmt = c
+	return nil
+}
+
+func (f *Folder) visitDeclaration(d Declaration) error {
+	f.stmt = Declaration{d.Token, f.Expr(d.Expr), d.Const, d.Slot}
+	return nil
+}
+
+func (f *Folder) visitDeferStmt(d DeferStmt) error {
+	f.stmt = DeferStmt{d.Token, f.Stmt(d.Deferred)}
+	return nil
+}
+
+func (f *Folder) visitDestructuring(d Destructuring) error {
+	f.stmt = Destructuring{d.Token, d.Names, f.Expr(d.Expr), d.Const, d.Slots}
+	return nil
+}
+
+func (f *Folder) visitForIn(s ForIn) error {
+	f.stmt = ForIn{s.Token, s.Name, f.Expr(s.Iterable), f.Stmt(s.Body)}
+	return nil
+}
+
+func (f *Folder) visitForStmt(s ForStmt) error {
+	f.stmt = ForStmt{s.Token, f.Stmt(s.Init), f.Expr(s.Condition), f.Expr(s.Increment), f.Stmt(s.Body)}
+	return nil
+}
+
+func (f *Folder) visitFunction(fn Function) error {
+	f.stmt = f.function(fn)
+	return nil
+}
+
+func (f *Folder) visitImportStmt(i ImportStmt) error {
+	f.stmt = ImportStmt{i.Token, i.Path, f.stmts(i.Stmts)}
+	return nil
+}
+
+func (f *Folder) visitLabeled(l Labeled) error {
+	f.stmt = Labeled{l.Label, f.Stmt(l.Loop)}
+	return nil
+}
+
+func (f *Folder) visitIfStmt(s IfStmt) error {
+	f.stmt = IfStmt{s.Token, f.Expr(s.Condition), f.Stmt(s.Then), f.Stmt(s.Else)}
+	return nil
+}
+
+func (f *Folder) visitExprStmt(e ExprStmt) error {
+	f.stmt = ExprStmt{f.Expr(e.Expr)}
+	return nil
+}
+
+func (f *Folder) visitPrintStmt(p PrintStmt) error {
+	f.stmt = PrintStmt{p.Token, f.Expr(p.Expr)}
+	return nil
+}
+
+func (f *Folder) visitReturnStmt(r ReturnStmt) error {
+	f.stmt = ReturnStmt{r.Token, f.Expr(r.Expr)}
+	return nil
+}
+
+func (f *Folder) visitSwitchStmt(s SwitchStmt) error {
+	cases := make([]CaseClause, len(s.Cases))
+	for i, c := range s.Cases {
+		cases[i] = CaseClause{f.Expr(c.Value), f.block(c.Body)}
+	}
+
+	f.stmt = SwitchStmt{s.Token, f.Expr(s.Discriminant), cases, f.Stmt(s.Default)}
+	return nil
+}
+
+func (f *Folder) visitThrowStmt(t ThrowStmt) error {
+	f.stmt = ThrowStmt{t.Token, f.Expr(t.Expr)}
+	return nil
+}
+
+func (f *Folder) visitTryStmt(t TryStmt) error {
+	f.stmt = TryStmt{f.block(t.Body), t.Name, f.block(t.Catch)}
+	return nil
+}
+
+func (f *Folder) visitDoWhile(d DoWhile) error {
+	f.stmt = DoWhile{d.Token, f.Stmt(d.Body), f.Expr(d.Condition)}
+	return nil
+}
+
+func (f *Folder) visitWhileStmt(w WhileStmt) error {
+	f.stmt = WhileStmt{w.Token, f.Expr(w.Condition), f.Stmt(w.Body)}
+	return nil
+}
@@ -0,0 +1,154 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+// parse scans and parses a program.
+func parse(source string) ([]Stmt, error) {
+	scanner := Scanner{source}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		return nil, err
+	}
+
+	parser := Parser{Tokens: tokens}
+
+	return parser.Parse()
+}
+
+func TestFold(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"print 2 * 60 * 60;", "(print 7200)"},
+		{"print (1 + 2) * 3;", "(print 9)"},
+		{"print -(4 - 6);", "(print 2)"},
+		{"print !nil;", "(print true)"},
+		{"print \"a\" + \"b\";", "(print \"ab\")"},
+		{"print 1 < 2 == true;", "(print true)"},
+		{"print 2 ** 10 % 1000;", "(print 24)"},
+		{"print x * (2 + 3);", "(print (* x 5))"},
+		{"print x + 1 + 2;", "(print (+ (+ x 1) 2))"},
+		{"print 1 + 2 + x;", "(print (+ 3 x))"},
+		{"print (x);", "(print (group x))"},
+		{"var y = [1 + 1, {\"k\": 2 * 2}];", "(var y (list 2 (map \"k\" 4)))"},
+		{"fun f(a = 1 + 1) { return a * (3 - 1); }", "(fun f ((= a 2)) (return (* a 2)))"},
+		{"if (1 > 2) print 1; else { print 3 - 1; }", "(if false (print 1) (block (print 2)))"},
+		{"x = true ? 1 + 1 : 0;", "(; (= x (?: true 2 0)))"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			stmts, err := parse(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out := PrintStmts(Fold(stmts)); out != test.out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+		})
+	}
+}
+
+func TestFold_Errors(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"print 1 / 0;", "(print (/ 1 0))"},
+		{"print 2 * (1 % 0);", "(print (* 2 (group (% 1 0))))"},
+		{"print -\"a\";", "(print (- \"a\"))"},
+		{"print 1 + nil;", "(print (+ 1 nil))"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			stmts, err := parse(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			folded := Fold(stmts)
+			if out := PrintStmts(folded); out != test.out {
+				t.Errorf("want %s, got %s", test.out, out)
+			}
+
+			// the error is still raised at runtime
+			i := &Interpreter{}
+			if err := i.Run(folded); err == nil {
+				t.Errorf("want error, got nil")
+			}
+		})
+	}
+}
+
+func TestFold_Run(t *testing.T) {
+	stmts, err := parse(`fun seconds(hours) { return hours * 60 * 60; }
+	var total = seconds(2) + 24 * 60 * 60;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	i := &Interpreter{}
+	if err := i.Run(Fold(stmts)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if v := global(i, "total"); v != 93600.0 {
+		t.Errorf("want 93600, got %v", v)
+	}
+}
+
+func TestFold_Comments(t *testing.T) {
+	scanner := Scanner{"print 1; // one\n{ print 2;\n// two\n}\n// last"}
+	tokens, err := scanner.ScanComments()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	i := &Interpreter{stdout: stdout}
+	if err := i.Run(Fold(stmts)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "1\n2\n"; stdout.String() != want {
+		t.Errorf("want %q, got %q", want, stdout.String())
+	}
+
+	if want, out := "(print 1)\n(block (print 2))", PrintStmts(stmts); out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
@@ -0,0 +1,717 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Format formats Lox source in the canonical style: two spaces of
+// indentation, spaces around binary operators and a blank line around
+// function and class declarations. Comments are kept around the statement,
+// the class member or the element of a list they are attached to.
+func Format(source string) (string, error) {
+	scanner := Scanner{source}
+	tokens, err := scanner.ScanComments()
+	if err != nil {
+		return "", err
+	}
+
+	// imported files are not formatted, nor loaded
+	parser := Parser{Tokens: tokens, Loader: emptyLoader{}}
+	stmts, err := parser.Parse()
+	if err != nil {
+		return "", err
+	}
+
+	if len(stmts) == 0 {
+		return "", nil
+	}
+
+	f := formatter{"", parser.annotations}
+
+	return f.list(stmts) + "\n", nil
+}
+
+// emptyLoader loads every file as empty.
+type emptyLoader struct{}
+
+func (emptyLoader) Load(path string) (string, error) {
+	return "", nil
+}
+
+// formatter renders statements as source text, the lines nested in a
+// statement are indented relative to its first line.
+type formatter struct {
+	out         string
+	annotations map[Token]annotation // see Parser.annotate
+}
+
+func (f *formatter) expr(e Expr) string {
+	_ = e.Accept(f)
+	return f.out
+}
+
+func (f *formatter) stmt(s Stmt) string {
+	_ = s.Accept(f)
+	return f.out
+}
+
+// exprs renders comma separated expressions between open and close.
+func (f *formatter) exprs(open string, exprs []Expr, close string) string {
+	parts := make([]string, len(exprs))
+	notes := make([]annotation, len(exprs))
+	for i, e := range exprs {
+		parts[i] = f.expr(e)
+		notes[i] = comments(e)
+	}
+
+	return separated(open, parts, notes, close)
+}
+
+// comments returns the comments around an element of a list.
+func comments(e Expr) annotation {
+	if c, ok := e.(CommentedExpr); ok {
+		return annotation{c.Leading, c.Trailing}
+	}
+
+	return annotation{}
+}
+
+// separated renders comma separated parts between open and close, on one
+// line unless some of them has comments, then one per line with them.
+func separated(open string, parts []string, notes []annotation, close string) string {
+	multiline := false
+	for _, n := range notes {
+		if len(n.leading) > 0 || len(n.trailing) > 0 {
+			multiline = true
+		}
+	}
+
+	if !multiline {
+		return open + strings.Join(parts, ", ") + close
+	}
+
+	return strings.TrimRight(open, " ") + "\n  " + indent(perLine(parts, notes)) + "\n" + strings.TrimLeft(close, " ")
+}
+
+// perLine renders parts one per line with their comments, each followed
+// by a comma so that a line comment after the last one is not taken for a
+// floor division.
+func perLine(parts []string, notes []annotation) string {
+	lines := make([]string, len(parts))
+	for i, part := range parts {
+		lines[i] = annotated(part+",", notes[i])
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// enclose renders the body of a class or an enum between braces, followed
+// by the comments closing it.
+func enclose(body string, closing annotation) string {
+	for _, comment := range closing.leading {
+		if body != "" {
+			body += "\n"
+		}
+
+		body += uncomment(comment)
+	}
+
+	if body == "" {
+		return "{}"
+	}
+
+	return "{\n  " + indent(body) + "\n}"
+}
+
+// annotated renders text with the comments preceding it, on lines of
+// their own, and the ones following it on its last line.
+func annotated(text string, note annotation) string {
+	var sb strings.Builder
+
+	for _, comment := range note.leading {
+		sb.WriteString(uncomment(comment) + "\n")
+	}
+
+	sb.WriteString(text)
+
+	for _, comment := range note.trailing {
+		sb.WriteString(" " + uncomment(comment))
+	}
+
+	return sb.String()
+}
+
+// list renders a sequence of statements, one per line.
+func (f *formatter) list(stmts []Stmt) string {
+	var sb strings.Builder
+
+	for i, s := range stmts {
+		if i > 0 {
+			sb.WriteString("\n")
+
+			if isDeclaration(stmts[i-1]) || isDeclaration(s) {
+				sb.WriteString("\n")
+			}
+		}
+
+		c, ok := s.(Commented)
+		if !ok {
+			sb.WriteString(f.stmt(s))
+			continue
+		}
+
+		for j, comment := range c.Leading {
+			if j > 0 {
+				sb.WriteString("\n")
+			}
+
+			sb.WriteString(uncomment(comment))
+		}
+
+		if c.Stmt == nil {
+			continue
+		}
+
+		if len(c.Leading) > 0 {
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString(f.stmt(c.Stmt))
+
+		for _, comment := range c.Trailing {
+			sb.WriteString(" " + uncomment(comment))
+		}
+	}
+
+	return sb.String()
+}
+
+// isDeclaration reports whether s declares a function or a class, which
+// are set apart by blank lines.
+func isDeclaration(s Stmt) bool {
+	switch uncommented(s).(type) {
+	case Function, ClassStmt:
+		return true
+	}
+
+	return false
+}
+
+// uncomment returns the text of a comment, the lines of a block comment
+// lose the indentation of its first line so that they can be indented
+// again.
+func uncomment(comment Token) string {
+	lines := strings.Split(strings.TrimRight(comment.Lexeme, " \t"), "\n")
+	for i := 1; i < len(lines); i++ {
+		line := lines[i]
+		for j := 1; j < comment.Column && strings.HasPrefix(line, " "); j++ {
+			line = line[1:]
+		}
+
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// indent indents every line of text but the first one, which continues
+// a line already indented, blank lines are left empty.
+func indent(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = "  " + lines[i]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (f *formatter) block(stmts []Stmt) string {
+	if len(stmts) == 0 {
+		return "{}"
+	}
+
+	return "{\n  " + indent(f.list(stmts)) + "\n}"
+}
+
+// body renders the body of a control flow statement after its header.
+func (f *formatter) body(s Stmt) string {
+	if b, ok := s.(Block); ok {
+		return " " + f.block(b.Stmts)
+	}
+
+	return " " + f.stmt(s)
+}
+
+func (f *formatter) function(name string, arguments []Token, defaults []Expr, variadic bool, body []Stmt) string {
+	names := make([]string, len(arguments))
+	notes := make([]annotation, len(arguments))
+	for i, argument := range arguments {
+		names[i] = argument.Lexeme
+		notes[i] = f.annotations[argument]
+
+		if i < len(defaults) && defaults[i] != nil {
+			names[i] += " = " + f.expr(defaults[i])
+		}
+	}
+
+	if variadic {
+		names[len(names)-1] = "..." + names[len(names)-1]
+	}
+
+	return name + separated("(", names, notes, ") ") + f.block(body)
+}
+
+func (f *formatter) method(m Function) string {
+	if m.Getter {
+		return m.Name.Lexeme + " " + f.block(m.Body)
+	}
+
+	return f.function(m.Name.Lexeme, m.Arguments, m.Defaults, m.Variadic, m.Body)
+}
+
+// quote returns s as a string literal, with the escapes of the scanner.
+func quote(s string) string {
+	var sb strings.Builder
+
+	sb.WriteString("\"")
+	for _, r := range s {
+		switch r {
+		case '\n':
+			sb.WriteString("\\n")
+		case '\t':
+			sb.WriteString("\\t")
+		case '\r':
+			sb.WriteString("\\r")
+		case '\\':
+			sb.WriteString("\\\\")
+		case '"':
+			sb.WriteString("\\\"")
+		case '\x00':
+			sb.WriteString("\\0")
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteString("\"")
+
+	return sb.String()
+}
+
+func (f *formatter) visitAssign(a Assign) error {
+	if a.Token.TokenType == Equal {
+		f.out = a.Variable.Lexeme + " = " + f.expr(a.Expr)
+		return nil
+	}
+
+	// a compound assignment is parsed as the binary operation
+	f.out = a.Variable.Lexeme + " " + a.Token.Lexeme + " " + f.expr(a.Expr.(Binary).Right)
+
+	return nil
+}
+
+func (f *formatter) visitBinary(b Binary) error {
+	f.out = f.expr(b.Left) + " " + b.Operator.Lexeme + " " + f.expr(b.Right)
+	return nil
+}
+
+func (f *formatter) visitCall(c Call) error {
+	f.out = f.expr(c.Callee) + f.exprs("(", c.Arguments, ")")
+	return nil
+}
+
+func (f *formatter) visitGet(g Get) error {
+	dot := "."
+	if g.Optional {
+		dot = "?."
+	}
+
+	f.out = f.expr(g.Object) + dot + g.Name.Lexeme
+	return nil
+}
+
+func (f *formatter) visitGrouping(g Grouping) error {
+	f.out = "(" + f.expr(g.Expr) + ")"
+	return nil
+}
+
+func (f *formatter) visitIndex(x Index) error {
+	f.out = f.expr(x.Object) + "[" + f.expr(x.Index) + "]"
+	return nil
+}
+
+func (f *formatter) visitIndexSet(x IndexSet) error {
+	f.out = f.expr(x.Object) + "[" + f.expr(x.Index) + "] " + x.Operator.Lexeme + "= " + f.expr(x.Value)
+	return nil
+}
+
+func (f *formatter) visitLambda(l Lambda) error {
+	f.out = f.function("fun ", l.Arguments, l.Defaults, l.Variadic, l.Body)
+	return nil
+}
+
+func (f *formatter) visitList(l List) error {
+	f.out = f.exprs("[", l.Elements, "]")
+	return nil
+}
+
+func (f *formatter) visitLiteral(l Literal) error {
+	switch v := l.Value.(type) {
+	case string:
+		{
+			f.out = quote(v)
+		}
+	case float64:
+		{
+			// there is no exponent notation
+			f.out = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	default:
+		{
+			f.out = l.String()
+		}
+	}
+
+	return nil
+}
+
+func (f *formatter) visitLogical(l Logical) error {
+	f.out = f.expr(l.Left) + " " + l.Operator.Lexeme + " " + f.expr(l.Right)
+	return nil
+}
+
+func (f *formatter) visitMap(m Map) error {
+	parts := make([]string, len(m.Keys))
+	notes := make([]annotation, len(m.Keys))
+	for i := range m.Keys {
+		parts[i] = f.expr(m.Keys[i]) + ": " + f.expr(m.Values[i])
+		notes[i] = comments(m.Keys[i])
+	}
+
+	f.out = separated("{", parts, notes, "}")
+
+	return nil
+}
+
+func (f *formatter) visitRecord(r Record) error {
+	parts := make([]string, len(r.Names))
+	notes := make([]annotation, len(r.Names))
+	for i, name := range r.Names {
+		parts[i] = name.Lexeme + ": " + f.expr(r.Values[i])
+		notes[i] = comments(r.Values[i])
+	}
+
+	f.out = separated("{", parts, notes, "}")
+
+	return nil
+}
+
+func (f *formatter) visitSet(s Set) error {
+	f.out = f.expr(s.Object) + "." + s.Name.Lexeme + " " + s.Operator.Lexeme + "= " + f.expr(s.Value)
+	return nil
+}
+
+func (f *formatter) visitSuperExpr(s SuperExpr) error {
+	f.out = "super." + s.Method.Lexeme
+	return nil
+}
+
+func (f *formatter) visitTernary(t Ternary) error {
+	f.out = f.expr(t.Condition) + " ? " + f.expr(t.Then) + " : " + f.expr(t.Else)
+	return nil
+}
+
+func (f *formatter) visitThisExpr(t ThisExpr) error {
+	f.out = "this"
+	return nil
+}
+
+func (f *formatter) visitUnary(u Unary) error {
+	f.out = u.Operator.Lexeme + f.expr(u.Right)
+	return nil
+}
+
+func (f *formatter) visitVariable(v Variable) error {
+	f.out = v.Lexeme
+	return nil
+}
+
+func (f *formatter) visitBlock(b Block) error {
+	f.out = f.block(b.Stmts)
+	return nil
+}
+
+func (f *formatter) visitBreakStmt(b BreakStmt) error {
+	f.out = "break;"
+	if b.Label.Lexeme != "" {
+		f.out = "break " + b.Label.Lexeme + ";"
+	}
+
+	return nil
+}
+
+// member is a field or a method of a class, as rendered.
+type member struct {
+	name  Token
+	text  string
+	field bool
+}
+
+func (f *formatter) visitClassStmt(c ClassStmt) error {
+	header := "class " + c.Name.Lexeme
+	if c.Superclass != nil {
+		header += " < " + f.expr(c.Superclass)
+	}
+
+	var members []member
+	for _, d := range c.Fields {
+		members = append(members, member{d.Token, f.stmt(d), true})
+	}
+
+	for _, m := range c.Methods {
+		members = append(members, member{m.Name, f.method(m), false})
+	}
+
+	for _, m := range c.Statics {
+		members = append(members, member{m.Name, "class " + f.method(m), false})
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return before(members[i].name, members[j].name)
+	})
+
+	// the fields declared one after the other are not set apart
+	var sb strings.Builder
+	for i, m := range members {
+		if i > 0 {
+			sb.WriteString("\n")
+
+			if !m.field || !members[i-1].field {
+				sb.WriteString("\n")
+			}
+		}
+
+		sb.WriteString(annotated(m.text, f.annotations[m.name]))
+	}
+
+	f.out = header + " " + enclose(sb.String(), f.annotations[c.Name])
+
+	return nil
+}
+
+func (f *formatter) visitEnumStmt(e EnumStmt) error {
+	members := make([]string, len(e.Members))
+	notes := make([]annotation, len(e.Members))
+	for i, m := range e.Members {
+		members[i] = m.Lexeme
+		notes[i] = f.annotations[m]
+	}
+
+	header := "enum " + e.Name.Lexeme + " "
+	if closing := f.annotations[e.Name]; len(members) == 0 || len(closing.leading) > 0 {
+		f.out = header + enclose(perLine(members, notes), closing)
+		return nil
+	}
+
+	f.out = header + separated("{ ", members, notes, " }")
+
+	return nil
+}
+
+func (f *formatter) visitContinueStmt(c ContinueStmt) error {
+	f.out = "continue;"
+	if c.Label.Lexeme != "" {
+		f.out = "continue " + c.Label.Lexeme + ";"
+	}
+
+	return nil
+}
+
+func (f *formatter) visitDeclaration(d Declaration) error {
+	keyword := "var "
+	if d.Const {
+		keyword = "const "
+	}
+
+	if d.Expr == nil {
+		f.out = keyword + d.Lexeme + ";"
+	} else {
+		f.out = keyword + d.Lexeme + " = " + f.expr(d.Expr) + ";"
+	}
+
+	return nil
+}
+
+func (f *formatter) visitDeferStmt(d DeferStmt) error {
+	f.out = "defer" + f.body(d.Deferred)
+	return nil
+}
+
+func (f *formatter) visitDestructuring(d Destructuring) error {
+	names := make([]string, len(d.Names))
+	for j, name := range d.Names {
+		names[j] = name.Lexeme
+	}
+
+	f.out = d.Lexeme + " " + strings.Join(names, ", ") + " = " + f.expr(d.Expr) + ";"
+
+	return nil
+}
+
+func (f *formatter) visitExprStmt(e ExprStmt) error {
+	f.out = f.expr(e.Expr) + ";"
+	return nil
+}
+
+func (f *formatter) visitForIn(s ForIn) error {
+	f.out = "for (" + s.Name.Lexeme + " in " + f.expr(s.Iterable) + ")" + f.body(s.Body)
+	return nil
+}
+
+func (f *formatter) visitForStmt(s ForStmt) error {
+	header := "for (;"
+	if s.Init != nil {
+		header = "for (" + f.stmt(s.Init)
+	}
+
+	if s.Condition != nil {
+		header += " " + f.expr(s.Condition)
+	}
+	header += ";"
+
+	if s.Increment != nil {
+		header += " " + f.expr(s.Increment)
+	}
+
+	f.out = header + ")" + f.body(s.Body)
+
+	return nil
+}
+
+func (f *formatter) visitFunction(fn Function) error {
+	f.out = f.function("fun "+fn.Name.Lexeme, fn.Arguments, fn.Defaults, fn.Variadic, fn.Body)
+	return nil
+}
+
+func (f *formatter) visitIfStmt(s IfStmt) error {
+	out := "if (" + f.expr(s.Condition) + ")" + f.body(s.Then)
+
+	if s.Else != nil {
+		// else follows a closing brace on the same line
+		if _, ok := s.Then.(Block); ok {
+			out += " else"
+		} else {
+			out += "\nelse"
+		}
+
+		out += f.body(s.Else)
+	}
+
+	f.out = out
+
+	return nil
+}
+
+func (f *formatter) visitImportStmt(s ImportStmt) error {
+	f.out = "import " + quote(s.Path) + ";"
+	return nil
+}
+
+func (f *formatter) visitLabeled(l Labeled) error {
+	f.out = l.Label.Lexeme + ": " + f.stmt(l.Loop)
+	return nil
+}
+
+func (f *formatter) visitPrintStmt(p PrintStmt) error {
+	f.out = "print " + f.expr(p.Expr) + ";"
+	return nil
+}
+
+func (f *formatter) visitReturnStmt(r ReturnStmt) error {
+	if r.Expr == nil {
+		f.out = "return;"
+		return nil
+	}
+
+	f.out = "return " + f.expr(r.Expr) + ";"
+	return nil
+}
+
+func (f *formatter) visitSwitchStmt(s SwitchStmt) error {
+	var clauses []string
+	for _, c := range s.Cases {
+		clauses = append(clauses, "case "+f.expr(c.Value)+":"+f.clause(c.Body.Stmts))
+	}
+
+	if s.Default != nil {
+		clauses = append(clauses, "default:"+f.clause(s.Default.(Block).Stmts))
+	}
+
+	f.out = "switch (" + f.expr(s.Discriminant) + ") {\n" + strings.Join(clauses, "\n") + "\n}"
+
+	return nil
+}
+
+// clause renders the body of a switch clause, below its label.
+func (f *formatter) clause(stmts []Stmt) string {
+	if len(stmts) == 0 {
+		return ""
+	}
+
+	return "\n  " + indent(f.list(stmts))
+}
+
+func (f *formatter) visitThrowStmt(t ThrowStmt) error {
+	f.out = "throw " + f.expr(t.Expr) + ";"
+	return nil
+}
+
+func (f *formatter) visitTryStmt(t TryStmt) error {
+	f.out = "try " + f.block(t.Body.Stmts) + " catch (" + t.Name.Lexeme + ") " + f.block(t.Catch.Stmts)
+	return nil
+}
+
+func (f *formatter) visitDoWhile(d DoWhile) error {
+	out := "do" + f.body(d.Body)
+
+	// while follows a closing brace on the same line
+	if _, ok := d.Body.(Block); ok {
+		out += " while"
+	} else {
+		out += "\nwhile"
+	}
+
+	f.out = out + " (" + f.expr(d.Condition) + ");"
+
+	return nil
+}
+
+func (f *formatter) visitWhileStmt(w WhileStmt) error {
+	f.out = "while (" + f.expr(w.Condition) + ")" + f.body(w.Body)
+	return nil
+}
@@ -0,0 +1,151 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormat_Golden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "format", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Format(string(source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(file, ".lox") + ".golden"
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(want) {
+				t.Errorf("want:\n%s\ngot:\n%s", want, got)
+			}
+
+			// formatted code is left as it is
+			again, err := Format(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if again != got {
+				t.Errorf("not idempotent, want:\n%s\ngot:\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"", ""},
+		{"print   1+2 ;", "print 1 + 2;\n"},
+		{"var a=1;fun f(){}var b=2;", "var a = 1;\n\nfun f() {}\n\nvar b = 2;\n"},
+		{"if(a)print 1;else print 2;", "if (a) print 1;\nelse print 2;\n"},
+		{"for(;;){}", "for (;;) {}\n"},
+		{"for(var x in xs)print x;", "for (x in xs) print x;\n"},
+		{"print a ?. b.c??d;", "print a?.b.c ?? d;\n"},
+		{"var p = {x:1,y : 2};", "var p = {x: 1, y: 2};\n"},
+		{"do{x=x+1;}while(x<3);", "do {\n  x = x + 1;\n} while (x < 3);\n"},
+		{"do print x; while (false);", "do print x;\nwhile (false);\n"},
+		{"var q,r=divmod(7,3);", "var q, r = divmod(7, 3);\n"},
+		{"const a ,b,c = xs;", "const a, b, c = xs;\n"},
+		{"enum Color{RED,GREEN ,BLUE}", "enum Color { RED, GREEN, BLUE }\n"},
+		{"enum Empty {  }", "enum Empty {}\n"},
+		{"fun f(){return ;}", "fun f() {\n  return;\n}\n"},
+		{"fun f(){defer close (x) ;}", "fun f() {\n  defer close(x);\n}\n"},
+		{"fun f(){defer{a();b();}}", "fun f() {\n  defer {\n    a();\n    b();\n  }\n}\n"},
+		{"#line 3 \"gen.dsl\"\nprint  1;", "#line 3 \"gen.dsl\"\nprint 1;\n"},
+		{"outer:while(true){break  outer;}", "outer: while (true) {\n  break outer;\n}\n"},
+		{"print 1; // one\n// two\nprint 2;", "print 1; // one\n// two\nprint 2;\n"},
+		{"if (a) { print 1; } // done", "if (a) {\n  print 1;\n} // done\n"},
+		{"// only a comment", "// only a comment\n"},
+		{"print 1_000.5;", "print 1000.5;\n"},
+		{`import "./lib.lox";`, "import \"lib.lox\";\n"},
+	}
+
+	for _, test := range table {
+		out, err := Format(test.in)
+		if err != nil {
+			t.Errorf("%q: %v", test.in, err)
+			continue
+		}
+
+		if out != test.out {
+			t.Errorf("%q: expected %q, got %q", test.in, test.out, out)
+		}
+	}
+}
+
+func TestFormat_Error(t *testing.T) {
+	if _, err := Format("print (1;"); err == nil {
+		t.Error("expected a syntax error")
+	}
+}
+
+func TestFormat_Run(t *testing.T) {
+	// formatting does not change what a program does
+	source := "var s=0;for(var i=0;i<5;i+=1){if(i==3)continue;s=s+i;}print s;"
+
+	formatted, err := Format(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := output(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := output(formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before != after {
+		t.Errorf("expected %q, got %q", before, after)
+	}
+}
@@ -0,0 +1,88 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FileSystem is the file system used by the file natives.
+type FileSystem interface {
+	ReadFile(path string) (string, error)
+	WriteFile(path string, contents string) error
+	AppendFile(path string, contents string) error // creating the file if needed
+}
+
+// OSFileSystem is the file system of the operating system.
+type OSFileSystem struct{}
+
+func (OSFileSystem) ReadFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (OSFileSystem) WriteFile(path string, contents string) error {
+	return ioutil.WriteFile(path, []byte(contents), 0644)
+}
+
+func (OSFileSystem) AppendFile(path string, contents string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(contents); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// MapFileSystem is an in-memory file system mapping paths to contents.
+type MapFileSystem map[string]string
+
+func (m MapFileSystem) ReadFile(path string) (string, error) {
+	contents, ok := m[filepath.Clean(path)]
+	if !ok {
+		return "", &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	return contents, nil
+}
+
+func (m MapFileSystem) WriteFile(path string, contents string) error {
+	m[filepath.Clean(path)] = contents
+	return nil
+}
+
+func (m MapFileSystem) AppendFile(path string, contents string) error {
+	m[filepath.Clean(path)] += contents
+	return nil
+}
@@ -22,63 +22,135 @@
 
 package ast
 
-import (
-	"time"
-)
-
 type Callable interface {
 	Arity() int
 	Call(interpreter *Interpreter, arguments []Expr) (Literal, error)
 }
 
+// Arity returns the number of required arguments, that is the minimum
+// number of arguments for variadic functions and functions with defaults.
 func (f Function) Arity() int {
+	for j := range f.Arguments {
+		if j < len(f.Defaults) && f.Defaults[j] != nil {
+			return j
+		}
+	}
+
+	if f.Variadic {
+		return len(f.Arguments) - 1
+	}
+
 	return len(f.Arguments)
 }
 
+// checkArguments checks the number of arguments n of a call.
+func (f Function) checkArguments(paren Token, n int) error {
+	min, max := f.Arity(), len(f.Arguments)
+	if f.Variadic {
+		max = -1
+	}
+
+	switch {
+	case min == max && n != min:
+		{
+			return errorAt(paren, "expected %d arguments but got %d", min, n)
+		}
+	case max < 0 && n < min:
+		{
+			return errorAt(paren, "expected at least %d arguments but got %d", min, n)
+		}
+	case max >= 0 && (n < min || n > max):
+		{
+			return errorAt(paren, "expected %d to %d arguments but got %d", min, max, n)
+		}
+	}
+
+	return nil
+}
+
 func (f Function) Call(i *Interpreter, arguments []Expr) (Literal, error) {
+	previous := i.Environment
 	i.Environment = NewEnvironment(f.Closure)
 
-	for j, argument := range arguments {
-		expr, err := i.Evaluate(argument)
-		if err != nil {
-			return Literal{}, err
+	// the caller scope is restored however the function exits
+	defer func() { i.Environment = previous }()
+
+	for j := range f.Arguments {
+		if f.Variadic && j == len(f.Arguments)-1 {
+			i.stats.allocation()
+			rest := &ListValue{[]interface{}{}}
+			for k := j; k < len(arguments); k++ {
+				l, err := i.Evaluate(arguments[k])
+				if err != nil {
+					return Literal{}, err
+				}
+
+				rest.Elements = append(rest.Elements, l.Value)
+			}
+
+			i.defineLocal(j, f.Arguments[j].Lexeme, Literal{rest})
+
+			break
+		}
+
+		var expr Literal
+		var err error
+
+		if j < len(arguments) {
+			expr, err = i.Evaluate(arguments[j])
+		} else {
+			// defaults are evaluated at every call in the declaration scope
+			scope := i.Environment
+			i.Environment = f.Closure
+			expr, err = i.Evaluate(f.Defaults[j])
+			i.Environment = scope
 		}
 
-		if err := i.Environment.Declare(Variable{f.Arguments[j]}, expr); err != nil {
+		if err != nil {
 			return Literal{}, err
 		}
+
+		// arguments take the first slots in order
+		i.defineLocal(j, f.Arguments[j].Lexeme, expr)
 	}
 
+	mark := len(i.deferred)
+
+	var result Literal // void unless returned
+	var err error
 	for _, stmt := range f.Body {
-		if err := stmt.Accept(i); err != nil {
+		if err = i.execute(stmt); err != nil {
 			if r, ok := err.(ReturnValue); ok {
-				i.Environment = i.Environment.Parent
-				return r.Literal, nil
+				result, err = r.Literal, nil
 			}
 
-			return Literal{}, err
+			break
 		}
 	}
 
-	i.Environment = i.Environment.Parent
+	if err = i.unwind(mark, err); err != nil {
+		return Literal{}, err
+	}
 
-	return Literal{}, nil // void
+	return result, nil
 }
 
-func (c ClassStmt) Arity() int {
-	return 0
-}
+func (f Function) String() string {
+	if f.Name.Lexeme == "" {
+		return "<fn>"
+	}
 
-func (c ClassStmt) Call(i *Interpreter, arguments []Expr) (Literal, error) {
-	return c.CreateInstance(), nil
+	return "<fn " + f.Name.Lexeme + ">"
 }
 
-type Clock struct{}
+// bind returns the method bound to instance, which is the first slot of
+// a scope enclosing the arguments.
+func (f *Function) bind(instance *ClassInstance) *Function {
+	closure := NewEnvironment(f.Closure)
+	closure.Define(0, Literal{instance})
 
-func (c Clock) Arity() int {
-	return 0
-}
+	bound := *f
+	bound.Closure = closure
 
-func (c Clock) Call(interpreter *Interpreter, arguments []Expr) (Literal, error) {
-	return Literal{time.Now().Unix()}, nil
+	return &bound
 }
@@ -23,15 +23,100 @@
 package ast
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
 type Interpreter struct {
 	Literal
-	Locals map[string]int
+	*Environment
+	Globals *Environment
+
+	// Input is the source of the lines read by the read natives, os.Stdin
+	// if nil, a *bufio.Reader is read as is and any other reader through
+	// a buffer of its own
+	Input    io.Reader
+	stdin    *bufio.Reader // buffering Input
+	buffered io.Reader     // the reader stdin buffers
+
+	stdout io.Writer // print output, os.Stdout if nil
+	stderr io.Writer // error reports, os.Stderr if nil
+
+	// MaxDepth limits the depth of nested calls, DefaultMaxDepth if zero
+	MaxDepth int
+	frames   []Frame
+	site     Token // call of the innermost running native
+
+	// MaxSteps limits the number of statements a run executes, blocks
+	// included, unlimited if zero
+	MaxSteps int
+	steps    int
+	ctx      context.Context // of the current run, see RunWithContext
+	done     <-chan struct{} // of ctx, nil if it is never done
+	at       Token           // of the last statement run with a position
+
+	// ReplMode echoes the value of top-level expression statements
+	ReplMode bool
+
+	// Time is the clock of the time natives, the system clock if nil
+	Time TimeSource
+
+	// Files is the file system of the file natives, which are disabled if
+	// nil, see OSFileSystem
+	Files FileSystem
+
+	// Args are the arguments of the program and Env its environment
+	// variables by name, as returned by args and getEnv
+	Args []string
+	Env  map[string]string
+
+	rand *rand.Rand // generator of the random natives
+
+	regexps map[string]*regexp.Regexp // compiled by the regexp natives
+
+	// Bytecode compiles programs and runs them on the VM, programs the
+	// compiler does not support yet are tree-walked anyway
+	Bytecode bool
+
+	// DebugHook is called before running every statement, if set
+	DebugHook DebugHook
+
+	// CoverageMode counts the runs of every line, see Coverage
+	CoverageMode bool
+	coverage     map[int]int
+
+	// StatsMode counts evaluations, calls, lookups and allocations, see
+	// Stats
+	StatsMode bool
+	stats     *Stats
+
+	label string // of the loop about to run, taken by the loop
+
+	constants map[string]bool // global constants declared by the runs that succeeded
+
+	deferred []deferral // by the running functions, the innermost last
+}
+
+// deferral is a deferred statement and the scope it was deferred in.
+type deferral struct {
+	Stmt
 	*Environment
 }
 
+const DefaultMaxDepth = 1000
+
+// maxRepeatLength is the longest string, in bytes, string repetition can build.
+const maxRepeatLength = 1 << 30
+
 type ReturnValue struct {
 	Literal
 }
@@ -40,28 +125,341 @@ func (r ReturnValue) Error() string {
 	return r.Literal.String()
 }
 
+// LoopBreak is returned by a break statement and unwinds the
+// execution up to the innermost enclosing loop, or the one with Label.
+type LoopBreak struct {
+	Label string
+}
+
+func (b LoopBreak) Error() string {
+	return "break"
+}
+
+// LoopContinue is returned by a continue statement and skips the rest
+// of the body of the innermost enclosing loop, or the one with Label.
+type LoopContinue struct {
+	Label string
+}
+
+func (c LoopContinue) Error() string {
+	return "continue"
+}
+
+// looped handles the error of a run of the body of a loop, labeled label
+// if not empty. A break of the loop stops it and a continue goes on, any
+// other error stops it and propagates, like a break of an outer loop.
+func looped(err error, label string) (bool, error) {
+	switch e := err.(type) {
+	case LoopBreak:
+		{
+			if e.Label == "" || e.Label == label {
+				return true, nil
+			}
+		}
+	case LoopContinue:
+		{
+			if e.Label == "" || e.Label == label {
+				return false, nil
+			}
+		}
+	}
+
+	return true, err
+}
+
+// Thrown is returned by a throw statement and unwinds the execution up
+// to the innermost enclosing try statement.
+type Thrown struct {
+	Token
+	Literal
+}
+
+func (t Thrown) Error() string {
+	return errorAt(t.Token, "uncaught %v", t.Literal).Error()
+}
+
 func (i *Interpreter) Run(stmts []Stmt) error {
-	r := Resolver{}
+	return i.RunWithContext(context.Background(), stmts)
+}
+
+// RunContext scans, parses and runs source until it ends or ctx is done,
+// see RunWithContext. Imported files are loaded from the file system.
+func (i *Interpreter) RunContext(ctx context.Context, source string) error {
+	scanner := Scanner{source}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		return err
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+
+	return i.RunWithContext(ctx, stmts)
+}
+
+// RunWithContext runs a program until it ends or ctx is done, whichever
+// comes first. Like running more than MaxSteps statements, a deadline of
+// ctx aborts the run with an execution budget exceeded error at the next
+// statement, and a cancellation with an execution canceled error.
+func (i *Interpreter) RunWithContext(ctx context.Context, stmts []Stmt) error {
+	r := Resolver{Constants: i.constants}
 
 	if err := r.Resolve(stmts); err != nil {
 		return err
 	}
 
-	i.Locals = r.Locals
+	i.Environment = i.globals()
+
+	if i.CoverageMode {
+		i.cover(stmts)
+	}
+
+	if i.StatsMode && i.stats == nil {
+		i.stats = &Stats{}
+	}
+
+	i.steps = 0
+	i.ctx, i.done = ctx, ctx.Done()
+	defer func() { i.ctx, i.done = nil, nil }()
+
+	// the VM does not call the debug hook nor count lines, stats or steps
+	if i.Bytecode && i.DebugHook == nil && !i.CoverageMode && !i.StatsMode && !i.limited() {
+		compiler := Compiler{Echo: i.ReplMode}
+		if chunk, err := compiler.Compile(stmts); err == nil {
+			if err := NewVM(i).Run(chunk); err != nil {
+				return err
+			}
 
-	i.Environment = NewEnvironment(nil)
-	i.Environment.Set("clock", Clock{})
+			i.constants = r.Constants
+			return nil
+		}
+	}
 
 	for _, stmt := range stmts {
-		if err := stmt.Accept(i); err != nil {
+		err := i.execute(stmt)
+		if err == nil && i.ReplMode {
+			err = i.echo(stmt)
+		}
+
+		if err != nil {
+			if e, ok := err.(*Error); ok {
+				return i.runtimeError(e)
+			}
+
 			return err
 		}
 	}
 
+	// a run failing before it declares its constants does not record them
+	i.constants = r.Constants
+
+	return nil
+}
+
+// echo prints the value of an expression statement the way it would
+// appear in source, assignments and nil values are not echoed.
+func (i *Interpreter) echo(stmt Stmt) error {
+	e, ok := uncommented(stmt).(ExprStmt)
+	if !ok {
+		return nil
+	}
+
+	switch e.Expr.(type) {
+	case Assign, Set, IndexSet:
+		{
+			return nil
+		}
+	}
+
+	if i.Literal.Value == nil {
+		return nil
+	}
+
+	s, err := i.repr(exprToken(e.Expr), i.Literal.Value)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(i.output(), s)
+
 	return nil
 }
 
+// SetInput sets the source of the lines read by the program, like setting
+// Input.
+func (i *Interpreter) SetInput(r io.Reader) {
+	i.Input = r
+}
+
+func (i *Interpreter) input() *bufio.Reader {
+	r := i.Input
+	if r == nil {
+		r = os.Stdin
+	}
+
+	if i.stdin == nil || i.buffered != r {
+		if b, ok := r.(*bufio.Reader); ok {
+			i.stdin = b
+		} else {
+			i.stdin = bufio.NewReader(r)
+		}
+
+		i.buffered = r
+	}
+
+	return i.stdin
+}
+
+// SetOutput sets the destination of the program output.
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.stdout = w
+}
+
+// SetErrorOutput sets the destination of the errors passed to Report.
+func (i *Interpreter) SetErrorOutput(w io.Writer) {
+	i.stderr = w
+}
+
+func (i *Interpreter) output() io.Writer {
+	if i.stdout == nil {
+		return os.Stdout
+	}
+
+	return i.stdout
+}
+
+func (i *Interpreter) errorOutput() io.Writer {
+	if i.stderr == nil {
+		return os.Stderr
+	}
+
+	return i.stderr
+}
+
+func (i *Interpreter) maxDepth() int {
+	if i.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+
+	return i.MaxDepth
+}
+
+// Context returns the context of the current run, for natives to stop
+// their work once it is done, or the background context between runs.
+func (i *Interpreter) Context() context.Context {
+	if i.ctx == nil {
+		return context.Background()
+	}
+
+	return i.ctx
+}
+
+// limited reports whether the current run has a budget, of steps or time.
+func (i *Interpreter) limited() bool {
+	return i.MaxSteps > 0 || i.done != nil
+}
+
+// budget counts a statement about to run and aborts the run once its
+// budget is exhausted or its context is done. A statement without a
+// position, like a block, is located at the last one with a position.
+func (i *Interpreter) budget(stmt Stmt) error {
+	if t := stmtToken(stmt); t.Line > 0 {
+		i.at = t
+	}
+
+	i.steps++
+	if i.MaxSteps > 0 && i.steps > i.MaxSteps {
+		return errorAt(i.at, "execution budget exceeded")
+	}
+
+	select {
+	case <-i.done:
+		{
+			if i.ctx.Err() == context.DeadlineExceeded {
+				return errorAt(i.at, "execution budget exceeded")
+			}
+
+			return errorAt(i.at, "execution canceled")
+		}
+	default:
+		{
+			return nil
+		}
+	}
+}
+
+// Report writes an error to the error output, followed by the stack
+// trace of runtime errors.
+func (i *Interpreter) Report(err error) {
+	fmt.Fprintln(i.errorOutput(), err)
+
+	if e, ok := err.(*RuntimeError); ok && len(e.Trace) > 0 {
+		fmt.Fprintln(i.errorOutput(), e.Stack())
+	}
+}
+
+// runtimeError returns e with the current call stack.
+func (i *Interpreter) runtimeError(e *Error) *RuntimeError {
+	trace := make([]Frame, len(i.frames))
+	for j, frame := range i.frames {
+		trace[len(trace)-1-j] = frame
+	}
+
+	return &RuntimeError{e.Line, e.Column, e.Message, e.Token, trace}
+}
+
+// declare binds a value to the name declared by t, globals by name and
+// locals in their slot.
+func (i *Interpreter) declare(t Token, slot *Slot, value Literal) error {
+	if slot.local() {
+		i.defineLocal(slot.Index, t.Lexeme, value)
+		return nil
+	}
+
+	return i.Environment.Declare(Variable{t, nil}, value)
+}
+
+// globals returns the global scope, creating it on first use so that
+// natives can be registered before running a program.
+func (i *Interpreter) globals() *Environment {
+	if i.Globals == nil {
+		i.Globals = NewEnvironment(nil)
+
+		// defined even without TimeLib, like it always was
+		i.define("clock", 0, clock(i.time().Now()))
+	}
+
+	return i.Globals
+}
+
 func (i *Interpreter) Evaluate(expr Expr) (Literal, error) {
+	i.stats.evaluation()
+	err := expr.Accept(i)
+
+	// the chain is over, so it is nil as a whole
+	if _, ok := err.(nilChain); ok {
+		i.Literal = Literal{}
+		return i.Literal, nil
+	}
+
+	return i.Literal, err
+}
+
+// nilChain unwinds an optional chain like a?.b.c from the access on nil
+// up to the end of the chain.
+type nilChain struct{}
+
+func (nilChain) Error() string {
+	return "nil optional chain"
+}
+
+// link evaluates the object of an access, which is part of the same
+// optional chain.
+func (i *Interpreter) link(expr Expr) (Literal, error) {
+	i.stats.evaluation()
 	err := expr.Accept(i)
 	return i.Literal, err
 }
@@ -72,7 +470,14 @@ func (i *Interpreter) visitAssign(a Assign) error {
 		return err
 	}
 
-	if err := i.Environment.Assign(a.Variable, l); err != nil {
+	i.stats.lookup()
+	if a.Variable.Slot.local() {
+		err = i.Environment.AssignAt(a.Variable, l)
+	} else {
+		err = i.globals().Assign(a.Variable, l)
+	}
+
+	if err != nil {
 		return err
 	}
 
@@ -94,23 +499,114 @@ func (i *Interpreter) visitBinary(b Binary) error {
 		return err
 	}
 
+	return i.binary(b.Operator, left, right)
+}
+
+// magic maps operators to the methods overloading them on instances.
+var magic = map[TokenType]string{
+	Plus:       "__add__",
+	Minus:      "__sub__",
+	Star:       "__mul__",
+	Slash:      "__div__",
+	Percent:    "__mod__",
+	EqualEqual: "__eq__",
+	NotEqual:   "__eq__",
+}
+
+// overload calls the method overloading operator on obj, if any.
+func (i *Interpreter) overload(operator Token, name string, obj *ClassInstance, arguments ...Expr) (bool, error) {
+	method, ok := obj.Class.method(name)
+	if !ok {
+		return false, nil
+	}
+
+	l, err := i.call(operator, Literal{method.bind(obj)}, arguments)
+	if err != nil {
+		return true, err
+	}
+
+	i.Literal = l
+
+	return true, nil
+}
+
+// binary applies a binary operator to already evaluated operands.
+func (i *Interpreter) binary(operator Token, left Literal, right Literal) error {
+	if obj, ok := left.Value.(*ClassInstance); ok {
+		if name, ok := magic[operator.TokenType]; ok {
+			if ok, err := i.overload(operator, name, obj, right); ok {
+				// != is the negation of __eq__
+				if err == nil && operator.TokenType == NotEqual {
+					i.Literal = Literal{!i.Literal.Bool()}
+				}
+
+				return err
+			}
+		}
+	}
+
 	invalidOperand := func(left interface{}, right interface{}) error {
-		return fmt.Errorf("error at line %d: invalid operands for binary %s: %T, %T", b.Operator.Line, b.Operator.Lexeme, left, right)
+		return errorAt(operator, "invalid operands for binary %s: %T, %T", operator.Lexeme, left, right)
+	}
+
+	divisionByZero := func() error {
+		return errorAt(operator, "division by zero")
+	}
+
+	// numbers are compared by value and strings lexicographically
+	compare := func(numbers func(l, r float64) bool, strings func(l, r string) bool) error {
+		if l, ok := left.Value.(float64); ok {
+			if r, ok := right.Value.(float64); ok {
+				i.Literal = Literal{numbers(l, r)}
+				return nil
+			}
+		} else if l, ok := left.Value.(string); ok {
+			if r, ok := right.Value.(string); ok {
+				i.Literal = Literal{strings(l, r)}
+				return nil
+			}
+		}
+
+		return invalidOperand(left.Value, right.Value)
 	}
 
-	switch b.Operator.TokenType {
+	switch operator.TokenType {
 	case Plus:
 		{
+			// strings are never coerced, "a" + 1 is an error and toString
+			// converts explicitly, only instances render themselves
 			if l, ok := left.Value.(float64); ok {
 				if r, ok := right.Value.(float64); ok {
 					// Sum of numbers
 					i.Literal = Literal{l + r}
+				} else {
+					return invalidOperand(left.Value, right.Value)
 				}
 			} else if l, ok := left.Value.(string); ok {
 				if r, ok := right.Value.(string); ok {
 					// String concatenation
 					i.Literal = Literal{l + r}
+				} else if _, ok := right.Value.(*ClassInstance); ok {
+					r, err := i.stringify(operator, right)
+					if err != nil {
+						return err
+					}
+
+					i.Literal = Literal{l + r}
+				} else {
+					return invalidOperand(left.Value, right.Value)
+				}
+			} else if _, ok := left.Value.(*ClassInstance); ok {
+				if _, ok := right.Value.(string); !ok {
+					return invalidOperand(left.Value, right.Value)
+				}
+
+				l, err := i.stringify(operator, left)
+				if err != nil {
+					return err
 				}
+
+				i.Literal = Literal{l + right.Value.(string)}
 			} else {
 				// Invalids operands
 				return invalidOperand(left.Value, right.Value)
@@ -121,6 +617,8 @@ func (i *Interpreter) visitBinary(b Binary) error {
 			if l, ok := left.Value.(float64); ok {
 				if r, ok := right.Value.(float64); ok {
 					i.Literal = Literal{l - r}
+				} else {
+					return invalidOperand(left.Value, right.Value)
 				}
 			} else {
 				return invalidOperand(left.Value, right.Value)
@@ -131,6 +629,28 @@ func (i *Interpreter) visitBinary(b Binary) error {
 			if l, ok := left.Value.(float64); ok {
 				if r, ok := right.Value.(float64); ok {
 					i.Literal = Literal{l * r}
+				} else {
+					return invalidOperand(left.Value, right.Value)
+				}
+			} else if l, ok := left.Value.(string); ok {
+				r, ok := right.Value.(float64)
+				if !ok {
+					return invalidOperand(left.Value, right.Value)
+				}
+
+				// String repetition
+				if r < 0 || r != math.Trunc(r) || math.IsInf(r, 0) {
+					return errorAt(operator, "string repetition count must be a non-negative integer, got %v", Literal{r})
+				}
+
+				if l != "" && r > float64(maxRepeatLength/len(l)) {
+					return errorAt(operator, "string repetition count %v is too large", Literal{r})
+				}
+
+				if l == "" {
+					i.Literal = Literal{""}
+				} else {
+					i.Literal = Literal{strings.Repeat(l, int(r))}
 				}
 			} else {
 				return invalidOperand(left.Value, right.Value)
@@ -140,229 +660,904 @@ func (i *Interpreter) visitBinary(b Binary) error {
 		{
 			if l, ok := left.Value.(float64); ok {
 				if r, ok := right.Value.(float64); ok {
+					if r == 0 {
+						return divisionByZero()
+					}
+
 					i.Literal = Literal{l / r}
+				} else {
+					return invalidOperand(left.Value, right.Value)
 				}
 			} else {
 				return invalidOperand(left.Value, right.Value)
 			}
 		}
-	case EqualEqual:
-		{
-			i.Literal = Literal{left.Value == right.Value}
-		}
-	case NotEqual:
-		{
-			i.Literal = Literal{left.Value != right.Value}
-		}
-	case Greater:
+	case SlashSlash:
 		{
 			if l, ok := left.Value.(float64); ok {
 				if r, ok := right.Value.(float64); ok {
-					i.Literal = Literal{l > r}
+					if r == 0 {
+						return divisionByZero()
+					}
+
+					// rounded towards negative infinity, -7 // 2 is -4
+					i.Literal = Literal{math.Floor(l / r)}
 				} else {
 					return invalidOperand(left.Value, right.Value)
 				}
+			} else {
+				return invalidOperand(left.Value, right.Value)
 			}
 		}
-	case GreaterEqual:
+	case Percent:
 		{
 			if l, ok := left.Value.(float64); ok {
 				if r, ok := right.Value.(float64); ok {
-					i.Literal = Literal{l >= r}
+					if r == 0 {
+						return divisionByZero()
+					}
+
+					// The result takes the sign of the dividend
+					i.Literal = Literal{math.Mod(l, r)}
 				} else {
 					return invalidOperand(left.Value, right.Value)
 				}
+			} else {
+				return invalidOperand(left.Value, right.Value)
 			}
 		}
-	case Less:
+	case StarStar:
 		{
 			if l, ok := left.Value.(float64); ok {
 				if r, ok := right.Value.(float64); ok {
-					i.Literal = Literal{l < r}
+					i.Literal = Literal{math.Pow(l, r)}
 				} else {
 					return invalidOperand(left.Value, right.Value)
 				}
+			} else {
+				return invalidOperand(left.Value, right.Value)
 			}
 		}
-	case LessEqual:
+	case EqualEqual:
 		{
-			if l, ok := left.Value.(float64); ok {
-				if r, ok := right.Value.(float64); ok {
-					i.Literal = Literal{l <= r}
-				} else {
-					return invalidOperand(left.Value, right.Value)
-				}
-			}
+			i.Literal = Literal{isEqual(left.Value, right.Value)}
 		}
+	case NotEqual:
+		{
+			i.Literal = Literal{!isEqual(left.Value, right.Value)}
+		}
+	case Greater:
+		{
+			return compare(func(l, r float64) bool { return l > r }, func(l, r string) bool { return l > r })
+		}
+	case GreaterEqual:
+		{
+			return compare(func(l, r float64) bool { return l >= r }, func(l, r string) bool { return l >= r })
+		}
+	case Less:
+		{
+			return compare(func(l, r float64) bool { return l < r }, func(l, r string) bool { return l < r })
+		}
+	case LessEqual:
+		{
+			return compare(func(l, r float64) bool { return l <= r }, func(l, r string) bool { return l <= r })
+		}
+	case Is:
+		{
+			class, ok := right.Value.(*ClassValue)
+			if !ok {
+				return errorAt(operator, "right operand of is must be a class, got %T", right.Value)
+			}
+
+			// values other than instances are not of any class
+			obj, ok := left.Value.(*ClassInstance)
+			i.Literal = Literal{ok && obj.Class.inherits(class)}
+		}
+	}
+
+	return nil
+}
+
+// isEqual reports whether two values are equal. Numbers, strings and
+// booleans are compared by value, lists, maps and functions by identity.
+func isEqual(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if t := reflect.TypeOf(a); t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+func (i *Interpreter) visitBlock(b Block) error {
+	previous := i.Environment
+	i.Environment = NewEnvironment(previous)
+
+	// restore the scope even when unwinding by break or return
+	defer func() { i.Environment = previous }()
+
+	for _, stmt := range b.Stmts {
+		if err := i.execute(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitBreakStmt(b BreakStmt) error {
+	return LoopBreak{b.Label.Lexeme}
+}
+
+func (i *Interpreter) visitCall(c Call) error {
+	var callee Literal
+	var err error
+
+	if g, ok := c.Callee.(Get); ok {
+		callee, err = i.property(g, true)
+	} else if s, ok := c.Callee.(SuperExpr); ok {
+		callee, err = i.super(s, true)
+	} else {
+		callee, err = i.link(c.Callee)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var arguments []Expr
+	for _, argument := range c.Arguments {
+		value, err := i.Evaluate(argument)
+		if err != nil {
+			return err
+		}
+
+		arguments = append(arguments, value)
+	}
+
+	l, err := i.call(c.Paren, callee, arguments)
+	if err != nil {
+		return err
+	}
+
+	i.Literal = l
+
+	return nil
+}
+
+// call calls callee with already evaluated arguments, paren locates the
+// call in the source.
+func (i *Interpreter) call(paren Token, callee Literal, arguments []Expr) (Literal, error) {
+	f, ok := callee.Value.(Callable)
+	if !ok {
+		return Literal{}, errorAt(paren, "can only call functions and classes, got %T", callee.Value)
+	}
+
+	switch fn := f.(type) {
+	case *Function:
+		{
+			if err := fn.checkArguments(paren, len(arguments)); err != nil {
+				return Literal{}, err
+			}
+		}
+	case *ClassValue:
+		{
+			if err := fn.checkArguments(paren, len(arguments)); err != nil {
+				return Literal{}, err
+			}
+		}
+	case *Native:
+		{
+			if err := fn.checkArguments(paren, len(arguments)); err != nil {
+				return Literal{}, err
+			}
+		}
+	default:
+		{
+			if f.Arity() != len(arguments) {
+				return Literal{}, errorAt(paren, "expected %d arguments but got %d", f.Arity(), len(arguments))
+			}
+		}
+	}
+
+	if len(i.frames) >= i.maxDepth() {
+		return Literal{}, errorAt(paren, "stack overflow")
+	}
+
+	i.stats.call()
+	i.frames = append(i.frames, Frame{Literal{f}.String(), paren.Line})
+	defer func() { i.frames = i.frames[:len(i.frames)-1] }()
+
+	n, native := f.(*Native)
+	if native {
+		site := i.site
+		i.site = paren
+		defer func() { i.site = site }()
+	}
+
+	l, err := f.Call(i, arguments)
+	if err != nil {
+		// natives know nothing about the source, locate their errors
+		if native {
+			switch e := err.(type) {
+			case *Error:
+				{
+					// raised calling back a callable at the site
+					err = errorAt(paren, "%s: %s", n.Name, e.Message)
+				}
+			case *RuntimeError, Thrown:
+				{
+					// raised by the code called back, already located
+				}
+			default:
+				{
+					err = errorAt(paren, "%s: %v", n.Name, err)
+				}
+			}
+		}
+
+		// the innermost call attaches the trace
+		if e, ok := err.(*Error); ok {
+			err = i.runtimeError(e)
+		}
+
+		return Literal{}, err
+	}
+
+	return l, nil
+}
+
+func (i *Interpreter) visitClassStmt(c ClassStmt) error {
+	var superclass *ClassValue
+	if c.Superclass != nil {
+		l, err := i.Evaluate(c.Superclass)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		if superclass, ok = l.Value.(*ClassValue); !ok {
+			return errorAt(c.Name, "superclass of %s must be a class, got %T", c.Name.Lexeme, l.Value)
+		}
+	}
+
+	// methods of a subclass close over a scope holding the superclass
+	enclosing := i.Environment
+	if superclass != nil {
+		i.Environment = NewEnvironment(enclosing)
+		i.Environment.Define(0, Literal{superclass})
+	}
+
+	class := &ClassValue{c.Name.Lexeme, superclass, i.methods(c.Methods), i.methods(c.Statics), c.Fields, i.Environment}
+	i.Environment = enclosing
+
+	return i.declare(c.Name, c.Slot, Literal{class})
+}
+
+// methods closes methods over the current scope.
+func (i *Interpreter) methods(methods []Function) map[string]*Function {
+	closed := make(map[string]*Function, len(methods))
+	for _, method := range methods {
+		m := method
+		m.Closure = i.Environment
+		closed[m.Name.Lexeme] = &m
+	}
+
+	return closed
+}
+
+func (i *Interpreter) visitContinueStmt(c ContinueStmt) error {
+	return LoopContinue{c.Label.Lexeme}
+}
+
+// compound combines the current value of an assignment target with the
+// assigned value for compound assignments like +=, it returns the value
+// untouched for plain assignments.
+func (i *Interpreter) compound(operator Token, current Literal, value Literal) (Literal, error) {
+	if operator.Lexeme == "" {
+		i.Literal = value
+		return value, nil
+	}
+
+	if err := i.binary(operator, current, value); err != nil {
+		return Literal{}, err
+	}
+
+	return i.Literal, nil
+}
+
+func (i *Interpreter) visitDeclaration(d Declaration) error {
+	i.Literal = Literal{nil}
+
+	if d.Expr != nil {
+		if _, err := i.Evaluate(d.Expr); err != nil {
+			return err
+		}
+	}
+
+	if err := i.declare(d.Token, d.Slot, i.Literal); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitDeferStmt(d DeferStmt) error {
+	i.deferred = append(i.deferred, deferral{d.Deferred, i.Environment})
+	return nil
+}
+
+// unwind runs the statements deferred since mark, the latest first, when
+// a function exits with err. The error of a failing deferred statement
+// replaces err, the others still run.
+func (i *Interpreter) unwind(mark int, err error) error {
+	scope := i.Environment
+	for len(i.deferred) > mark {
+		d := i.deferred[len(i.deferred)-1]
+		i.deferred = i.deferred[:len(i.deferred)-1]
+
+		i.Environment = d.Environment
+		if e := i.execute(d.Stmt); e != nil {
+			err = e
+		}
+	}
+	i.Environment = scope
+
+	return err
+}
+
+func (i *Interpreter) visitDestructuring(d Destructuring) error {
+	l, err := i.Evaluate(d.Expr)
+	if err != nil {
+		return err
+	}
+
+	list, ok := l.Value.(*ListValue)
+	if !ok {
+		return errorAt(d.Token, "can only destructure lists, got %s", typeName(l.Value))
+	}
+
+	if len(list.Elements) != len(d.Names) {
+		return errorAt(d.Token, "expected %d values but got %d", len(d.Names), len(list.Elements))
+	}
+
+	for j, name := range d.Names {
+		if err := i.declare(name, d.Slots[j], Literal{list.Elements[j]}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitEnumStmt(e EnumStmt) error {
+	members := make([]string, len(e.Members))
+	for j, m := range e.Members {
+		members[j] = m.Lexeme
+	}
+
+	return i.declare(e.Name, e.Slot, Literal{NewEnumValue(e.Name.Lexeme, members)})
+}
+
+func (i *Interpreter) visitExprStmt(e ExprStmt) error {
+	_, err := i.Evaluate(e.Expr)
+	return err
+}
+
+func (i *Interpreter) visitForIn(f ForIn) error {
+	label := i.loopLabel()
+
+	l, err := i.Evaluate(f.Iterable)
+	if err != nil {
+		return err
+	}
+
+	var next func(j int) (interface{}, bool)
+
+	switch v := l.Value.(type) {
+	case *ListValue:
+		{
+			// elements pushed by the body are visited too
+			next = func(j int) (interface{}, bool) {
+				if j < len(v.Elements) {
+					return v.Elements[j], true
+				}
+
+				return nil, false
+			}
+		}
+	case *MapValue:
+		{
+			keys := v.Keys()
+			next = func(j int) (interface{}, bool) {
+				if j < len(keys) {
+					return keys[j].Value, true
+				}
+
+				return nil, false
+			}
+		}
+	default:
+		{
+			return errorAt(f.Token, "cannot iterate over %T", l.Value)
+		}
+	}
+
+	previous := i.Environment
+	defer func() { i.Environment = previous }()
+
+	for j := 0; ; j++ {
+		element, ok := next(j)
+		if !ok {
+			return nil
+		}
+
+		i.Environment = NewEnvironment(previous)
+		i.defineLocal(0, f.Name.Lexeme, Literal{element})
+
+		if err := i.execute(f.Body); err != nil {
+			if stop, err := looped(err, label); stop {
+				return err
+			}
+		}
+	}
+}
+
+func (i *Interpreter) visitForStmt(f ForStmt) error {
+	label := i.loopLabel()
+
+	// the hook already stopped at the line of the loop
+	if f.Init != nil {
+		if err := f.Init.Accept(i); err != nil {
+			return err
+		}
+	}
+
+	for true {
+		if f.Condition != nil {
+			l, err := i.Evaluate(f.Condition)
+			if err != nil {
+				return err
+			}
+
+			if !l.Bool() {
+				return nil
+			}
+		}
+
+		if err := i.execute(f.Body); err != nil {
+			// continue still runs the increment clause
+			if stop, err := looped(err, label); stop {
+				return err
+			}
+		}
+
+		if f.Increment != nil {
+			if _, err := i.Evaluate(f.Increment); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitFunction(f Function) error {
+	f.Closure = i.Environment
+	i.stats.allocation()
+	if err := i.declare(f.Name, f.Slot, Literal{&f}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// visitImportStmt runs the imported statements, which are top-level so in
+// the global scope.
+func (i *Interpreter) visitImportStmt(s ImportStmt) error {
+	for _, stmt := range s.Stmts {
+		if err := i.execute(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitIfStmt(s IfStmt) error {
+	l, err := i.Evaluate(s.Condition)
+	if err != nil {
+		return err
+	}
+
+	if l.Bool() {
+		if err := i.execute(s.Then); err != nil {
+			return err
+		}
+	} else {
+		if s.Else != nil {
+			if err := i.execute(s.Else); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitMap(m Map) error {
+	i.stats.allocation()
+	value := NewMapValue()
+
+	for j := range m.Keys {
+		k, err := i.Evaluate(m.Keys[j])
+		if err != nil {
+			return err
+		}
+
+		key, err := NewMapKey(m.Brace, k.Value)
+		if err != nil {
+			return err
+		}
+
+		v, err := i.Evaluate(m.Values[j])
+		if err != nil {
+			return err
+		}
+
+		value.Set(key, v.Value)
+	}
+
+	i.Literal = Literal{value}
+
+	return nil
+}
+
+func (i *Interpreter) visitRecord(r Record) error {
+	i.stats.allocation()
+	value := NewRecordValue()
+
+	for j, name := range r.Names {
+		v, err := i.Evaluate(r.Values[j])
+		if err != nil {
+			return err
+		}
+
+		value.define(name.Lexeme, v.Value)
+	}
+
+	i.Literal = Literal{value}
+
+	return nil
+}
+
+func (i *Interpreter) visitPrintStmt(p PrintStmt) error {
+	expr, err := i.Evaluate(p.Expr)
+	if err != nil {
+		return err
+	}
+
+	s, err := i.stringify(p.Token, expr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(i.output(), s)
+
+	return nil
+}
+
+// stringify renders a value as print does, instances defining toString
+// are rendered by calling it, also within lists, maps and records.
+func (i *Interpreter) stringify(site Token, l Literal) (string, error) {
+	return i.render(site, l.Value, rendering{})
+}
+
+// repr renders a value as stringify does, quoting strings, like within a
+// collection or when echoed.
+func (i *Interpreter) repr(site Token, v interface{}) (string, error) {
+	return i.renderNested(site, v, rendering{})
+}
+
+// render renders v as stringify does, seen holds the collections being
+// rendered.
+func (i *Interpreter) render(site Token, v interface{}, seen rendering) (string, error) {
+	switch c := v.(type) {
+	case *ListValue:
+		{
+			if seen[c] {
+				return "[...]", nil
+			}
+
+			seen[c] = true
+			defer delete(seen, c)
+
+			elements := make([]string, len(c.Elements))
+			for j, e := range c.Elements {
+				s, err := i.renderNested(site, e, seen)
+				if err != nil {
+					return "", err
+				}
+
+				elements[j] = s
+			}
+
+			return "[" + strings.Join(elements, ", ") + "]", nil
+		}
+	case *MapValue:
+		{
+			if seen[c] {
+				return "{...}", nil
+			}
+
+			seen[c] = true
+			defer delete(seen, c)
+
+			entries := make([]string, len(c.keys))
+			for j, k := range c.keys {
+				key, err := i.renderNested(site, k.Value, seen)
+				if err != nil {
+					return "", err
+				}
+
+				value, err := i.renderNested(site, c.entries[k], seen)
+				if err != nil {
+					return "", err
+				}
+
+				entries[j] = key + ": " + value
+			}
+
+			return "{" + strings.Join(entries, ", ") + "}", nil
+		}
+	case *RecordValue:
+		{
+			if seen[c] {
+				return "{...}", nil
+			}
+
+			seen[c] = true
+			defer delete(seen, c)
+
+			fields := make([]string, len(c.names))
+			for j, name := range c.names {
+				value, err := i.renderNested(site, c.fields[name], seen)
+				if err != nil {
+					return "", err
+				}
+
+				fields[j] = name + ": " + value
+			}
+
+			return "{" + strings.Join(fields, ", ") + "}", nil
+		}
+	case *ClassInstance:
+		{
+			method, ok := c.Class.method("toString")
+			if !ok {
+				break
+			}
+
+			s, err := i.call(site, Literal{method.bind(c)}, nil)
+			if err != nil {
+				return "", err
+			}
+
+			if _, ok := s.Value.(string); !ok {
+				return "", errorAt(site, "toString must return a string, got %T", s.Value)
+			}
+
+			return s.String(), nil
+		}
+	}
+
+	return Literal{v}.String(), nil
+}
+
+// renderNested renders v as repr does.
+func (i *Interpreter) renderNested(site Token, v interface{}, seen rendering) (string, error) {
+	if s, ok := v.(string); ok {
+		return strconv.Quote(s), nil
+	}
+
+	return i.render(site, v, seen)
+}
+
+func (i *Interpreter) visitGet(g Get) error {
+	l, err := i.property(g, false)
+	if err != nil {
+		return err
+	}
+
+	i.Literal = l
+
+	return nil
+}
+
+// property returns a field or a bound method of an instance, or a static
+// method of a class, getters are invoked right away and cannot be called.
+func (i *Interpreter) property(g Get, called bool) (Literal, error) {
+	l, err := i.link(g.Object)
+	if err != nil {
+		return Literal{}, err
+	}
+
+	if l.Value == nil && g.Optional {
+		return Literal{}, nilChain{}
+	}
+
+	if class, ok := l.Value.(*ClassValue); ok {
+		if method, ok := class.static(g.Name.Lexeme); ok {
+			return Literal{method}, nil
+		}
+
+		return Literal{}, nil
+	}
+
+	if record, ok := l.Value.(*RecordValue); ok {
+		v, err := record.Get(g.Name)
+		return Literal{v}, err
+	}
+
+	if enum, ok := l.Value.(*EnumValue); ok {
+		v, err := enum.Get(g.Name)
+		return Literal{v}, err
+	}
+
+	obj, ok := l.Value.(*ClassInstance)
+	if !ok {
+		return Literal{}, errorAt(g.Name, "invalid property: %v", g.Name.Lexeme)
+	}
+
+	// fields shadow methods
+	if field, ok := obj.Fields[g.Name.Lexeme]; ok {
+		return field, nil
 	}
 
-	return nil
-}
+	method, ok := obj.Class.method(g.Name.Lexeme)
+	if !ok {
+		return Literal{}, nil
+	}
 
-func (i *Interpreter) visitBlock(b Block) error {
-	i.Environment = NewEnvironment(i.Environment)
+	if !method.Getter {
+		return Literal{method.bind(obj)}, nil
+	}
 
-	for _, stmt := range b.Stmts {
-		if err := stmt.Accept(i); err != nil {
-			return err
-		}
+	if called {
+		return Literal{}, errorAt(g.Name, "cannot call getter %s", g.Name.Lexeme)
 	}
 
-	i.Environment = i.Environment.Parent
+	return i.call(g.Name, Literal{method.bind(obj)}, nil)
+}
 
-	return nil
+// visitGrouping ends optional chains, (a?.b).c fails when a is nil.
+func (i *Interpreter) visitGrouping(g Grouping) error {
+	_, err := i.Evaluate(g.Expr)
+	return err
 }
 
-func (i *Interpreter) visitCall(c Call) error {
-	callee, err := i.Evaluate(c.Callee)
+func (i *Interpreter) visitIndex(x Index) error {
+	object, err := i.link(x.Object)
 	if err != nil {
 		return err
 	}
 
-	var arguments []Expr
-	for _, argument := range c.Arguments {
-		value, err := i.Evaluate(argument)
-		if err != nil {
-			return err
-		}
-
-		arguments = append(arguments, value)
+	index, err := i.Evaluate(x.Index)
+	if err != nil {
+		return err
 	}
 
-	if f, ok := callee.Value.(Callable); ok {
-		if f.Arity() != len(arguments) {
-			return fmt.Errorf("expected %d arguments but got %d", f.Arity(), len(arguments))
+	if list, ok := object.Value.(*ListValue); ok {
+		j, err := list.index(x.Bracket, index.Value)
+		if err != nil {
+			return err
 		}
 
-		l, err := f.Call(i, arguments)
+		i.Literal = Literal{list.Elements[j]}
+	} else if m, ok := object.Value.(*MapValue); ok {
+		key, err := NewMapKey(x.Bracket, index.Value)
 		if err != nil {
 			return err
 		}
 
-		i.Literal = l
+		// a missing key is not an error
+		v, _ := m.Get(key)
+		i.Literal = Literal{v}
+	} else {
+		return errorAt(x.Bracket, "cannot index %T", object.Value)
 	}
 
 	return nil
 }
 
-func (i *Interpreter) visitClassStmt(c ClassStmt) error {
-	return i.Environment.Declare(Variable{c.Name}, Literal{c})
-}
-
-func (i *Interpreter) visitDeclaration(d Declaration) error {
-	i.Literal = Literal{nil}
-
-	if d.Expr != nil {
-		if _, err := i.Evaluate(d.Expr); err != nil {
-			return err
-		}
+func (i *Interpreter) visitIndexSet(x IndexSet) error {
+	object, err := i.Evaluate(x.Object)
+	if err != nil {
+		return err
 	}
 
-	if err := i.Environment.Declare(Variable{d.Token}, i.Literal); err != nil {
+	index, err := i.Evaluate(x.Index)
+	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	if list, ok := object.Value.(*ListValue); ok {
+		j, err := list.index(x.Bracket, index.Value)
+		if err != nil {
+			return err
+		}
 
-func (i *Interpreter) visitExprStmt(e ExprStmt) error {
-	return e.Expr.Accept(i)
-}
+		l, err := i.Evaluate(x.Value)
+		if err != nil {
+			return err
+		}
 
-func (i *Interpreter) visitForStmt(f ForStmt) error {
-	if f.Init != nil {
-		if err := f.Init.Accept(i); err != nil {
+		if l, err = i.compound(x.Operator, Literal{list.Elements[j]}, l); err != nil {
 			return err
 		}
-	}
 
-	for true {
-		l, err := i.Evaluate(f.Condition)
+		list.Elements[j] = l.Value
+	} else if m, ok := object.Value.(*MapValue); ok {
+		key, err := NewMapKey(x.Bracket, index.Value)
 		if err != nil {
 			return err
 		}
 
-		if !l.Bool() {
-			return nil
-		}
+		current, _ := m.Get(key)
 
-		if err := f.Body.Accept(i); err != nil {
+		l, err := i.Evaluate(x.Value)
+		if err != nil {
 			return err
 		}
 
-		if err := f.Increment.Accept(i); err != nil {
+		if l, err = i.compound(x.Operator, Literal{current}, l); err != nil {
 			return err
 		}
+
+		m.Set(key, l.Value)
+	} else {
+		return errorAt(x.Bracket, "cannot index %T", object.Value)
 	}
 
 	return nil
 }
 
-func (i *Interpreter) visitFunction(f Function) error {
-	f.Closure = i.Environment
-	if err := i.Environment.Declare(Variable{f.Name}, Literal{f}); err != nil {
-		return err
-	}
+func (i *Interpreter) visitLambda(l Lambda) error {
+	// lambdas are functions without a name
+	i.stats.allocation()
+	i.Literal = Literal{l.function(i.Environment)}
 
 	return nil
 }
 
-func (i *Interpreter) visitIfStmt(s IfStmt) error {
-	l, err := i.Evaluate(s.Condition)
-	if err != nil {
-		return err
-	}
+func (i *Interpreter) visitList(l List) error {
+	elements := make([]interface{}, 0, len(l.Elements))
 
-	if l.Bool() {
-		if err := s.Then.Accept(i); err != nil {
+	for _, element := range l.Elements {
+		e, err := i.Evaluate(element)
+		if err != nil {
 			return err
 		}
-	} else {
-		if s.Else != nil {
-			if err := s.Else.Accept(i); err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
 
-func (i *Interpreter) visitPrintStmt(p PrintStmt) error {
-	expr, err := i.Evaluate(p.Expr)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(expr)
-
-	return nil
-}
-
-func (i *Interpreter) visitGet(g Get) error {
-	l, err := i.Evaluate(g.Object)
-	if err != nil {
-		return nil
+		elements = append(elements, e.Value)
 	}
 
-	if obj, ok := l.Value.(ClassInstance); ok {
-		i.Literal = obj.Get(g.Name)
-	} else {
-		return fmt.Errorf("error at line %d: invalid property: %v", g.Name.Line, g.Name.Lexeme)
-	}
+	i.stats.allocation()
+	i.Literal = Literal{&ListValue{elements}}
 
 	return nil
 }
 
-func (i *Interpreter) visitGrouping(g Grouping) error {
-	return g.Expr.Accept(i)
-}
-
 func (i *Interpreter) visitLiteral(l Literal) error {
 	i.Literal = l
 	return nil
 }
 
+// visitLogical evaluates to the operand deciding the result, the right one
+// is not evaluated if the left one is enough.
 func (i *Interpreter) visitLogical(l Logical) error {
 	left, err := i.Evaluate(l.Left)
 	if err != nil {
@@ -372,41 +1567,38 @@ func (i *Interpreter) visitLogical(l Logical) error {
 	switch l.Operator.TokenType {
 	case Or:
 		{
-			if !left.Bool() {
-				right, err := i.Evaluate(l.Right)
-				if err != nil {
-					return err
-				}
-
-				i.Literal = Literal{right.Bool()}
-			} else {
-				i.Literal = Literal{true}
+			if left.Bool() {
+				i.Literal = left
+				return nil
 			}
-
-			break
 		}
 	case And:
 		{
-			if left.Bool() {
-				right, err := i.Evaluate(l.Right)
-				if err != nil {
-					return err
-				}
-
-				i.Literal = Literal{right.Bool()}
-			} else {
-				i.Literal = Literal{false}
+			if !left.Bool() {
+				i.Literal = left
+				return nil
+			}
+		}
+	case QuestionQuestion:
+		{
+			// only nil falls back, unlike or
+			if left.Value != nil {
+				i.Literal = left
+				return nil
 			}
-
-			break
 		}
 	}
 
-	return nil
+	_, err = i.Evaluate(l.Right)
+	return err
 }
 
 func (i *Interpreter) visitReturnStmt(r ReturnStmt) error {
-	if err := r.Expr.Accept(i); err != nil {
+	if r.Expr == nil {
+		return ReturnValue{Literal{}}
+	}
+
+	if _, err := i.Evaluate(r.Expr); err != nil {
 		return err
 	} else {
 		return ReturnValue{i.Literal}
@@ -416,28 +1608,141 @@ func (i *Interpreter) visitReturnStmt(r ReturnStmt) error {
 func (i *Interpreter) visitSet(s Set) error {
 	l, err := i.Evaluate(s.Object)
 	if err != nil {
-		return nil
+		return err
 	}
 
-	if obj, ok := l.Value.(ClassInstance); ok {
+	if obj, ok := l.Value.(*ClassInstance); ok {
+		current := obj.Get(s.Name)
+
 		l, err := i.Evaluate(s.Value)
 		if err != nil {
 			return err
 		}
 
+		if l, err = i.compound(s.Operator, current, l); err != nil {
+			return err
+		}
+
 		obj.Set(s.Name, l)
+	} else if record, ok := l.Value.(*RecordValue); ok {
+		current, err := record.Get(s.Name)
+		if err != nil {
+			return err
+		}
+
+		l, err := i.Evaluate(s.Value)
+		if err != nil {
+			return err
+		}
+
+		if l, err = i.compound(s.Operator, Literal{current}, l); err != nil {
+			return err
+		}
+
+		return record.Set(s.Name, l.Value)
+	} else if enum, ok := l.Value.(*EnumValue); ok {
+		if _, err := enum.Get(s.Name); err != nil {
+			return err
+		}
+
+		return errorAt(s.Name, "cannot assign to member %s of enum %s", s.Name.Lexeme, enum.Name)
+	} else {
+		return errorAt(s.Name, "only instances and records have fields")
+	}
+
+	return nil
+}
+
+func (i *Interpreter) visitSuperExpr(s SuperExpr) error {
+	l, err := i.super(s, false)
+	if err != nil {
+		return err
+	}
+
+	i.Literal = l
+
+	return nil
+}
+
+// super returns a method of the superclass bound to the current instance,
+// getters are treated as in property.
+func (i *Interpreter) super(s SuperExpr, called bool) (Literal, error) {
+	superclass, err := i.Evaluate(Variable{s.Token, s.Slot})
+	if err != nil {
+		return Literal{}, err
+	}
+
+	this, err := i.Evaluate(Variable{s.Token, s.This})
+	if err != nil {
+		return Literal{}, err
+	}
+
+	obj := this.Value.(*ClassInstance)
+
+	method, ok := superclass.Value.(*ClassValue).method(s.Method.Lexeme)
+	if !ok {
+		return Literal{}, errorAt(s.Method, "undefined method %s of superclass", s.Method.Lexeme)
+	}
+
+	if !method.Getter {
+		return Literal{method.bind(obj)}, nil
+	}
+
+	if called {
+		return Literal{}, errorAt(s.Method, "cannot call getter %s", s.Method.Lexeme)
+	}
+
+	return i.call(s.Method, Literal{method.bind(obj)}, nil)
+}
+
+func (i *Interpreter) visitSwitchStmt(s SwitchStmt) error {
+	discriminant, err := i.Evaluate(s.Discriminant)
+	if err != nil {
+		return err
+	}
+
+	// cases never fall through
+	for _, c := range s.Cases {
+		l, err := i.Evaluate(c.Value)
+		if err != nil {
+			return err
+		}
+
+		if isEqual(discriminant.Value, l.Value) {
+			return i.execute(c.Body)
+		}
+	}
+
+	if s.Default != nil {
+		return i.execute(s.Default)
 	}
 
 	return nil
 }
 
+func (i *Interpreter) visitTernary(t Ternary) error {
+	l, err := i.Evaluate(t.Condition)
+	if err != nil {
+		return err
+	}
+
+	// only the taken branch is evaluated
+	if l.Bool() {
+		_, err = i.Evaluate(t.Then)
+	} else {
+		_, err = i.Evaluate(t.Else)
+	}
+
+	return err
+}
+
 func (i *Interpreter) visitUnary(u Unary) error {
 	if _, err := i.Evaluate(u.Right); err != nil {
 		return err
 	}
 
 	invalidOperand := func(operand interface{}) error {
-		return fmt.Errorf("error at line %d: bad operand for unary %s: %T", u.Operator.Line, u.Operator.Lexeme, operand)
+		return errorAt(u.Operator, "bad operand for unary %s: %T", u.Operator.Lexeme, operand)
 	}
 
 	switch u.Operator.TokenType {
@@ -449,6 +1754,12 @@ func (i *Interpreter) visitUnary(u Unary) error {
 		{
 			if f, ok := i.Literal.Value.(float64); ok {
 				i.Literal = Literal{-f}
+			} else if obj, ok := i.Literal.Value.(*ClassInstance); ok {
+				if ok, err := i.overload(u.Operator, "__neg__", obj); ok {
+					return err
+				}
+
+				return invalidOperand(obj)
 			} else {
 				return invalidOperand(i.Literal.Value)
 			}
@@ -459,9 +1770,16 @@ func (i *Interpreter) visitUnary(u Unary) error {
 }
 
 func (i *Interpreter) visitVariable(v Variable) error {
-	distance, _ := i.Locals[fmt.Sprintf("%v%v", v.Lexeme, v.Line)]
+	var e interface{}
+	var err error
+
+	i.stats.lookup()
+	if v.Slot.local() {
+		e, err = i.Environment.GetAt(v)
+	} else {
+		e, err = i.globals().Get(v)
+	}
 
-	e, err := i.Environment.Get(v, distance)
 	if err != nil {
 		return err
 	}
@@ -473,7 +1791,112 @@ func (i *Interpreter) visitVariable(v Variable) error {
 	return nil
 }
 
+func (i *Interpreter) visitThisExpr(t ThisExpr) error {
+	return i.visitVariable(Variable{t.Token, t.Slot})
+}
+
+func (i *Interpreter) visitThrowStmt(t ThrowStmt) error {
+	l, err := i.Evaluate(t.Expr)
+	if err != nil {
+		return err
+	}
+
+	return Thrown{t.Token, l}
+}
+
+func (i *Interpreter) visitTryStmt(t TryStmt) error {
+	err := i.execute(t.Body)
+	if err == nil {
+		return nil
+	}
+
+	var caught Literal
+
+	switch e := err.(type) {
+	case Thrown:
+		{
+			caught = e.Literal
+		}
+	case *Error:
+		{
+			caught = Literal{caughtError(e.Message, e.Line)}
+		}
+	case *RuntimeError:
+		{
+			caught = Literal{caughtError(e.Message, e.Line)}
+		}
+	default:
+		{
+			// return, break and continue are not exceptions
+			return err
+		}
+	}
+
+	previous := i.Environment
+	i.Environment = NewEnvironment(previous)
+
+	defer func() { i.Environment = previous }()
+
+	// the caught value is the only variable of its scope
+	i.defineLocal(0, t.Name.Lexeme, caught)
+
+	return i.execute(t.Catch)
+}
+
+// caughtError returns the value a runtime error is caught as, a map of
+// the form {"message": ..., "line": ...}.
+func caughtError(message string, line int) *MapValue {
+	m := NewMapValue()
+	m.Set(MapKey{"message"}, message)
+	m.Set(MapKey{"line"}, float64(line))
+
+	return m
+}
+
+// visitDoWhile runs the body before checking the condition, continue
+// skips to the check.
+func (i *Interpreter) visitDoWhile(d DoWhile) error {
+	label := i.loopLabel()
+
+	for true {
+		if err := i.execute(d.Body); err != nil {
+			if stop, err := looped(err, label); stop {
+				return err
+			}
+		}
+
+		l, err := i.Evaluate(d.Condition)
+		if err != nil {
+			return err
+		}
+
+		if !l.Bool() {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// visitLabeled runs a loop knowing its label, the hook already stopped at
+// the line of the label.
+func (i *Interpreter) visitLabeled(l Labeled) error {
+	i.label = l.Label.Lexeme
+	return l.Loop.Accept(i)
+}
+
+// loopLabel takes the label of the loop starting to run, it must be called
+// before running anything else.
+func (i *Interpreter) loopLabel() string {
+	label := i.label
+	i.label = ""
+
+	return label
+}
+
 func (i *Interpreter) visitWhileStmt(w WhileStmt) error {
+	label := i.loopLabel()
+
 	for true {
 		l, err := i.Evaluate(w.Condition)
 		if err != nil {
@@ -484,8 +1907,10 @@ func (i *Interpreter) visitWhileStmt(w WhileStmt) error {
 			return nil
 		}
 
-		if err := w.Body.Accept(i); err != nil {
-			return err
+		if err := i.execute(w.Body); err != nil {
+			if stop, err := looped(err, label); stop {
+				return err
+			}
 		}
 	}
 
@@ -0,0 +1,2264 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// evaluate scans, parses and evaluates a single expression.
+func evaluate(source string) (Literal, error) {
+	scanner := Scanner{source}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		return Literal{}, err
+	}
+
+	parser := Parser{Tokens: tokens}
+	expr, err := parser.expression()
+	if err != nil {
+		return Literal{}, err
+	}
+
+	i := Interpreter{Environment: NewEnvironment(nil)}
+
+	return i.Evaluate(expr)
+}
+
+// run scans, parses and runs a program, returning the interpreter so
+// that tests can inspect the global scope.
+func run(source string) (*Interpreter, error) {
+	i := &Interpreter{}
+	return i, exec(i, source)
+}
+
+// exec scans, parses and runs a program with the given interpreter.
+func exec(i *Interpreter, source string) error {
+	scanner := Scanner{source}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		return err
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+
+	return i.Run(stmts)
+}
+
+// output runs a program with the standard library and returns what it
+// printed.
+func output(source string) (string, error) {
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+
+	err := exec(i, source)
+
+	return b.String(), err
+}
+
+// global returns the value bound to name in the global scope.
+func global(i *Interpreter, name string) interface{} {
+	e, err := i.Globals.Get(Variable{Token{Lexeme: name}, nil})
+	if err != nil {
+		return err
+	}
+
+	return e.(Literal).Value
+}
+
+func TestInterpreter_Modulo(t *testing.T) {
+	table := []struct {
+		in  string
+		out interface{}
+	}{
+		{"7 % 3", 1.0},
+		{"6 % 3", 0.0},
+		{"-7 % 3", -1.0},
+		{"7 % -3", 1.0},
+		{"7.5 % 2", 1.5},
+		{"1 + 7 % 3 * 2", 3.0},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			l, err := evaluate(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if l.Value != test.out {
+				t.Errorf("want %v, got %v", test.out, l.Value)
+			}
+		})
+	}
+}
+
+func TestInterpreter_ModuloByZero(t *testing.T) {
+	for _, in := range []string{"7 % 0", "7 / 0"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := evaluate(in); err == nil {
+				t.Errorf("want division by zero error, got nil")
+			}
+		})
+	}
+}
+
+func TestInterpreter_Break(t *testing.T) {
+	table := []struct {
+		in  string
+		out interface{}
+	}{
+		{`var x = 0;
+		while (true) {
+			x = x + 1;
+			if (x == 3) break;
+		}`, 3.0},
+		{`var x = 0;
+		for (var i = 0; i < 10; i = i + 1) {
+			if (i == 5) {
+				break;
+			}
+			x = i;
+		}`, 4.0},
+		{`var x = 0;
+		while (x < 3) {
+			x = x + 1;
+			while (true) {
+				break;
+			}
+		}`, 3.0},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i, err := run(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if x := global(i, "x"); x != test.out {
+				t.Errorf("want %v, got %v", test.out, x)
+			}
+		})
+	}
+}
+
+func TestInterpreter_Continue(t *testing.T) {
+	table := []struct {
+		in  string
+		out interface{}
+	}{
+		{`var x = 0;
+		var i = 0;
+		while (i < 10) {
+			i = i + 1;
+			if (i % 2 == 0) continue;
+			x = x + i;
+		}`, 25.0},
+		{`var x = 0;
+		for (var i = 0; i < 10; i = i + 1) {
+			if (i % 2 == 0) {
+				continue;
+			}
+			x = x + i;
+		}`, 25.0},
+		{`var x = 0;
+		for (var i = 0; i < 5; i = i + 1) {
+			continue;
+			x = x + 1;
+		}
+		x = i;`, 5.0},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i, err := run(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if x := global(i, "x"); x != test.out {
+				t.Errorf("want %v, got %v", test.out, x)
+			}
+		})
+	}
+}
+
+func TestInterpreter_Ternary(t *testing.T) {
+	table := []struct {
+		in  string
+		out interface{}
+	}{
+		{"true ? 1 : 2", 1.0},
+		{"false ? 1 : 2", 2.0},
+		{"nil ? 1 : 2", 2.0},
+		{"1 < 2 ? \"yes\" : \"no\"", "yes"},
+		{"false ? 1 : true ? 2 : 3", 2.0},
+		{"true ? false ? 1 : 2 : 3", 2.0},
+		{"true ? 1 : 1 / 0", 1.0},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			l, err := evaluate(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if l.Value != test.out {
+				t.Errorf("want %v, got %v", test.out, l.Value)
+			}
+		})
+	}
+}
+
+func TestInterpreter_TernaryUntakenBranch(t *testing.T) {
+	i, err := run(`var x = 0;
+	var y = true ? 1 : (x = 1);
+	var z = false ? (x = 2) : 2;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if x := global(i, "x"); x != 0.0 {
+		t.Errorf("untaken branch was evaluated: x = %v", x)
+	}
+
+	if y, z := global(i, "y"), global(i, "z"); y != 1.0 || z != 2.0 {
+		t.Errorf("want 1 and 2, got %v and %v", y, z)
+	}
+}
+
+func TestInterpreter_List(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`var x = [];`, "[]"},
+		{`var x = [1, "two", nil, true];`, `[1, "two", nil, true]`},
+		{`var x = [1, 2, 3][1];`, "2"},
+		{`var x = [[1, 2], [3, [4, 5]]][1][1][0];`, "4"},
+		{`var x = [1, 2, 3];
+		x[0] = 10;`, "[10, 2, 3]"},
+		{`var x = [[1], [2]];
+		x[1][0] = 3;`, "[[1], [3]]"},
+		{`var x = [1, 2];
+		var y = x;
+		y[1] = 3;`, "[1, 3]"},
+		{`var x = [1, 2];
+		x = x[0] = 5;`, "5"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i, err := run(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if x := (Literal{global(i, "x")}).String(); x != test.out {
+				t.Errorf("want %v, got %v", test.out, x)
+			}
+		})
+	}
+}
+
+func TestInterpreter_ListIndexError(t *testing.T) {
+	table := []string{
+		"[1, 2][-1]",
+		"[1, 2][2]",
+		"[][0]",
+		"[1, 2][0.5]",
+		"[1, 2][\"0\"]",
+		"1[0]",
+	}
+
+	for _, in := range table {
+		t.Run(in, func(t *testing.T) {
+			if _, err := evaluate(in); err == nil {
+				t.Errorf("want error, got nil")
+			}
+		})
+	}
+}
+
+func TestInterpreter_Map(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`var x = {};`, "{}"},
+		{`var x = {"a": 1, "b": [2]};`, `{"a": 1, "b": [2]}`},
+		{`var x = {"a": 1, "b": 2}["b"];`, "2"},
+		{`var x = {"a": 1}["z"];`, "nil"},
+		{`var x = {1: "number", "1": "string"};`, `{1: "number", "1": "string"}`},
+		{`var x = {1: "number", "1": "string"}[1];`, "number"},
+		{`var x = {"a": 1};
+		x["b"] = 2;
+		x["a"] = 3;`, `{"a": 3, "b": 2}`},
+		{`var x = {"a": {"b": 1}};
+		x["a"]["b"] = 2;`, `{"a": {"b": 2}}`},
+		{`var x = {0: "zero"}[-0];`, "zero"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i, err := run(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if x := (Literal{global(i, "x")}).String(); x != test.out {
+				t.Errorf("want %v, got %v", test.out, x)
+			}
+		})
+	}
+}
+
+func TestInterpreter_MapKeyError(t *testing.T) {
+	table := []string{
+		"{nil: 1}",
+		"{true: 1}",
+		"{[]: 1}",
+		"{\"a\": 1}[nil]",
+	}
+
+	for _, in := range table {
+		t.Run(in, func(t *testing.T) {
+			if _, err := evaluate(in); err == nil {
+				t.Errorf("want error, got nil")
+			}
+		})
+	}
+}
+
+func TestInterpreter_Lambda(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`var add = fun(a, b) { return a + b; };
+		var x = add(1, 2);`, "3"},
+		{`fun apply(f, v) { return f(v); }
+		var x = apply(fun(n) { return n * 2; }, 21);`, "42"},
+		{`var x = fun() { return "called"; }();`, "called"},
+		{`var x = fun() {};`, "<fn>"},
+		{`fun makeCounter() {
+			var i = 0;
+			return fun() {
+				i = i + 1;
+				return i;
+			};
+		}
+		var counter = makeCounter();
+		counter();
+		var x = counter();`, "2"},
+		{`fun adder(a) {
+			return fun(b) {
+				return a + b;
+			};
+		}
+		var x = adder(1)(2);`, "3"},
+		{`fun fib(n) {
+			if (n < 2) return n;
+			return fib(n - 1) + fib(n - 2);
+		}
+		var x = fib(10);`, "55"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i, err := run(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if x := (Literal{global(i, "x")}).String(); x != test.out {
+				t.Errorf("want %v, got %v", test.out, x)
+			}
+		})
+	}
+}
+
+func TestInterpreter_CompoundAssignment(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`var x = 1;
+		x += 2;`, "3"},
+		{`var x = 10;
+		x -= 2;
+		x *= 3;
+		x /= 4;`, "6"},
+		{`var x = "a";
+		x += "b";`, "ab"},
+		{`var x = 1;
+		var y = x += 1;
+		x = y;`, "2"},
+		{`class Point {}
+		var p = Point();
+		p.x = 1;
+		p.x += 41;
+		var x = p.x;`, "42"},
+		{`var x = [1, 2];
+		x[1] *= 5;`, "[1, 10]"},
+		{`var x = {"a": 1};
+		x["a"] -= 1;`, `{"a": 0}`},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i, err := run(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if x := (Literal{global(i, "x")}).String(); x != test.out {
+				t.Errorf("want %v, got %v", test.out, x)
+			}
+		})
+	}
+}
+
+func TestInterpreter_CompoundAssignmentSingleEvaluation(t *testing.T) {
+	i, err := run(`class Box {}
+	var box = Box();
+	box.value = 1;
+	var list = [1];
+	var x = 0;
+	fun target() {
+		x += 1;
+		return box;
+	}
+	fun index() {
+		x += 1;
+		return 0;
+	}
+	target().value += 1;
+	list[index()] += 1;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if x := global(i, "x"); x != 2.0 {
+		t.Errorf("want targets evaluated once (2), got %v", x)
+	}
+}
+
+func TestInterpreter_CompoundAssignmentError(t *testing.T) {
+	for _, in := range []string{"var x = 1; x += \"a\";", "1 += 2;", "var x = nil; x.y += 1;"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := run(in); err == nil {
+				t.Errorf("want error, got nil")
+			}
+		})
+	}
+}
+
+func TestInterpreter_ErrorPosition(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"var x = 1;\nvar y = x +   \"a\";", "error at line 2, col 11: invalid operands for binary +: float64, string"},
+		{"print   undefined;", "error at line 1, col 9: undefined variable undefined"},
+		{"fun f(a) {}\n  f();", "error at line 2, col 4: expected 1 arguments but got 0"},
+		{"var x = ;", "error at line 1, col 9: unknown token ';'"},
+		{"var x = 1\nprint x;", "error at line 2, col 1: expected 'SEMICOLON'"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := run(test.in)
+			if err == nil || err.Error() != test.out {
+				t.Errorf("want %q, got %v", test.out, err)
+			}
+		})
+	}
+}
+
+func TestInterpreter_SetOutput(t *testing.T) {
+	out, err := output(`print "hi";
+	print 1 + 2;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out != "hi\n3\n" {
+		t.Errorf("want %q, got %q", "hi\n3\n", out)
+	}
+}
+
+func TestInterpreter_Report(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	i := &Interpreter{}
+	i.SetOutput(&stdout)
+	i.SetErrorOutput(&stderr)
+
+	i.Report(errors.New("something failed"))
+
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output: %q", stdout.String())
+	}
+
+	if stderr.String() != "something failed\n" {
+		t.Errorf("want %q, got %q", "something failed\n", stderr.String())
+	}
+}
+
+func TestInterpreter_Power(t *testing.T) {
+	table := []struct {
+		in   string
+		out  interface{}
+		lisp string
+	}{
+		{"2 ** 10", 1024.0, "(** 2 10)"},
+		{"2 ** 3 ** 2", 512.0, "(** 2 (** 3 2))"},
+		{"(2 ** 3) ** 2", 64.0, "(** (group (** 2 3)) 2)"},
+		{"-2 ** 2", -4.0, "(- (** 2 2))"},
+		{"2 ** -1", 0.5, "(** 2 (- 1))"},
+		{"2 * 3 ** 2", 18.0, "(* 2 (** 3 2))"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			tokens, _ := (&Scanner{test.in}).Scan()
+			expr, err := (&Parser{Tokens: tokens}).expression()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if lisp := PrintExpr(expr); lisp != test.lisp {
+				t.Errorf("want %v, got %v", test.lisp, lisp)
+			}
+
+			l, err := evaluate(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if l.Value != test.out {
+				t.Errorf("want %v, got %v", test.out, l.Value)
+			}
+		})
+	}
+}
+
+func TestInterpreter_PowerError(t *testing.T) {
+	for _, in := range []string{"\"a\" ** 2", "2 ** nil"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := evaluate(in); err == nil {
+				t.Errorf("want error, got nil")
+			}
+		})
+	}
+}
+
+func TestInterpreter_Switch(t *testing.T) {
+	program := `fun describe(x) {
+		var result = "none";
+		switch (x) {
+			case 1:
+				result = "one";
+			case 1 + 1:
+				var two = "two";
+				result = two;
+			case "three":
+				result = "three";
+			default:
+				result = "other";
+		}
+		return result;
+	}
+	fun noDefault(x) {
+		var result = "untouched";
+		switch (x) {
+			case 1:
+				result = "one";
+		}
+		return result;
+	}
+	`
+
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"describe(1)", "one"},
+		{"describe(2)", "two"},
+		{"describe(\"three\")", "three"},
+		{"describe(4)", "other"},
+		{"describe(nil)", "other"},
+		{"noDefault(1)", "one"},
+		{"noDefault(2)", "untouched"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(program + "print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestInterpreter_SwitchError(t *testing.T) {
+	table := []string{
+		"switch (1) { case 1: print 1; default: print 2; default: print 3; }",
+		"switch (1) { print 1; }",
+		"switch (1) { case 1 print 1; }",
+	}
+
+	for _, in := range table {
+		t.Run(in, func(t *testing.T) {
+			if _, err := run(in); err == nil {
+				t.Errorf("want error, got nil")
+			}
+		})
+	}
+}
+
+func TestInterpreter_Equality(t *testing.T) {
+	out, err := output(`fun f() {}
+	var g = f;
+	var l = [1];
+	print f == g;
+	print f == fun() {};
+	print l == l;
+	print l == [1];
+	print 1 == "1";
+	print nil == false;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "true\nfalse\ntrue\nfalse\nfalse\nfalse\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestInterpreter_Comparison(t *testing.T) {
+	table := []struct {
+		in  string
+		out bool
+	}{
+		{"1 < 2", true},
+		{"2 <= 2", true},
+		{"3 > 4", false},
+		{"4 >= 5", false},
+		{`"apple" < "banana"`, true},
+		{`"apple" > "banana"`, false},
+		{`"apple" <= "apple"`, true},
+		{`"apple" >= "apples"`, false},
+		{`"Zebra" < "apple"`, true},
+		{`"" < "a"`, true},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			l, err := evaluate(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if l.Value != test.out {
+				t.Errorf("want %v, got %v", test.out, l.Value)
+			}
+		})
+	}
+}
+
+func TestInterpreter_ComparisonError(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`"a" < 1`, "error at line 1, col 5: invalid operands for binary <: string, float64"},
+		{`1 >= "a"`, "error at line 1, col 3: invalid operands for binary >=: float64, string"},
+		{`nil > 1`, "error at line 1, col 5: invalid operands for binary >: <nil>, float64"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := evaluate(test.in)
+			if err == nil || err.Error() != test.out {
+				t.Errorf("want %q, got %v", test.out, err)
+			}
+		})
+	}
+}
+
+func TestInterpreter_StackOverflow(t *testing.T) {
+	i := &Interpreter{}
+
+	err := exec(i, `fun forever(n) {
+		return forever(n + 1);
+	}
+	forever(0);`)
+	if want := "error at line 2, col 17: stack overflow"; err == nil || err.Error() != want {
+		t.Fatalf("want %q, got %v", want, err)
+	}
+
+	// the interpreter is still usable after the overflow
+	if err := exec(i, "fun f() { return 1; } var x = f();"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestInterpreter_MaxDepth(t *testing.T) {
+	program := `fun depth(n) {
+		if (n == 0) return 0;
+		return depth(n - 1);
+	}
+	`
+
+	i := &Interpreter{MaxDepth: 10}
+
+	if err := exec(i, program+"depth(9);"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := exec(i, program+"depth(10);"); err == nil {
+		t.Errorf("want stack overflow, got nil")
+	}
+}
+
+func TestInterpreter_NotCallable(t *testing.T) {
+	_, err := run(`var x = "text";
+	x();`)
+	if want := "error at line 2, col 3: can only call functions and classes, got string"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestInterpreter_Try(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`try { throw "boom"; } catch (e) { print e; }`, "boom\n"},
+		{`try { print 1; } catch (e) { print e; } print 2;`, "1\n2\n"},
+		{`try { var x = 1 / 0; } catch (e) { print e["message"]; print e["line"]; }`, "division by zero\n1\n"},
+		{`fun f() { throw 42; } try { f(); print "unreachable"; } catch (e) { print e; }`, "42\n"},
+		{`try { try { throw 1; } catch (e) { throw e + 1; } } catch (e) { print e; }`, "2\n"},
+		{`var e = "outer"; try { throw "inner"; } catch (e) { print e; } print e;`, "inner\nouter\n"},
+		{`fun f() { try { return 1; } catch (e) { return 2; } } print f();`, "1\n"},
+		{`for (var i = 0; i < 3; i = i + 1) { try { break; } catch (e) { print e; } } print "done";`, "done\n"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestInterpreter_UncaughtThrow(t *testing.T) {
+	_, err := run(`fun f() {
+		throw "boom";
+	}
+	f();`)
+
+	thrown, ok := err.(Thrown)
+	if !ok {
+		t.Fatalf("want Thrown, got %T (%v)", err, err)
+	}
+
+	if thrown.Value != "boom" {
+		t.Errorf("want %q, got %v", "boom", thrown.Value)
+	}
+
+	if want := "error at line 2, col 3: uncaught boom"; err.Error() != want {
+		t.Errorf("want %q, got %q", want, err.Error())
+	}
+}
+
+func TestInterpreter_Variadic(t *testing.T) {
+	program := `fun sum(...nums) {
+		var total = 0;
+		for (var i = 0; i < len(nums); i = i + 1) total = total + nums[i];
+		return total;
+	}
+	fun tail(head, ...rest) {
+		return rest;
+	}
+	var count = fun (...xs) { return len(xs); };
+	`
+
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"print sum();", "0\n"},
+		{"print sum(1, 2, 3);", "6\n"},
+		{"print tail(1);", "[]\n"},
+		{"print tail(1, 2, \"three\");", "[2, \"three\"]\n"},
+		{"print count(nil, nil);", "2\n"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(program + test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestInterpreter_VariadicError(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"fun f(...a, b) {}", "error at line 1, col 10: variadic parameter must be the last one"},
+		{"fun f(a, ...b) {} f();", "error at line 1, col 20: expected at least 1 arguments but got 0"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			if _, err := run(test.in); err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestInterpreter_DefaultArguments(t *testing.T) {
+	program := `fun greet(name, greeting = "hello") {
+		return greeting + " " + name;
+	}
+	var calls = 0;
+	fun next() {
+		calls = calls + 1;
+		return calls;
+	}
+	fun counter(n = next()) {
+		return n;
+	}
+	var prefix = "outer";
+	fun scoped(x = prefix) {
+		var prefix = "inner";
+		return x;
+	}
+	fun rest(a, b = 2, ...more) {
+		return [a, b, more];
+	}
+	`
+
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"print greet(\"bob\");", "hello bob\n"},
+		{"print greet(\"bob\", \"hi\");", "hi bob\n"},
+		{"print counter(); print counter(); print counter(10); print calls;", "1\n2\n10\n2\n"},
+		{"print scoped();", "outer\n"},
+		{"prefix = \"changed\"; print scoped();", "changed\n"},
+		{"print rest(1);", "[1, 2, []]\n"},
+		{"print rest(1, 3, 4, 5);", "[1, 3, [4, 5]]\n"},
+		{"print (fun (x = 1) { return x; })();", "1\n"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(program + test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestInterpreter_DefaultArgumentsError(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"fun f(a = 1, b) {}", "error at line 1, col 14: required parameter b follows a parameter with a default value"},
+		{"fun f(a, b = 1) {} f();", "error at line 1, col 21: expected 1 to 2 arguments but got 0"},
+		{"fun f(a, b = 1) {} f(1, 2, 3);", "error at line 1, col 21: expected 1 to 2 arguments but got 3"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			if _, err := run(test.in); err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestInterpreter_StackTrace(t *testing.T) {
+	_, err := run(`fun outer() {
+		middle();
+	}
+	fun middle() {
+		inner();
+	}
+	fun inner() {
+		return 1 / 0;
+	}
+	outer();`)
+
+	e, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("want *RuntimeError, got %T (%v)", err, err)
+	}
+
+	if want := "error at line 8, col 12: division by zero"; e.Error() != want {
+		t.Errorf("want %q, got %q", want, e.Error())
+	}
+
+	trace := []Frame{{"<fn inner>", 5}, {"<fn middle>", 2}, {"<fn outer>", 10}}
+	if !reflect.DeepEqual(e.Trace, trace) {
+		t.Errorf("want %v, got %v", trace, e.Trace)
+	}
+
+	stack := "  at <fn inner>, line 5\n  at <fn middle>, line 2\n  at <fn outer>, line 10"
+	if e.Stack() != stack {
+		t.Errorf("want %q, got %q", stack, e.Stack())
+	}
+}
+
+func TestInterpreter_StackTraceTopLevel(t *testing.T) {
+	_, err := run("var x = 1 / 0;")
+
+	e, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("want *RuntimeError, got %T (%v)", err, err)
+	}
+
+	if len(e.Trace) != 0 {
+		t.Errorf("want an empty trace, got %v", e.Trace)
+	}
+}
+
+func TestInterpreter_ReportStackTrace(t *testing.T) {
+	var stderr bytes.Buffer
+
+	i := &Interpreter{}
+	i.SetErrorOutput(&stderr)
+
+	i.Report(exec(i, "fun f() { return 1 / 0; }\nf();"))
+
+	want := "error at line 1, col 20: division by zero\n  at <fn f>, line 2\n"
+	if stderr.String() != want {
+		t.Errorf("want %q, got %q", want, stderr.String())
+	}
+}
+
+func TestInterpreter_Const(t *testing.T) {
+	out, err := output(`const greeting = "hello";
+	{
+		var greeting = "shadowed";
+		greeting = greeting + "!";
+		print greeting;
+	}
+	print greeting;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "shadowed!\nhello\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestInterpreter_ConstAcrossRuns(t *testing.T) {
+	i := NewInterpreter()
+
+	if err := exec(i, "const a = 1;"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := exec(i, "a = 2;")
+	if want := "error at line 1, col 1: cannot assign to constant a"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+
+	// a failed run leaves the constants as they were
+	if err := exec(i, "var a = 3; break;"); err == nil {
+		t.Fatal("want an error, break is outside of a loop")
+	}
+
+	if err := exec(i, "a = 2;"); err == nil {
+		t.Error("want an error, a is still constant")
+	}
+
+	if err := exec(i, "{ var a = 4; a = 5; }"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// nor does a run failing before reaching its constants
+	if err := exec(i, "undefined(); const b = 1;"); err == nil {
+		t.Fatal("want an error, undefined is not defined")
+	}
+
+	err = exec(i, "b = 2;")
+	if want := "cannot assign to constant b"; err == nil || strings.Contains(err.Error(), want) {
+		t.Errorf("want b undefined, got %v", err)
+	}
+
+	// declared again with var it can be assigned
+	if err := exec(i, "var a = 6;"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := exec(i, "a = 7;"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if a := global(i, "a"); a != 7.0 {
+		t.Errorf("want 7, got %v", a)
+	}
+}
+
+func TestInterpreter_ReplMode(t *testing.T) {
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+	i.ReplMode = true
+
+	lines := []string{
+		"1 + 2;",
+		"var x = 10;",
+		"x = x * 2;",
+		"x;",
+		"\"text\";",
+		"[1, \"a\"];",
+		"fun f() {}",
+		"f();",
+		"print x;",
+		"x > 5 ? \"big\" : \"small\";",
+	}
+
+	for _, line := range lines {
+		if err := exec(i, line); err != nil {
+			t.Fatalf("%s: unexpected error: %v", line, err)
+		}
+	}
+
+	want := "3\n20\n\"text\"\n[1, \"a\"]\n20\n\"big\"\n"
+	if b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+}
+
+func TestInterpreter_FileModeSilent(t *testing.T) {
+	out, err := output("1 + 2; \"text\";")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out != "" {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func BenchmarkInterpreter_Fib(b *testing.B) {
+	program := `fun fib(n) {
+		if (n < 2) return n;
+		return fib(n - 1) + fib(n - 2);
+	}
+	{
+		var total = 0;
+		for (var i = 0; i < 10; i = i + 1) total = total + fib(15);
+	}`
+
+	scanner := Scanner{program}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		i := &Interpreter{}
+		if err := i.Run(stmts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestInterpreter_Scopes(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"var a = 1; { var a = 2; { var a = 3; print a; } print a; } print a;", "3\n2\n1\n"},
+		{"{ var a = 1; var b = 2; { var c = a + b; a = c; } print a; }", "3\n"},
+		{"{ var a = 1; fun f() { return a; } var a = 2; print f(); }", "2\n"},
+		{"fun counter() { var n = 0; return fun () { n = n + 1; return n; }; } var c = counter(); c(); print c();", "2\n"},
+		{"{ var x = \"outer\"; fun f() { return x; } { var x = \"inner\"; print f(); } }", "outer\n"},
+		{"fun f(a, b) { var c = a * b; { var d = c + a; return d; } } print f(2, 3);", "8\n"},
+		{"{ var a = \"global?\"; fun f() { print a; } f(); a = \"changed\"; f(); }", "global?\nchanged\n"},
+		{"var g = 1; fun f() { g = g + 1; } f(); print g;", "2\n"},
+		{"for (var i = 0; i < 2; i = i + 1) { var j = i * 10; print j; }", "0\n10\n"},
+		{"{ for (var i = 0; i < 2; i = i + 1) { fun f() { return i; } print f(); } }", "0\n1\n"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestInterpreter_SkippedDeclaration(t *testing.T) {
+	_, err := run("{ if (false) fun f() {} f(); }")
+	if want := "error at line 1, col 25: undefined variable f"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestInterpreter_ClosureAcrossRuns(t *testing.T) {
+	var b bytes.Buffer
+
+	i := &Interpreter{}
+	i.SetOutput(&b)
+
+	// each line is resolved on its own, like in the REPL
+	lines := []string{
+		"fun adder(n) { return fun (x) { return x + n; }; }",
+		"var add2 = adder(2);",
+		"{ var y = 40; print add2(y); }",
+	}
+
+	for _, line := range lines {
+		if err := exec(i, line); err != nil {
+			t.Fatalf("%s: unexpected error: %v", line, err)
+		}
+	}
+
+	if b.String() != "42\n" {
+		t.Errorf("want %q, got %q", "42\n", b.String())
+	}
+}
+
+func TestInterpreter_Methods(t *testing.T) {
+	out, err := output(`class Counter {
+		init(start) { this.count = start; }
+		add(n) { this.count = this.count + n; return this; }
+	}
+	var c = Counter(1);
+	c.add(2).add(3);
+	var add = c.add;
+	add(4);
+	print c.count;
+	print c;
+	print Counter;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "10\n<Counter instance>\n<class Counter>\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestInterpreter_Getter(t *testing.T) {
+	out, err := output(`class Rect {
+		init(w, h) { this.w = w; this.h = h; }
+		area { return this.w * this.h; }
+		scaled(k) { return Rect(this.w * k, this.h * k); }
+	}
+	var r = Rect(2, 3);
+	print r.area;
+	r.w = 10;
+	print r.area;
+	print r.scaled(2).area;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "6\n30\n120\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestInterpreter_GetterCall(t *testing.T) {
+	_, err := run("class Rect { area { return 1; } }\nRect().area();")
+	if want := "error at line 2, col 8: cannot call getter area"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestInterpreter_StaticMethods(t *testing.T) {
+	out, err := output(`class Math {
+		class square(x) { return x * x; }
+		class cube(x) { return Math.square(x) * x; }
+	}
+	print Math.square(3);
+	var cube = Math.cube;
+	print cube(2);`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "9\n8\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestInterpreter_Fields(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"class Counter { var count = 0; }\nprint Counter().count;", "0\n"},
+		{"class Box { var value; }\nprint Box().value;", "nil\n"},
+		{"class Pair { var a = 1; var b = this.a + 1; }\nprint Pair().b;", "2\n"},
+		{"class A { var x = 1; init() { this.x = this.x + 10; } }\nprint A().x;", "11\n"},
+		{"class A { var x = 1; }\nclass B < A { var y = this.x * 2; }\nprint B().y;", "2\n"},
+		{"class A { var xs = []; }\nvar a = A();\nvar b = A();\npush(a.xs, 1);\nprint len(b.xs);", "0\n"},
+		{"var n = 0;\nclass A { var id = n; init() { n = n + 1; } }\nA();\nprint A().id;", "1\n"},
+		{"class A { var x = 1; get() { return this.x; } }\nprint A().get();", "1\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_Super(t *testing.T) {
+	out, err := output(`class A {
+		name() { return "A"; }
+		describe() { return "I am " + this.name(); }
+	}
+	class B < A { name() { return "B<" + super.name(); } }
+	class C < B {
+		name() { return "C<" + super.name(); }
+		describe() { return super.describe() + "!"; }
+	}
+	class D < C {}
+	print C().name();
+	print C().describe();
+	print D().describe();
+	fun bound() { class E < A { get() { return super.name; } } return E().get(); }
+	var m = bound();
+	print m();`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "C<B<A\nI am C<B<A!\nI am C<B<A!\nA\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestInterpreter_SuperError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print super.name;", "error at line 1, col 7: cannot use 'super' outside of a class"},
+		{"fun f() { return super.name; }", "error at line 1, col 18: cannot use 'super' outside of a class"},
+		{"class A { name() { return super.name; } }", "error at line 1, col 27: cannot use 'super' in a class with no superclass"},
+		{"class A {}\nclass B < A { class make() { return super.make; } }", "error at line 2, col 37: cannot use 'super' in a static method"},
+		{"class A {}\nclass B < A { name() { return super.name(); } }\nB().name();", "error at line 2, col 37: undefined method name of superclass"},
+		{"class A { area { return 1; } }\nclass B < A { area { return super.area(); } }\nB().area;", "error at line 2, col 35: cannot call getter area"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_Overloading(t *testing.T) {
+	vector := `class Vector {
+		init(x, y) { this.x = x; this.y = y; }
+		__add__(other) { return Vector(this.x + other.x, this.y + other.y); }
+		__sub__(other) { return Vector(this.x - other.x, this.y - other.y); }
+		__mul__(k) { return Vector(this.x * k, this.y * k); }
+		__eq__(other) { return other is Vector and this.x == other.x and this.y == other.y; }
+		__neg__() { return Vector(-this.x, -this.y); }
+		list() { return [this.x, this.y]; }
+	}
+	`
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print (Vector(1, 2) + Vector(3, 4)).list();", "[4, 6]\n"},
+		{"print (Vector(1, 2) - Vector(3, 5)).list();", "[-2, -3]\n"},
+		{"print (Vector(1, 2) * 3).list();", "[3, 6]\n"},
+		{"print (-Vector(1, 2)).list();", "[-1, -2]\n"},
+		{"var v = Vector(1, 1);\nv += Vector(1, 2);\nprint v.list();", "[2, 3]\n"},
+		{"print Vector(1, 2) == Vector(1, 2);", "true\n"},
+		{"print Vector(1, 2) != Vector(1, 2);", "false\n"},
+		{"print Vector(1, 2) == 1;", "false\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(vector + test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_OverloadingError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"class A {}\nprint A() + 1;", "error at line 2, col 11: invalid operands for binary +: *ast.ClassInstance, float64"},
+		{"class A {}\nprint -A();", "error at line 2, col 7: bad operand for unary -: *ast.ClassInstance"},
+		{"class A { __add__(a, b) { return 1; } }\nprint A() + 1;", "error at line 2, col 11: expected 2 arguments but got 1"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_ToString(t *testing.T) {
+	point := "class Point {\n init(x, y) { this.x = x; this.y = y; }\n toString() { return \"(\" + toString(this.x) + \", \" + toString(this.y) + \")\"; }\n}\nclass Plain {}\n"
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print Point(1, 2);", "(1, 2)\n"},
+		{"print Plain();", "<Plain instance>\n"},
+		{"print \"at \" + Point(1, 2);", "at (1, 2)\n"},
+		{"print Point(1, 2) + \"!\";", "(1, 2)!\n"},
+		{"print toString(Point(3, 4));", "(3, 4)\n"},
+		{"print \"a \" + Plain();", "a <Plain instance>\n"},
+		{"print [Point(1, 2), \"a\", [Plain()]];", "[(1, 2), \"a\", [<Plain instance>]]\n"},
+		{"print {\"p\": Point(1, 2)};", "{\"p\": (1, 2)}\n"},
+		{"print {p: Point(1, 2)};", "{p: (1, 2)}\n"},
+		{"print toString([Point(1, 2)]);", "[(1, 2)]\n"},
+		{"print format(\"at %s\", Point(1, 2));", "at (1, 2)\n"},
+		{"print join([Point(1, 2)], \" \");", "(1, 2)\n"},
+	}
+
+	for _, test := range tests {
+		i := &Interpreter{}
+		i.Install(CoreLib, StringLib)
+		stdout := &bytes.Buffer{}
+		i.SetOutput(stdout)
+
+		if err := exec(i, point+test.source); err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out := stdout.String(); out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_ToStringError(t *testing.T) {
+	_, err := run("class A { toString() { return 1; } }\nprint A();")
+	if want := "error at line 2, col 1: toString must return a string, got float64"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+
+	_, err = run("class A { toString() { return 1; } }\nprint [A()];")
+	if want := "error at line 2, col 1: toString must return a string, got float64"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestInterpreter_StringifyCycles(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var l = [1];\npush(l, l);\nprint l;", "[1, [...]]\n"},
+		{"var m = {};\nm[\"self\"] = m;\nprint toString(m);", "{\"self\": {...}}\n"},
+		{"var r = {self: nil};\nr.self = r;\nprint r;", "{self: {...}}\n"},
+		{"var l = [];\nvar m = {\"l\": l};\npush(l, m);\nprint format(\"%s\", [l, l]);", "[[{\"l\": [...]}], [{\"l\": [...]}]]\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_ReplModeToString(t *testing.T) {
+	for _, bytecode := range []bool{false, true} {
+		var b bytes.Buffer
+
+		i := NewInterpreter()
+		i.SetOutput(&b)
+		i.ReplMode = true
+		i.Bytecode = bytecode
+
+		lines := []string{
+			"class A { toString() { return \"a\"; } }",
+			"A();",
+			"[A()];",
+			"print A();",
+			"var l = [A()];",
+			"push(l, l);",
+			"l;",
+		}
+
+		for _, line := range lines {
+			if err := exec(i, line); err != nil {
+				t.Fatalf("%s: unexpected error: %v", line, err)
+			}
+		}
+
+		if want := "a\n[a]\na\n[a, [...]]\n"; b.String() != want {
+			t.Errorf("bytecode %t: want %q, got %q", bytecode, want, b.String())
+		}
+	}
+}
+
+func TestInterpreter_ForIn(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"for (x in [1, 2, 3]) print x;", "1\n2\n3\n"},
+		{"for (var x in []) print x;", ""},
+		{`for (k in {"b": 1, "a": 2}) print k;`, "b\na\n"},
+		{"var m = {\"a\": 1, \"b\": 2};\nfor (k in m) print m[k];", "1\n2\n"},
+		{"for (x in [1, 2, 3, 4]) { if (x == 2) continue; if (x == 4) break; print x; }", "1\n3\n"},
+		{"var x = 0;\nfor (x in [1]) {}\nprint x;", "0\n"},
+		{"var fs = [];\nfor (x in [1, 2]) push(fs, fun () { return x; });\nprint fs[0]() + fs[1]();", "3\n"},
+		{"var xs = [1];\nfor (x in xs) { if (x < 3) push(xs, x + 1); print x; }", "1\n2\n3\n"},
+		{"for (r in [[1, 2], [3]]) for (x in r) print x;", "1\n2\n3\n"},
+		{"for (x in range(3, 0, -1)) print x;", "3\n2\n1\n"},
+		// in is only special in the clauses of a for..in
+		{"var in = [1, 2];\nfor (var in in in) print in;\nprint in;", "1\n2\n[1, 2]\n"},
+		{"fun f(in) { return in + 1; }\nprint f(1);", "2\n"},
+		{"var in = 2;\nfor (var i = 0; i < in; i = i + 1) print i;", "0\n1\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_ForInError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"for (x in 1) print x;", "error at line 1, col 1: cannot iterate over float64"},
+		{`for (c in "abc") print c;`, "error at line 1, col 1: cannot iterate over string"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_StringRepetition(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print "ab" * 3;`, "ababab\n"},
+		{`print "ab" * 0 == "";`, "true\n"},
+		{`print "" * 100000000000000000000 == "";`, "true\n"},
+		{"var s = \"-\";\ns *= 2;\nprint s;", "--\n"},
+		{`print "a" + toString(1);`, "a1\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_StringOperatorError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`print "ab" * -1;`, "error at line 1, col 12: string repetition count must be a non-negative integer, got -1"},
+		{`print "ab" * 1.5;`, "error at line 1, col 12: string repetition count must be a non-negative integer, got 1.5"},
+		{`print "ab" * 100000000000000000000;`, "error at line 1, col 12: string repetition count 100000000000000000000 is too large"},
+		{`print "ab" * 5000000000000000000;`, "error at line 1, col 12: string repetition count 5000000000000000000 is too large"},
+		{`print "ab" * "c";`, "error at line 1, col 12: invalid operands for binary *: string, string"},
+		{`print 3 * "ab";`, "error at line 1, col 9: invalid operands for binary *: float64, string"},
+		{`print "a" + 1;`, "error at line 1, col 11: invalid operands for binary +: string, float64"},
+		{`print 1 + "a";`, "error at line 1, col 9: invalid operands for binary +: float64, string"},
+		{`print "a" + nil;`, "error at line 1, col 11: invalid operands for binary +: string, <nil>"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_Coalesce(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print nil ?? 1;", "1\n"},
+		{"print false ?? 1;", "false\n"},
+		{"print 0 ?? 1;", "0\n"},
+		{"print nil ?? nil ?? 3;", "3\n"},
+		{"print nil ?? false or true;", "true\n"},
+		{"print nil ?? 1 ? \"yes\" : \"no\";", "yes\n"},
+		{"var calls = 0;\nfun f() { calls = calls + 1; return 2; }\nprint 1 ?? f();\nprint nil ?? f();\nprint calls;", "1\n2\n1\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_OptionalChaining(t *testing.T) {
+	prelude := `class Node { init(next) { this.next = next; this.value = 1; } get() { return this.value; } }
+	var calls = 0;
+	fun count() { calls = calls + 1; return 0; }
+	var a = nil;
+	var n = Node(Node(nil));
+	`
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"print a?.b;", "nil\n"},
+		{"print a?.b.c;", "nil\n"},
+		{"print a?.b.c();", "nil\n"},
+		{"print a?.b[count()];\nprint calls;", "nil\n0\n"},
+		{"print a?.b(count());\nprint calls;", "nil\n0\n"},
+		{"print n?.value;", "1\n"},
+		{"print n?.next.value;", "1\n"},
+		{"print n.next.next?.value;", "nil\n"},
+		{"print n.next?.get();", "1\n"},
+		{"print a?.b ?? \"default\";", "default\n"},
+		{"var v = a?.b.c;\nprint v;", "nil\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(prelude + test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_OptionalChainingError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var a = nil;\nprint (a?.b).c;", "error at line 2, col 14: invalid property: c"},
+		{"var a = nil;\nprint a.b;", "error at line 2, col 9: invalid property: b"},
+		{"var a = nil;\na?.b = 1;", "error at line 2, col 6: invalid assignment target"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_Is(t *testing.T) {
+	hierarchy := `class Shape { name() { return "shape"; } }
+	class Circle < Shape {}
+	class Square < Shape { name() { return "square"; } }
+	class Unit < Square {}
+	var shape = Shape(); var circle = Circle(); var unit = Unit();
+	`
+
+	table := []struct {
+		in  string
+		out interface{}
+	}{
+		{"shape is Shape", true},
+		{"shape is Circle", false},
+		{"circle is Shape", true},
+		{"circle is Square", false},
+		{"unit is Unit", true},
+		{"unit is Square", true},
+		{"unit is Shape", true},
+		{"unit is Circle", false},
+		{"1 is Shape", false},
+		{"nil is Shape", false},
+		{"Circle is Shape", false},
+		{"circle.name()", "shape"},
+		{"unit.name()", "square"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i, err := run(hierarchy + "var result = " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if v := global(i, "result"); v != test.out {
+				t.Errorf("want %v, got %v", test.out, v)
+			}
+		})
+	}
+}
+
+func TestInterpreter_IsError(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"class A {}\nprint A() is 1;", "error at line 2, col 11: right operand of is must be a class, got float64"},
+		{"class A {}\nvar a = A();\nprint a is a;", "error at line 3, col 9: right operand of is must be a class, got *ast.ClassInstance"},
+		{"var A = 1;\nclass B < A {}", "error at line 2, col 7: superclass of B must be a class, got float64"},
+		{"class A < A {}", "error at line 1, col 11: class A cannot inherit from itself"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := run(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestInterpreter_Logical(t *testing.T) {
+	table := []struct {
+		in  string
+		out interface{}
+	}{
+		{`nil or "default"`, "default"},
+		{`false or nil`, nil},
+		{`"first" or "second"`, "first"},
+		{`0 or 1`, 0.0},
+		{`nil or false or 3`, 3.0},
+		{`1 and 2`, 2.0},
+		{`nil and 2`, nil},
+		{`false and nil`, false},
+		{`"a" and false and "b"`, false},
+		{`1 and nil or "fallback"`, "fallback"},
+		{`true and 42`, 42.0},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			l, err := evaluate(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if l.Value != test.out {
+				t.Errorf("want %v, got %v", test.out, l.Value)
+			}
+		})
+	}
+}
+
+func TestInterpreter_LogicalShortCircuit(t *testing.T) {
+	out, err := output(`fun loud(v) { print "evaluated"; return v; }
+	var a = false and loud(1);
+	var b = nil and loud(1);
+	var c = 1 or loud(1);
+	var d = 0 and loud("zero is true");
+	print d;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "evaluated\nzero is true\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestInterpreter_FloorDivision(t *testing.T) {
+	table := []struct {
+		in  string
+		out interface{}
+	}{
+		{"7 // 2", 3.0},
+		{"-7 // 2", -4.0},
+		{"7 // -2", -4.0},
+		{"-7 // -2", 3.0},
+		{"6 // 3", 2.0},
+		{"7.5 // 2", 3.0},
+		{"1 + 7 // 2 * 2", 7.0},
+		{"(1 + 7) // 3", 2.0},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			l, err := evaluate(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if l.Value != test.out {
+				t.Errorf("want %v, got %v", test.out, l.Value)
+			}
+		})
+	}
+}
+
+func TestInterpreter_TrailingComments(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var x = 1;\nif (x > 0) // positive\nprint x;", "1\n"},
+		{"var x = 0;\nwhile (x < 2) // loop\nx = x + 1;\nprint x;", "2\n"},
+		{"fun f() // doc\n{ return 7 // 2; }\nprint f();", "3\n"},
+		{"var g = fun (a) // doc\n{ return a; };\nprint g(1);", "1\n"},
+		{"class A {\n  m() // doc\n  { return 1; }\n  n // getter\n  { return 2; }\n}\nprint A().m() + A().n;", "3\n"},
+		{"var x = 7 // 2; // floor\nprint x;", "3\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_FloorDivisionError(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"print 1 // 0;", "error at line 1, col 9: division by zero"},
+		{"print \"a\" // 2;", "error at line 1, col 11: invalid operands for binary //: string, float64"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := run(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestInterpreter_Records(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var p = {x: 1, y: 2};\nprint p.x + p.y;", "3\n"},
+		{"var p = {x: 1, y: 2};\np.x = 10;\np.y += 1;\nprint p;", "{x: 10, y: 3}\n"},
+		{"var p = {name: \"a\", tags: [1]};\nprint p;", "{name: \"a\", tags: [1]}\n"},
+		{"var p = {f: fun (n) { return n * 2; }};\nprint p.f(4);", "8\n"},
+		{"var p = {x: 1};\nvar q = p;\nq.x = 2;\nprint p.x;", "2\n"},
+		{"var p = {inner: {v: 1}};\nprint p.inner.v;", "1\n"},
+		{"print type({x: 1});", "record\n"},
+		{"print deepEqual({x: 1, y: [2]}, {x: 1, y: [2]});", "true\n"},
+		{"var p = {x: 1};\nprint p == {x: 1};", "false\n"},
+		{"var k = \"x\";\nprint {(k): 1};", "{\"x\": 1}\n"},
+		{"var p = nil;\nprint p?.x;", "nil\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_RecordError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"var p = {x: 1};\nprint p.z;", "error at line 2, col 9: undefined field z"},
+		{"var p = {x: 1};\np.z = 1;", "error at line 2, col 3: undefined field z"},
+		{"var p = {x: 1};\np.x += \"a\";", "error at line 2, col 5: invalid operands for binary +: float64, string"},
+		{"var p = {x: 1, x: 2};", "error at line 1, col 16: duplicate field x"},
+		{"var p = {x: 1, \"y\": 2};", "error at line 1, col 16: expected 'IDENTIFIER'"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_DoWhile(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		// the body runs once before the condition is checked
+		{"do print \"once\"; while (false);", "once\n"},
+		{"var n = 0;\ndo n = n + 1; while (n < 3);\nprint n;", "3\n"},
+		{"var n = 0;\ndo { n = n + 1; if (n == 2) continue; print n; } while (n < 3);", "1\n3\n"},
+		{"var n = 0;\ndo { n = n + 1; if (n == 2) break; } while (true);\nprint n;", "2\n"},
+		{"var calls = 0;\nfun check() { calls = calls + 1; return false; }\ndo {} while (check());\nprint calls;", "1\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_Labeled(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"outer: for (var i = 0; i < 3; i = i + 1) {\n  for (var j = 0; j < 3; j = j + 1) {\n    if (j == 1) continue outer;\n    if (i == 2) break outer;\n    print i * 10 + j;\n  }\n}", "0\n10\n"},
+		{"var n = 0;\nloop: while (true) { do { n = n + 1; if (n == 3) break loop; } while (true); }\nprint n;", "3\n"},
+		{"rows: for (r in [[1, 2], [3, 4]]) for (x in r) { if (x == 2) continue rows; print x; }", "1\n3\n4\n"},
+		{"a: for (x in [1, 2]) { b: for (y in [1, 2]) { if (y == 2) continue a; print x + y; } }", "2\n3\n"},
+		// unlabeled statements still refer to the innermost loop
+		{"outer: for (x in [1, 2]) { for (y in [1, 2]) { if (y == 1) continue; print x * y; } }", "2\n4\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_LabeledError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"while (true) break outer;", "error at line 1, col 20: undefined label outer"},
+		{"a: while (true) { b: while (true) continue c; }", "error at line 1, col 44: undefined label c"},
+		{"a: while (true) fun f() { while (true) break a; }", "error at line 1, col 46: undefined label a"},
+		{"a: while (true) a: while (true) break;", "error at line 1, col 17: label a is already used by an enclosing loop"},
+		{"a: print 1;", "error at line 1, col 4: expected a loop after label a"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_Destructuring(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"fun divmod(a, b) { return [floor(a / b), a % b]; }\nvar q, r = divmod(7, 3);\nprint q;\nprint r;", "2\n1\n"},
+		{"{ var a, b = [1, 2]; var c, d = [b, a]; print c - d; }", "1\n"},
+		{"fun f() { const x, y = [\"x\", \"y\"]; return x + y; } print f();", "xy\n"},
+		{"var a = 1; var a, b = [a + 1, a + 2]; print [a, b];", "[2, 3]\n"},
+		{"for (var i, j = [0, 10]; i < 2; i = i + 1) print i + j;", "10\n11\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_DestructuringError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"fun pair() { return [1, 2, 3]; } var a, b = pair();", "error at line 1, col 34: expected 2 values but got 3"},
+		{"var a, b = [1];", "error at line 1, col 1: expected 2 values but got 1"},
+		{"var a, b = \"ab\";", "error at line 1, col 1: can only destructure lists, got string"},
+		{"var a, a = [1, 2];", "error at line 1, col 8: duplicate name a"},
+		{"var a, b;", "error at line 1, col 9: expected 'EQUAL'"},
+		{"{ const a, b = [1, 2]; b = 3; }", "error at line 1, col 24: cannot assign to constant b"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_Enum(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"enum Color { RED, GREEN, BLUE }\nprint Color.RED;\nprint Color;", "Color.RED\n<enum Color>\n"},
+		{"enum Color { RED, GREEN }\nprint Color.RED == Color.RED;\nprint Color.RED == Color.GREEN;", "true\nfalse\n"},
+		{"enum A { X } enum B { X }\nprint A.X == B.X;\nprint A.X != B.X;", "false\ntrue\n"},
+		{"enum Color { RED, GREEN }\nvar c = Color.GREEN;\nswitch (c) { case Color.RED: print 1; case Color.GREEN: print 2; }", "2\n"},
+		{"fun f() { enum Dir { UP, DOWN } return Dir.DOWN; }\nprint f();", "Dir.DOWN\n"},
+		{"enum Color {\n  RED,\n  GREEN\n}\nprint type(Color) + \" \" + type(Color.RED);", "enum enum member\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_EnumError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"enum Color { RED } Color.RED = 1;", "error at line 1, col 26: cannot assign to member RED of enum Color"},
+		{"enum Color { RED } Color.BLUE = 1;", "error at line 1, col 26: undefined member BLUE of enum Color"},
+		{"enum Color { RED } Color.RED += 1;", "error at line 1, col 26: cannot assign to member RED of enum Color"},
+		{"enum Color { RED } print Color.BLUE;", "error at line 1, col 32: undefined member BLUE of enum Color"},
+		{"enum Color { RED, RED }", "error at line 1, col 19: duplicate member RED"},
+		{"enum Color { RED GREEN }", "error at line 1, col 18: expected 'RIGHT_SQUARE'"},
+		{"enum { RED }", "error at line 1, col 6: expected 'IDENTIFIER'"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_Defer(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"fun f() { defer print \"deferred\"; print \"body\"; }\nf();", "body\ndeferred\n"},
+		{"fun f() { defer print 1; defer print 2; defer print 3; }\nf();", "3\n2\n1\n"},
+		{"fun f(x) { defer print \"done\"; if (x) return \"early\"; print \"late\"; return \"end\"; }\nprint f(true);\nprint f(false);", "done\nearly\nlate\ndone\nend\n"},
+		{"fun f() { defer print \"cleanup\"; throw \"oops\"; }\ntry { f(); } catch (e) { print e; }", "cleanup\noops\n"},
+		{"fun f() { for (var i = 0; i < 3; i = i + 1) { var j = i * 10; defer print j; } }\nf();", "20\n10\n0\n"},
+		{"var log = [];\nfun f() { defer push(log, \"f\"); g(); }\nfun g() { defer push(log, \"g\"); throw \"x\"; }\ntry { f(); } catch (e) {}\nprint log;", `["g", "f"]` + "\n"},
+		{"fun f() { var x = 1; defer { x = 2; print x; } return x; }\nprint f();", "2\n1\n"},
+		{"fun f() { defer throw \"deferred\"; return 1; }\ntry { f(); } catch (e) { print e; }", "deferred\n"},
+		{"fun f() { defer print \"second\"; defer throw \"first\"; throw \"body\"; }\ntry { f(); } catch (e) { print e; }", "second\nfirst\n"},
+		{"var f = fun () { defer print \"lambda\"; };\nf();\nprint \"after\";", "lambda\nafter\n"},
+		{"fun f() { defer (fun () { return 1; })(); }\nprint f();", "nil\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_DeferError(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"defer print 1;", "error at line 1, col 1: cannot defer outside of a function"},
+		{"{ defer print 1; }", "error at line 1, col 3: cannot defer outside of a function"},
+		{"fun f() { defer return 1; }", "error at line 1, col 17: cannot return from a deferred statement"},
+		{"fun f() { while (true) { defer break; } }", "error at line 1, col 32: cannot break outside of a loop"},
+		{"fun f() { defer { if (true) return 1; } }", "error at line 1, col 29: cannot return from a deferred statement"},
+		{"fun f() { defer var x = 1; }", "error at line 1, col 17: unknown token 'var'"},
+	}
+
+	for _, test := range tests {
+		_, err := run(test.source)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+}
+
+func TestInterpreter_BareReturn(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"fun f() { print 1; return; print 2; }\nprint f();", "1\nnil\n"},
+		{"class A { init(x) { this.x = 0; if (x) return; this.x = 1; } }\nprint A(true).x;\nprint A(false).x;", "0\n1\n"},
+		{"class A { init() { return; } }\nprint A();", "<A instance>\n"},
+	}
+
+	for _, test := range tests {
+		out, err := output(test.source)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.source, err)
+			continue
+		}
+
+		if out != test.want {
+			t.Errorf("%q: want %q, got %q", test.source, test.want, out)
+		}
+	}
+}
+
+func TestInterpreter_MaxSteps(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"while (true) {}", "error at line 1, col 1: execution budget exceeded"},
+		{"var n = 0;\nfor (;;) n = n + 1;", "error at line 2, col 10: execution budget exceeded"},
+		{"fun f() { f(); }\nwhile (true) try { f(); } catch (e) {}", "error at line 1, col 11: execution budget exceeded"},
+		{"while (true) try { while (true) {} } catch (e) { print e; }", "error at line 1, col 20: execution budget exceeded"},
+	}
+
+	for _, test := range tests {
+		i := &Interpreter{MaxSteps: 100}
+		if err := exec(i, test.source); err == nil || err.Error() != test.want {
+			t.Errorf("%q: want %q, got %v", test.source, test.want, err)
+		}
+	}
+
+	// a program within the budget runs to the end, every run has its own
+	i := &Interpreter{MaxSteps: 100}
+	for run := 0; run < 3; run++ {
+		if err := exec(i, "for (var j = 0; j < 10; j = j + 1) {}"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestInterpreter_Deadline(t *testing.T) {
+	stmts, err := parse("var n = 0;\nwhile (true) n = n + 1;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	i := &Interpreter{}
+	err = i.RunWithContext(ctx, stmts)
+
+	want := "error at line 2, col 14: execution budget exceeded"
+	if err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+
+	// the deadline is over, but it belongs to the previous run only
+	if err := i.Run([]Stmt{ExprStmt{Literal{1.0}}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestInterpreter_Truthiness(t *testing.T) {
+	// every construct taking a condition agrees with bool
+	program := `fun check(v) {
+  var results = [bool(v)];
+  if (v) push(results, true); else push(results, false);
+  push(results, v ? true : false);
+  push(results, !!v);
+  push(results, (v and true) == true);
+  push(results, (v or false) != false and (v or false) != nil);
+  var n = 0;
+  while (v and n < 1) n = n + 1;
+  push(results, n == 1);
+  var m = 0;
+  for (; v and m < 1; m = m + 1) {}
+  push(results, m == 1);
+  push(results, len(filter([v], fun (x) { return x; })) == 1);
+  return results;
+}
+`
+
+	table := []struct {
+		in   string
+		want string
+	}{
+		{"0", "true"},
+		{`""`, "true"},
+		{"[]", "true"},
+		{"{}", "true"},
+		{"check", "true"},
+		{"true", "true"},
+		{"false", "false"},
+		{"nil", "false"},
+	}
+
+	for _, test := range table {
+		out, err := output(program + "print check(" + test.in + ");")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.in, err)
+		}
+
+		want := "[" + strings.Repeat(test.want+", ", 8) + test.want + "]\n"
+		if out != want {
+			t.Errorf("%s: want %q, got %q", test.in, want, out)
+		}
+	}
+}
@@ -0,0 +1,806 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// node is the JSON form of an AST node, its "type" field is the name of
+// the Go type of the node.
+type node map[string]interface{}
+
+// ToJSON serializes a program, every node is an object with a "type"
+// discriminator and tokens are objects with their type, lexeme, literal,
+// line and column.
+func ToJSON(stmts []Stmt) ([]byte, error) {
+	e := jsonEncoder{}
+
+	program := e.stmts(stmts)
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return json.Marshal(program)
+}
+
+// FromJSON deserializes a program serialized by ToJSON.
+func FromJSON(b []byte) ([]Stmt, error) {
+	var program interface{}
+	if err := json.Unmarshal(b, &program); err != nil {
+		return nil, err
+	}
+
+	d := jsonDecoder{}
+
+	stmts := d.stmts(program)
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return stmts, nil
+}
+
+type jsonEncoder struct {
+	out interface{}
+	err error
+}
+
+func (e *jsonEncoder) expr(x Expr) interface{} {
+	if x == nil {
+		return nil
+	}
+
+	_ = x.Accept(e)
+	return e.out
+}
+
+func (e *jsonEncoder) stmt(s Stmt) interface{} {
+	if s == nil {
+		return nil
+	}
+
+	_ = s.Accept(e)
+	return e.out
+}
+
+func (e *jsonEncoder) exprs(exprs []Expr) interface{} {
+	if exprs == nil {
+		return nil
+	}
+
+	nodes := make([]interface{}, len(exprs))
+	for i, x := range exprs {
+		nodes[i] = e.expr(x)
+	}
+
+	return nodes
+}
+
+func (e *jsonEncoder) stmts(stmts []Stmt) interface{} {
+	if stmts == nil {
+		return nil
+	}
+
+	// comments are not serialized
+	nodes := make([]interface{}, 0, len(stmts))
+	for _, s := range stmts {
+		if !commentsOnly(s) {
+			nodes = append(nodes, e.stmt(s))
+		}
+	}
+
+	return nodes
+}
+
+func (e *jsonEncoder) token(t Token) interface{} {
+	if t == (Token{}) {
+		return nil
+	}
+
+	n := node{"type": t.TokenType.String(), "lexeme": t.Lexeme, "literal": t.Literal, "line": t.Line, "column": t.Column}
+
+	// set by #line directives only
+	if t.File != "" {
+		n["file"] = t.File
+	}
+
+	return n
+}
+
+func (e *jsonEncoder) tokens(tokens []Token) interface{} {
+	if tokens == nil {
+		return nil
+	}
+
+	nodes := make([]interface{}, len(tokens))
+	for i, t := range tokens {
+		nodes[i] = e.token(t)
+	}
+
+	return nodes
+}
+
+func (e *jsonEncoder) emit(n node) error {
+	e.out = n
+	return nil
+}
+
+func (e *jsonEncoder) function(f Function) node {
+	return node{"type": "Function", "name": e.token(f.Name), "arguments": e.tokens(f.Arguments), "defaults": e.exprs(f.Defaults), "variadic": f.Variadic, "getter": f.Getter, "body": e.stmts(f.Body)}
+}
+
+func (e *jsonEncoder) visitAssign(a Assign) error {
+	return e.emit(node{"type": "Assign", "name": e.token(a.Variable.Token), "equal": e.token(a.Token), "value": e.expr(a.Expr)})
+}
+
+func (e *jsonEncoder) visitBinary(b Binary) error {
+	return e.emit(node{"type": "Binary", "left": e.expr(b.Left), "operator": e.token(b.Operator), "right": e.expr(b.Right)})
+}
+
+func (e *jsonEncoder) visitCall(c Call) error {
+	return e.emit(node{"type": "Call", "callee": e.expr(c.Callee), "paren": e.token(c.Paren), "arguments": e.exprs(c.Arguments)})
+}
+
+func (e *jsonEncoder) visitGet(g Get) error {
+	return e.emit(node{"type": "Get", "name": e.token(g.Name), "object": e.expr(g.Object), "optional": g.Optional})
+}
+
+func (e *jsonEncoder) visitGrouping(g Grouping) error {
+	return e.emit(node{"type": "Grouping", "expr": e.expr(g.Expr)})
+}
+
+func (e *jsonEncoder) visitIndex(i Index) error {
+	return e.emit(node{"type": "Index", "object": e.expr(i.Object), "bracket": e.token(i.Bracket), "index": e.expr(i.Index)})
+}
+
+func (e *jsonEncoder) visitIndexSet(i IndexSet) error {
+	return e.emit(node{"type": "IndexSet", "object": e.expr(i.Object), "bracket": e.token(i.Bracket), "index": e.expr(i.Index), "value": e.expr(i.Value), "operator": e.token(i.Operator)})
+}
+
+func (e *jsonEncoder) visitLambda(l Lambda) error {
+	return e.emit(node{"type": "Lambda", "fun": e.token(l.Fun), "arguments": e.tokens(l.Arguments), "defaults": e.exprs(l.Defaults), "variadic": l.Variadic, "body": e.stmts(l.Body)})
+}
+
+func (e *jsonEncoder) visitList(l List) error {
+	return e.emit(node{"type": "List", "bracket": e.token(l.Bracket), "elements": e.exprs(l.Elements)})
+}
+
+func (e *jsonEncoder) visitLiteral(l Literal) error {
+	switch v := l.Value.(type) {
+	case nil:
+		{
+			return e.emit(node{"type": "Literal", "kind": "nil"})
+		}
+	case bool:
+		{
+			return e.emit(node{"type": "Literal", "kind": "bool", "value": v})
+		}
+	case string:
+		{
+			return e.emit(node{"type": "Literal", "kind": "string", "value": v})
+		}
+	case float64:
+		{
+			// JSON has no infinities nor NaN
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				return e.emit(node{"type": "Literal", "kind": "number", "value": strconv.FormatFloat(v, 'g', -1, 64)})
+			}
+
+			return e.emit(node{"type": "Literal", "kind": "number", "value": v})
+		}
+	}
+
+	if e.err == nil {
+		e.err = fmt.Errorf("cannot serialize literal %v of type %T", l, l.Value)
+	}
+
+	return e.emit(nil)
+}
+
+func (e *jsonEncoder) visitLogical(l Logical) error {
+	return e.emit(node{"type": "Logical", "left": e.expr(l.Left), "operator": e.token(l.Operator), "right": e.expr(l.Right)})
+}
+
+func (e *jsonEncoder) visitMap(m Map) error {
+	return e.emit(node{"type": "Map", "brace": e.token(m.Brace), "keys": e.exprs(m.Keys), "values": e.exprs(m.Values)})
+}
+
+func (e *jsonEncoder) visitRecord(r Record) error {
+	return e.emit(node{"type": "Record", "brace": e.token(r.Brace), "names": e.tokens(r.Names), "values": e.exprs(r.Values)})
+}
+
+func (e *jsonEncoder) visitSet(s Set) error {
+	return e.emit(node{"type": "Set", "object": e.expr(s.Object), "name": e.token(s.Name), "value": e.expr(s.Value), "operator": e.token(s.Operator)})
+}
+
+func (e *jsonEncoder) visitSuperExpr(s SuperExpr) error {
+	return e.emit(node{"type": "SuperExpr", "token": e.token(s.Token), "method": e.token(s.Method)})
+}
+
+func (e *jsonEncoder) visitTernary(t Ternary) error {
+	return e.emit(node{"type": "Ternary", "condition": e.expr(t.Condition), "then": e.expr(t.Then), "else": e.expr(t.Else)})
+}
+
+func (e *jsonEncoder) visitThisExpr(t ThisExpr) error {
+	return e.emit(node{"type": "ThisExpr", "token": e.token(t.Token)})
+}
+
+func (e *jsonEncoder) visitUnary(u Unary) error {
+	return e.emit(node{"type": "Unary", "operator": e.token(u.Operator), "right": e.expr(u.Right)})
+}
+
+func (e *jsonEncoder) visitVariable(v Variable) error {
+	return e.emit(node{"type": "Variable", "name": e.token(v.Token)})
+}
+
+func (e *jsonEncoder) visitBlock(b Block) error {
+	return e.emit(node{"type": "Block", "stmts": e.stmts(b.Stmts)})
+}
+
+func (e *jsonEncoder) visitBreakStmt(b BreakStmt) error {
+	return e.emit(node{"type": "BreakStmt", "token": e.token(b.Token), "label": e.token(b.Label)})
+}
+
+func (e *jsonEncoder) functions(functions []Function) interface{} {
+	if functions == nil {
+		return nil
+	}
+
+	nodes := make([]interface{}, len(functions))
+	for i, f := range functions {
+		nodes[i] = e.function(f)
+	}
+
+	return nodes
+}
+
+func (e *jsonEncoder) fields(fields []Declaration) interface{} {
+	if fields == nil {
+		return nil
+	}
+
+	nodes := make([]interface{}, len(fields))
+	for i, f := range fields {
+		nodes[i] = e.stmt(f)
+	}
+
+	return nodes
+}
+
+func (e *jsonEncoder) visitClassStmt(c ClassStmt) error {
+	return e.emit(node{"type": "ClassStmt", "name": e.token(c.Name), "superclass": e.expr(c.Superclass), "methods": e.functions(c.Methods), "statics": e.functions(c.Statics), "fields": e.fields(c.Fields)})
+}
+
+func (e *jsonEncoder) visitContinueStmt(c ContinueStmt) error {
+	return e.emit(node{"type": "ContinueStmt", "token": e.token(c.Token), "label": e.token(c.Label)})
+}
+
+func (e *jsonEncoder) visitDeclaration(d Declaration) error {
+	return e.emit(node{"type": "Declaration", "name": e.token(d.Token), "value": e.expr(d.Expr), "const": d.Const})
+}
+
+func (e *jsonEncoder) visitEnumStmt(s EnumStmt) error {
+	return e.emit(node{"type": "EnumStmt", "name": e.token(s.Name), "members": e.tokens(s.Members)})
+}
+
+func (e *jsonEncoder) visitForIn(f ForIn) error {
+	return e.emit(node{"type": "ForIn", "token": e.token(f.Token), "name": e.token(f.Name), "iterable": e.expr(f.Iterable), "body": e.stmt(f.Body)})
+}
+
+func (e *jsonEncoder) visitForStmt(f ForStmt) error {
+	return e.emit(node{"type": "ForStmt", "token": e.token(f.Token), "init": e.stmt(f.Init), "condition": e.expr(f.Condition), "increment": e.expr(f.Increment), "body": e.stmt(f.Body)})
+}
+
+func (e *jsonEncoder) visitFunction(f Function) error {
+	return e.emit(e.function(f))
+}
+
+func (e *jsonEncoder) visitImportStmt(i ImportStmt) error {
+	return e.emit(node{"type": "ImportStmt", "token": e.token(i.Token), "path": i.Path, "stmts": e.stmts(i.Stmts)})
+}
+
+func (e *jsonEncoder) visitLabeled(l Labeled) error {
+	return e.emit(node{"type": "Labeled", "label": e.token(l.Label), "loop": e.stmt(l.Loop)})
+}
+
+func (e *jsonEncoder) visitIfStmt(i IfStmt) error {
+	return e.emit(node{"type": "IfStmt", "token": e.token(i.Token), "condition": e.expr(i.Condition), "then": e.stmt(i.Then), "else": e.stmt(i.Else)})
+}
+
+func (e *jsonEncoder) visitExprStmt(s ExprStmt) error {
+	return e.emit(node{"type": "ExprStmt", "expr": e.expr(s.Expr)})
+}
+
+func (e *jsonEncoder) visitPrintStmt(p PrintStmt) error {
+	return e.emit(node{"type": "PrintStmt", "token": e.token(p.Token), "expr": e.expr(p.Expr)})
+}
+
+func (e *jsonEncoder) visitReturnStmt(r ReturnStmt) error {
+	return e.emit(node{"type": "ReturnStmt", "token": e.token(r.Token), "value": e.expr(r.Expr)})
+}
+
+func (e *jsonEncoder) visitSwitchStmt(s SwitchStmt) error {
+	var cases []interface{}
+	if s.Cases != nil {
+		cases = make([]interface{}, len(s.Cases))
+		for i, c := range s.Cases {
+			cases[i] = node{"value": e.expr(c.Value), "body": e.stmt(c.Body)}
+		}
+	}
+
+	return e.emit(node{"type": "SwitchStmt", "token": e.token(s.Token), "discriminant": e.expr(s.Discriminant), "cases": cases, "default": e.stmt(s.Default)})
+}
+
+func (e *jsonEncoder) visitThrowStmt(t ThrowStmt) error {
+	return e.emit(node{"type": "ThrowStmt", "token": e.token(t.Token), "value": e.expr(t.Expr)})
+}
+
+func (e *jsonEncoder) visitTryStmt(t TryStmt) error {
+	return e.emit(node{"type": "TryStmt", "body": e.stmt(t.Body), "name": e.token(t.Name), "catch": e.stmt(t.Catch)})
+}
+
+func (e *jsonEncoder) visitDeferStmt(d DeferStmt) error {
+	return e.emit(node{"type": "DeferStmt", "token": e.token(d.Token), "stmt": e.stmt(d.Deferred)})
+}
+
+func (e *jsonEncoder) visitDestructuring(d Destructuring) error {
+	return e.emit(node{"type": "Destructuring", "token": e.token(d.Token), "names": e.tokens(d.Names), "value": e.expr(d.Expr), "const": d.Const})
+}
+
+func (e *jsonEncoder) visitDoWhile(d DoWhile) error {
+	return e.emit(node{"type": "DoWhile", "token": e.token(d.Token), "body": e.stmt(d.Body), "condition": e.expr(d.Condition)})
+}
+
+func (e *jsonEncoder) visitWhileStmt(w WhileStmt) error {
+	return e.emit(node{"type": "WhileStmt", "token": e.token(w.Token), "condition": e.expr(w.Condition), "body": e.stmt(w.Body)})
+}
+
+// tokenTypeNames maps the names of the token types back to their values.
+var tokenTypeNames = func() map[string]TokenType {
+	names := make(map[string]TokenType, tokenTypes)
+	for t := TokenType(0); t < tokenTypes; t++ {
+		names[t.String()] = t
+	}
+
+	return names
+}()
+
+// jsonDecoder rebuilds the AST from the generic JSON values, the first
+// error encountered is kept in err.
+type jsonDecoder struct {
+	err error
+}
+
+func (d *jsonDecoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("invalid AST: "+format, a...)
+	}
+}
+
+func (d *jsonDecoder) object(v interface{}) node {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		d.fail("want an object, got %T", v)
+		return node{}
+	}
+
+	return m
+}
+
+func (d *jsonDecoder) list(v interface{}) []interface{} {
+	if v == nil {
+		return nil
+	}
+
+	l, ok := v.([]interface{})
+	if !ok {
+		d.fail("want an array, got %T", v)
+	}
+
+	return l
+}
+
+func (d *jsonDecoder) string(v interface{}) string {
+	s, ok := v.(string)
+	if !ok {
+		d.fail("want a string, got %T", v)
+	}
+
+	return s
+}
+
+func (d *jsonDecoder) int(v interface{}) int {
+	n, ok := v.(float64)
+	if !ok {
+		d.fail("want a number, got %T", v)
+	}
+
+	return int(n)
+}
+
+func (d *jsonDecoder) bool(v interface{}) bool {
+	b, ok := v.(bool)
+	if !ok {
+		d.fail("want a boolean, got %T", v)
+	}
+
+	return b
+}
+
+func (d *jsonDecoder) token(v interface{}) Token {
+	if v == nil {
+		return Token{}
+	}
+
+	m := d.object(v)
+
+	t, ok := tokenTypeNames[d.string(m["type"])]
+	if !ok {
+		d.fail("unknown token type %v", m["type"])
+	}
+
+	var file string
+	if m["file"] != nil {
+		file = d.string(m["file"])
+	}
+
+	return Token{t, d.string(m["lexeme"]), d.string(m["literal"]), d.int(m["line"]), d.int(m["column"]), file}
+}
+
+func (d *jsonDecoder) tokens(v interface{}) []Token {
+	l := d.list(v)
+	if l == nil {
+		return nil
+	}
+
+	tokens := make([]Token, len(l))
+	for i, t := range l {
+		tokens[i] = d.token(t)
+	}
+
+	return tokens
+}
+
+func (d *jsonDecoder) exprs(v interface{}) []Expr {
+	l := d.list(v)
+	if l == nil {
+		return nil
+	}
+
+	exprs := make([]Expr, len(l))
+	for i, x := range l {
+		exprs[i] = d.expr(x)
+	}
+
+	return exprs
+}
+
+func (d *jsonDecoder) stmts(v interface{}) []Stmt {
+	l := d.list(v)
+	if l == nil {
+		return nil
+	}
+
+	stmts := make([]Stmt, len(l))
+	for i, s := range l {
+		stmts[i] = d.stmt(s)
+	}
+
+	return stmts
+}
+
+func (d *jsonDecoder) block(v interface{}) Block {
+	b, ok := d.stmt(v).(Block)
+	if !ok {
+		d.fail("want a block")
+	}
+
+	return b
+}
+
+func (d *jsonDecoder) function(m node) Function {
+	return Function{d.token(m["name"]), nil, d.tokens(m["arguments"]), d.exprs(m["defaults"]), d.bool(m["variadic"]), d.bool(m["getter"]), d.stmts(m["body"]), newSlot()}
+}
+
+func (d *jsonDecoder) functions(v interface{}) []Function {
+	l := d.list(v)
+	if l == nil {
+		return nil
+	}
+
+	functions := make([]Function, len(l))
+	for i, f := range l {
+		functions[i] = d.function(d.object(f))
+	}
+
+	return functions
+}
+
+func (d *jsonDecoder) fields(v interface{}) []Declaration {
+	l := d.list(v)
+	if l == nil {
+		return nil
+	}
+
+	fields := make([]Declaration, len(l))
+	for i, f := range l {
+		var ok bool
+		if fields[i], ok = d.stmt(f).(Declaration); !ok {
+			d.fail("want a declaration")
+		}
+	}
+
+	return fields
+}
+
+func (d *jsonDecoder) literal(m node) Literal {
+	switch m["kind"] {
+	case "nil":
+		{
+			return Literal{nil}
+		}
+	case "bool":
+		{
+			return Literal{d.bool(m["value"])}
+		}
+	case "string":
+		{
+			return Literal{d.string(m["value"])}
+		}
+	case "number":
+		{
+			if s, ok := m["value"].(string); ok {
+				n, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					d.fail("invalid number %q", s)
+				}
+
+				return Literal{n}
+			}
+
+			n, ok := m["value"].(float64)
+			if !ok {
+				d.fail("want a number, got %T", m["value"])
+			}
+
+			return Literal{n}
+		}
+	}
+
+	d.fail("unknown literal kind %v", m["kind"])
+
+	return Literal{}
+}
+
+func (d *jsonDecoder) expr(v interface{}) Expr {
+	if v == nil {
+		return nil
+	}
+
+	m := d.object(v)
+
+	switch m["type"] {
+	case "Assign":
+		{
+			return Assign{Variable{d.token(m["name"]), newSlot()}, d.token(m["equal"]), d.expr(m["value"])}
+		}
+	case "Binary":
+		{
+			return Binary{d.expr(m["left"]), d.token(m["operator"]), d.expr(m["right"])}
+		}
+	case "Call":
+		{
+			return Call{d.expr(m["callee"]), d.token(m["paren"]), d.exprs(m["arguments"])}
+		}
+	case "Get":
+		{
+			return Get{d.token(m["name"]), d.expr(m["object"]), d.bool(m["optional"])}
+		}
+	case "Grouping":
+		{
+			return Grouping{d.expr(m["expr"])}
+		}
+	case "Index":
+		{
+			return Index{d.expr(m["object"]), d.token(m["bracket"]), d.expr(m["index"])}
+		}
+	case "IndexSet":
+		{
+			return IndexSet{d.expr(m["object"]), d.token(m["bracket"]), d.expr(m["index"]), d.expr(m["value"]), d.token(m["operator"])}
+		}
+	case "Lambda":
+		{
+			return Lambda{d.token(m["fun"]), d.tokens(m["arguments"]), d.exprs(m["defaults"]), d.bool(m["variadic"]), d.stmts(m["body"])}
+		}
+	case "List":
+		{
+			return List{d.token(m["bracket"]), d.exprs(m["elements"])}
+		}
+	case "Literal":
+		{
+			return d.literal(m)
+		}
+	case "Logical":
+		{
+			return Logical{d.expr(m["left"]), d.token(m["operator"]), d.expr(m["right"])}
+		}
+	case "Map":
+		{
+			return Map{d.token(m["brace"]), d.exprs(m["keys"]), d.exprs(m["values"])}
+		}
+	case "Record":
+		{
+			return Record{d.token(m["brace"]), d.tokens(m["names"]), d.exprs(m["values"])}
+		}
+	case "Set":
+		{
+			return Set{d.expr(m["object"]), d.token(m["name"]), d.expr(m["value"]), d.token(m["operator"])}
+		}
+	case "SuperExpr":
+		{
+			return SuperExpr{d.token(m["token"]), d.token(m["method"]), newSlot(), newSlot()}
+		}
+	case "Ternary":
+		{
+			return Ternary{d.expr(m["condition"]), d.expr(m["then"]), d.expr(m["else"])}
+		}
+	case "ThisExpr":
+		{
+			return ThisExpr{d.token(m["token"]), newSlot()}
+		}
+	case "Unary":
+		{
+			return Unary{d.token(m["operator"]), d.expr(m["right"])}
+		}
+	case "Variable":
+		{
+			return Variable{d.token(m["name"]), newSlot()}
+		}
+	}
+
+	d.fail("unknown expression type %v", m["type"])
+
+	return Literal{}
+}
+
+func (d *jsonDecoder) stmt(v interface{}) Stmt {
+	if v == nil {
+		return nil
+	}
+
+	m := d.object(v)
+
+	switch m["type"] {
+	case "Block":
+		{
+			return Block{d.stmts(m["stmts"])}
+		}
+	case "BreakStmt":
+		{
+			return BreakStmt{d.token(m["token"]), d.token(m["label"])}
+		}
+	case "ClassStmt":
+		{
+			return ClassStmt{d.token(m["name"]), d.expr(m["superclass"]), d.functions(m["methods"]), d.functions(m["statics"]), d.fields(m["fields"]), newSlot()}
+		}
+	case "ContinueStmt":
+		{
+			return ContinueStmt{d.token(m["token"]), d.token(m["label"])}
+		}
+	case "Declaration":
+		{
+			return Declaration{d.token(m["name"]), d.expr(m["value"]), d.bool(m["const"]), newSlot()}
+		}
+	case "DeferStmt":
+		{
+			return DeferStmt{d.token(m["token"]), d.stmt(m["stmt"])}
+		}
+	case "Destructuring":
+		{
+			names := d.tokens(m["names"])
+			slots := make([]*Slot, len(names))
+			for j := range slots {
+				slots[j] = newSlot()
+			}
+
+			return Destructuring{d.token(m["token"]), names, d.expr(m["value"]), d.bool(m["const"]), slots}
+		}
+	case "EnumStmt":
+		{
+			return EnumStmt{d.token(m["name"]), d.tokens(m["members"]), newSlot()}
+		}
+	case "ForIn":
+		{
+			return ForIn{d.token(m["token"]), d.token(m["name"]), d.expr(m["iterable"]), d.stmt(m["body"])}
+		}
+	case "ForStmt":
+		{
+			return ForStmt{d.token(m["token"]), d.stmt(m["init"]), d.expr(m["condition"]), d.expr(m["increment"]), d.stmt(m["body"])}
+		}
+	case "Function":
+		{
+			return d.function(m)
+		}
+	case "IfStmt":
+		{
+			return IfStmt{d.token(m["token"]), d.expr(m["condition"]), d.stmt(m["then"]), d.stmt(m["else"])}
+		}
+	case "ImportStmt":
+		{
+			return ImportStmt{d.token(m["token"]), d.string(m["path"]), d.stmts(m["stmts"])}
+		}
+	case "Labeled":
+		{
+			return Labeled{d.token(m["label"]), d.stmt(m["loop"])}
+		}
+	case "ExprStmt":
+		{
+			return ExprStmt{d.expr(m["expr"])}
+		}
+	case "PrintStmt":
+		{
+			return PrintStmt{d.token(m["token"]), d.expr(m["expr"])}
+		}
+	case "ReturnStmt":
+		{
+			return ReturnStmt{d.token(m["token"]), d.expr(m["value"])}
+		}
+	case "SwitchStmt":
+		{
+			var cases []CaseClause
+			if l := d.list(m["cases"]); l != nil {
+				cases = make([]CaseClause, len(l))
+				for i, c := range l {
+					clause := d.object(c)
+					cases[i] = CaseClause{d.expr(clause["value"]), d.block(clause["body"])}
+				}
+			}
+
+			return SwitchStmt{d.token(m["token"]), d.expr(m["discriminant"]), cases, d.stmt(m["default"])}
+		}
+	case "ThrowStmt":
+		{
+			return ThrowStmt{d.token(m["token"]), d.expr(m["value"])}
+		}
+	case "TryStmt":
+		{
+			return TryStmt{d.block(m["body"]), d.token(m["name"]), d.block(m["catch"])}
+		}
+	case "DoWhile":
+		{
+			return DoWhile{d.token(m["token"]), d.stmt(m["body"]), d.expr(m["condition"])}
+		}
+	case "WhileStmt":
+		{
+			return WhileStmt{d.token(m["token"]), d.expr(m["condition"]), d.stmt(m["body"])}
+		}
+	}
+
+	d.fail("unknown statement type %v", m["type"])
+
+	return Block{}
+}
@@ -0,0 +1,222 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sample = `
+class Point {
+	var dims = 2;
+	init(x, y) { this.x = x; this.y = y; }
+	class origin() { return Point(0, 0); }
+	norm { return (this.x ** 2 + this.y ** 2) ** 0.5; }
+}
+
+class Origin < Point { norm { return super.norm; } }
+
+fun sum(first, second = 2, ...rest) {
+	var total = first + second;
+	for (var i = 0; i < len(rest); i = i + 1) {
+		if (rest[i] == nil) continue; else total += rest[i];
+	}
+	return total;
+}
+
+const PI = 3.14;
+var square = fun (x) { return x * x; };
+var values = [1, 0x10, 1_000, "a", true, nil];
+var table = {"k": -values[0], "pi": PI};
+values[1] *= 2;
+table["k"] = !false ? values : nil;
+
+while (Origin(0, 0) is Point) { break; }
+for (p in [Point(1, 2)]) continue;
+var maybe = nil?.k ?? table;
+var point = {x: 1, y: -2};
+point.x += point.y;
+do point.y += 1; while (point.y < 0);
+rows: for (r in [[1], [2]]) for (x in r) if (x > 1) break rows; else continue rows;
+const q, r = [7, 3];
+enum Color { RED, GREEN }
+fun closing(f) { defer close(f); return f; }
+
+switch (values[0]) {
+	case 1: { print "one"; }
+	default: { print "many"; }
+}
+
+try {
+	throw "oops";
+} catch (e) {
+	if (e or (e and 1)) print e;
+}
+
+print sum(1, 2, 3, 4) + square((2)) + Point(3, 4).norm;
+`
+
+func TestJSON_RoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "printer", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string]string{"sample": sample}
+	for _, file := range files {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sources[filepath.Base(file)] = string(source)
+	}
+
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			stmts, err := parse(source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			data, err := ToJSON(stmts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := FromJSON(data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(stmts, got) {
+				t.Errorf("want %s, got %s", PrintStmts(stmts), PrintStmts(got))
+			}
+		})
+	}
+}
+
+func TestJSON_Run(t *testing.T) {
+	stmts, err := parse(sample)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := ToJSON(stmts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decoded, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	i := &Interpreter{stdout: stdout}
+	i.Install(StdLib...)
+	if err := i.Run(decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "one\noops\n19\n"; stdout.String() != want {
+		t.Errorf("want %q, got %q", want, stdout.String())
+	}
+}
+
+func TestJSON_Numbers(t *testing.T) {
+	for _, n := range []float64{math.Inf(1), math.Inf(-1), math.MaxFloat64} {
+		stmts := []Stmt{PrintStmt{Token{}, Literal{n}}}
+
+		data, err := ToJSON(stmts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := FromJSON(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(stmts, got) {
+			t.Errorf("want %v, got %v", stmts, got)
+		}
+	}
+}
+
+func TestJSON_Errors(t *testing.T) {
+	table := []string{
+		`{`,
+		`{"type": "Block"}`,
+		`[{"type": "Unknown"}]`,
+		`[{"type": "PrintStmt", "expr": {"type": "Unknown"}}]`,
+		`[{"type": "PrintStmt", "expr": {"type": "Literal", "kind": "number", "value": "one"}}]`,
+		`[{"type": "BreakStmt", "token": {"type": "NOPE", "lexeme": "break", "literal": "", "line": 1, "column": 1}}]`,
+		`[{"type": "TryStmt", "body": {"type": "PrintStmt"}}]`,
+	}
+
+	for _, in := range table {
+		t.Run(in, func(t *testing.T) {
+			if _, err := FromJSON([]byte(in)); err == nil {
+				t.Errorf("want error, got nil")
+			}
+		})
+	}
+
+	if _, err := ToJSON([]Stmt{PrintStmt{Token{}, Literal{&ListValue{}}}}); err == nil {
+		t.Errorf("want error, got nil")
+	}
+}
+
+func TestJSON_Comments(t *testing.T) {
+	scanner := Scanner{"print 1;\n// last"}
+	tokens, err := scanner.ScanComments()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := ToJSON(stmts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decoded, err := FromJSON(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want, out := "(print 1)", PrintStmts(decoded); out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
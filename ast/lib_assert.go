@@ -0,0 +1,53 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "fmt"
+
+// AssertLib provides assert and assertEqual for test scripts, it is not
+// part of StdLib.
+func AssertLib(i *Interpreter) {
+	i.define("assert", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		if !truthy(arguments[0]) {
+			return nil, fmt.Errorf("assertion failed, got %s", repr(arguments[0]))
+		}
+
+		return nil, nil
+	})
+
+	// the values are compared like ==, instances may overload it with __eq__
+	i.define("assertEqual", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		operator := i.site
+		operator.TokenType, operator.Lexeme = EqualEqual, "=="
+
+		if err := i.binary(operator, Literal{arguments[0]}, Literal{arguments[1]}); err != nil {
+			return nil, err
+		}
+
+		if !truthy(i.Literal.Value) {
+			return nil, fmt.Errorf("assertion failed, %s != %s", repr(arguments[0]), repr(arguments[1]))
+		}
+
+		return nil, nil
+	})
+}
@@ -0,0 +1,69 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestAssertLib(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`assert(true); assert(1); assert("");`, ""},
+		{`assertEqual(1 + 1, 2); assertEqual("a", "a"); assertEqual(nil, nil);`, ""},
+		{`assert(1 > 2);`, "error at line 1, col 7: assert: assertion failed, got false"},
+		{`assert(nil);`, "error at line 1, col 7: assert: assertion failed, got nil"},
+		{`assertEqual(1 + 1, 3);`, "error at line 1, col 12: assertEqual: assertion failed, 2 != 3"},
+		{`assertEqual("a", 1);`, "error at line 1, col 12: assertEqual: assertion failed, \"a\" != 1"},
+		{`assertEqual([1], [1]);`, "error at line 1, col 12: assertEqual: assertion failed, [1] != [1]"},
+		{"class P { init(x) { this.x = x; } __eq__(o) { return this.x == o.x; } }\nassertEqual(P(1), P(1));", ""},
+		{"class P { init(x) { this.x = x; } __eq__(o) { return this.x == o.x; } }\nassertEqual(P(1), P(2));", "error at line 2, col 12: assertEqual: assertion failed, <P instance> != <P instance>"},
+		{"class P { __eq__(o) { return o.x; } }\nassertEqual(P(), 1);", "error at line 1, col 32: invalid property: x"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i := NewInterpreter()
+			i.Install(AssertLib)
+
+			err := exec(i, test.in)
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			if _, ok := err.(*RuntimeError); !ok || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestAssertLib_NotInstalled(t *testing.T) {
+	if _, err := output("assert(true);"); err == nil {
+		t.Error("assert is not part of the standard library")
+	}
+}
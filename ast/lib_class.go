@@ -0,0 +1,83 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ClassLib provides classOf, which returns the class of an instance,
+// superclassOf, which returns the superclass of a class or nil, and
+// methodNames, which lists the methods instances of a class respond to,
+// inherited ones included, in alphabetical order.
+func ClassLib(i *Interpreter) {
+	i.define("classOf", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		instance, ok := arguments[0].(*ClassInstance)
+		if !ok {
+			return nil, fmt.Errorf("argument 1 must be an instance, got %T", arguments[0])
+		}
+
+		return instance.Class, nil
+	})
+
+	i.define("superclassOf", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		c, err := class(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// a nil *ClassValue would not be nil in Lox
+		if c.Superclass == nil {
+			return nil, nil
+		}
+
+		return c.Superclass, nil
+	})
+
+	i.define("methodNames", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		c, err := class(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool)
+		var names []string
+		for class := c; class != nil; class = class.Superclass {
+			for name := range class.Methods {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Strings(names)
+
+		elements := make([]interface{}, len(names))
+		for j, name := range names {
+			elements[j] = name
+		}
+
+		return &ListValue{elements}, nil
+	})
+}
@@ -0,0 +1,93 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestClassLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`make(Point, 1, 2).x`, "1"},
+		{`make(Point3, 1, 2).z`, "0"},
+		{`map([Point, Point3], fun (k) { return make(k, 1, 2); })`, "[<Point instance>, <Point3 instance>]"},
+		{`classOf(Point(1, 2))`, "<class Point>"},
+		{`classOf(Point3(1, 2)) == Point3`, "true"},
+		{`classOf(Point3(1, 2))(5, 6).y`, "6"},
+		{`superclassOf(Point3)`, "<class Point>"},
+		{`superclassOf(Point)`, "nil"},
+		{`superclassOf(superclassOf(Point3)) == nil`, "true"},
+		{`methodNames(Point)`, `["init", "norm", "sum"]`},
+		{`methodNames(Point3)`, `["init", "norm", "sum", "top"]`},
+		{`methodNames(Empty)`, "[]"},
+	}
+
+	prelude := `class Point {
+		init(x, y) { this.x = x; this.y = y; }
+		sum() { return this.x + this.y; }
+		norm { return this.sum(); }
+		class origin() { return Point(0, 0); }
+	}
+	class Point3 < Point {
+		init(x, y) { super.init(x, y); this.z = 0; }
+		top() { return this.z; }
+	}
+	class Empty {}
+	fun make(k, x, y) { return k(x, y); }
+	`
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(prelude + "print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestClassLib_Errors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`classOf(C);`, "error at line 2, col 8: classOf: argument 1 must be an instance, got *ast.ClassValue"},
+		{`classOf({x: 1});`, "error at line 2, col 8: classOf: argument 1 must be an instance, got *ast.RecordValue"},
+		{`superclassOf(C());`, "error at line 2, col 13: superclassOf: argument 1 must be a class, got *ast.ClassInstance"},
+		{`methodNames("C");`, "error at line 2, col 12: methodNames: argument 1 must be a class, got string"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output("class C {}\n" + test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// CoreLib provides type, deepEqual, shallowCopy, deepCopy and the toNumber,
+// toString, toHex, toExp and bool conversions.
+func CoreLib(i *Interpreter) {
+	i.define("type", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return typeName(arguments[0]), nil
+	})
+
+	i.define("toNumber", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		switch v := arguments[0].(type) {
+		case float64:
+			return v, nil
+		case string:
+			n, ok := parseNumber(v)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert %q to a number", v)
+			}
+
+			return n, nil
+		}
+
+		return nil, fmt.Errorf("argument 1 must be a number or a string, got %T", arguments[0])
+	})
+
+	// values are rendered as print renders them
+	i.define("toString", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return i.stringify(i.site, Literal{arguments[0]})
+	})
+
+	// integers of any magnitude, like 0xFF or -0x10
+	i.define("toHex", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		n, err := number(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if n != math.Trunc(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("argument 1 must be an integer, got %v", Literal{n})
+		}
+
+		x, _ := big.NewFloat(n).Int(nil)
+		if x.Sign() < 0 {
+			return "-0x" + strings.ToUpper(x.Neg(x).Text(16)), nil
+		}
+
+		return "0x" + strings.ToUpper(x.Text(16)), nil
+	})
+
+	// scientific notation, with as many digits as needed to round-trip
+	i.define("toExp", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		n, err := number(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return strconv.FormatFloat(n, 'e', -1, 64), nil
+	})
+
+	// values are truthy as in conditions
+	i.define("bool", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return truthy(arguments[0]), nil
+	})
+
+	i.define("deepEqual", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return deepEqual(arguments[0], arguments[1], make(map[[2]interface{}]bool)), nil
+	})
+
+	i.define("shallowCopy", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return copyValue(arguments[0], false, make(map[interface{}]interface{})), nil
+	})
+
+	i.define("deepCopy", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return copyValue(arguments[0], true, make(map[interface{}]interface{})), nil
+	})
+}
+
+// copyValue returns a new list, map, record or instance with the elements
+// or fields of v, copied in turn if deep, other values as they are. Copies
+// already made are taken from copies, so cycles are copied as cycles.
+func copyValue(v interface{}, deep bool, copies map[interface{}]interface{}) interface{} {
+	element := func(e interface{}) interface{} {
+		if !deep {
+			return e
+		}
+
+		return copyValue(e, deep, copies)
+	}
+
+	switch x := v.(type) {
+	case *ListValue:
+		{
+			if c, ok := copies[x]; ok {
+				return c
+			}
+
+			l := &ListValue{make([]interface{}, len(x.Elements))}
+			copies[x] = l
+			for j, e := range x.Elements {
+				l.Elements[j] = element(e)
+			}
+
+			return l
+		}
+	case *MapValue:
+		{
+			if c, ok := copies[x]; ok {
+				return c
+			}
+
+			m := NewMapValue()
+			copies[x] = m
+			for _, k := range x.Keys() {
+				e, _ := x.Get(k)
+				m.Set(k, element(e))
+			}
+
+			return m
+		}
+	case *RecordValue:
+		{
+			if c, ok := copies[x]; ok {
+				return c
+			}
+
+			r := NewRecordValue()
+			copies[x] = r
+			for _, name := range x.names {
+				r.define(name, element(x.fields[name]))
+			}
+
+			return r
+		}
+	case *ClassInstance:
+		{
+			if c, ok := copies[x]; ok {
+				return c
+			}
+
+			instance := &ClassInstance{x.Class, make(map[string]Literal, len(x.Fields))}
+			copies[x] = instance
+			for name, f := range x.Fields {
+				instance.Fields[name] = Literal{element(f.Value)}
+			}
+
+			return instance
+		}
+	}
+
+	return v
+}
+
+// deepEqual compares lists element by element, maps key by key, records
+// and instances of the same class field by field, other values as == does.
+// Pairs already being compared are assumed equal, so cycles terminate.
+func deepEqual(a interface{}, b interface{}, seen map[[2]interface{}]bool) bool {
+	if isEqual(a, b) {
+		return true
+	}
+
+	pair := [2]interface{}{a, b}
+	if seen[pair] {
+		return true
+	}
+
+	switch x := a.(type) {
+	case *ListValue:
+		{
+			y, ok := b.(*ListValue)
+			if !ok || len(x.Elements) != len(y.Elements) {
+				return false
+			}
+
+			seen[pair] = true
+			for j := range x.Elements {
+				if !deepEqual(x.Elements[j], y.Elements[j], seen) {
+					return false
+				}
+			}
+
+			return true
+		}
+	case *MapValue:
+		{
+			y, ok := b.(*MapValue)
+			if !ok || x.Len() != y.Len() {
+				return false
+			}
+
+			seen[pair] = true
+			for _, k := range x.Keys() {
+				u, _ := x.Get(k)
+				v, ok := y.Get(k)
+				if !ok || !deepEqual(u, v, seen) {
+					return false
+				}
+			}
+
+			return true
+		}
+	case *RecordValue:
+		{
+			y, ok := b.(*RecordValue)
+			if !ok || len(x.names) != len(y.names) {
+				return false
+			}
+
+			seen[pair] = true
+			for _, name := range x.names {
+				v, ok := y.fields[name]
+				if !ok || !deepEqual(x.fields[name], v, seen) {
+					return false
+				}
+			}
+
+			return true
+		}
+	case *ClassInstance:
+		{
+			y, ok := b.(*ClassInstance)
+			if !ok || x.Class != y.Class || len(x.Fields) != len(y.Fields) {
+				return false
+			}
+
+			seen[pair] = true
+			for name, u := range x.Fields {
+				v, ok := y.Fields[name]
+				if !ok || !deepEqual(u.Value, v.Value, seen) {
+					return false
+				}
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// typeName returns the Lox name of the type of a runtime value.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *ListValue:
+		return "list"
+	case *MapValue:
+		return "map"
+	case *RecordValue:
+		return "record"
+	case *EnumValue:
+		return "enum"
+	case *EnumMember:
+		return "enum member"
+	case *ClassValue:
+		return "class"
+	case *ClassInstance:
+		return "instance"
+	case Callable:
+		return "function"
+	}
+
+	// values returned as they are by natives
+	return "object"
+}
+
+// parseNumber parses a number literal, with an optional sign and, unless in
+// hexadecimal or binary, an optional exponent as toString renders very large
+// or very small numbers, like -2e3. Unlike strconv.ParseFloat it rejects
+// what the scanner rejects, like NaN, Inf or 0x1p4.
+func parseNumber(s string) (float64, bool) {
+	literal := s
+	if strings.HasPrefix(literal, "-") || strings.HasPrefix(literal, "+") {
+		literal = literal[1:]
+	}
+
+	exponent := ""
+	if !strings.HasPrefix(literal, "0x") && !strings.HasPrefix(literal, "0X") {
+		if j := strings.IndexAny(literal, "eE"); j >= 0 {
+			literal, exponent = literal[:j], literal[j+1:]
+
+			digits := strings.TrimLeft(exponent, "+-")
+			if len(exponent)-len(digits) > 1 || digits == "" || strings.Trim(digits, "0123456789") != "" {
+				return 0, false
+			}
+		}
+	}
+
+	scanner := Scanner{literal}
+	tokens, err := scanner.Scan()
+	if err != nil || len(tokens) != 2 || tokens[0].TokenType != Number || tokens[0].Lexeme != literal {
+		return 0, false
+	}
+
+	text := tokens[0].Literal
+	if exponent != "" {
+		text += "e" + exponent
+	}
+
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if strings.HasPrefix(s, "-") {
+		n = -n
+	}
+
+	return n, true
+}
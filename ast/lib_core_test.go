@@ -0,0 +1,255 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestCoreLib_Bool(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`bool(0)`, "true"},
+		{`bool("")`, "true"},
+		{`bool([])`, "true"},
+		{`bool({})`, "true"},
+		{`bool(bool)`, "true"},
+		{`bool(true)`, "true"},
+		{`bool(false)`, "false"},
+		{`bool(nil)`, "false"},
+		{`bool(bool(nil))`, "false"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output("print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestCoreLib_Type(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`type(1.5)`, "number"},
+		{`type("hello")`, "string"},
+		{`type(true)`, "bool"},
+		{`type(nil)`, "nil"},
+		{`type(f)`, "function"},
+		{`type(fun () {})`, "function"},
+		{`type(len)`, "function"},
+		{`type(Point().norm)`, "function"},
+		{`type(Point)`, "class"},
+		{`type(Point())`, "instance"},
+		{`type([1, 2])`, "list"},
+		{`type({"a": 1})`, "map"},
+		{`type(type(1))`, "string"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output("fun f() {} class Point { norm() {} }\nprint " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestCoreLib_Conversions(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`toNumber("3.5") + 1`, "4.5"},
+		{`toNumber("-2e3")`, "-2000"},
+		{`toNumber(42)`, "42"},
+		{`toNumber("0xFF") + toNumber("0b101") + toNumber("1_000")`, "1260"},
+		{`toNumber("+1.5e-2")`, "0.015"},
+		{`toNumber(toString(10 ** 300)) == 10 ** 300`, "true"},
+		{`toString(true)`, "true"},
+		{`toString(nil)`, "nil"},
+		{`toString(3.5)`, "3.5"},
+		{`toString(10 ** 21)`, "1e+21"},
+		{`toString(2) + "!"`, "2!"},
+		{`toString([1, "a"])`, `[1, "a"]`},
+		{`toNumber(toString(0.1 + 0.2)) == 0.1 + 0.2`, "true"},
+		{`toHex(255)`, "0xFF"},
+		{`toHex(0)`, "0x0"},
+		{`toHex(-16)`, "-0x10"},
+		{`toHex(2 ** 64)`, "0x10000000000000000"},
+		{`toExp(12345.6)`, "1.23456e+04"},
+		{`toExp(0.00012)`, "1.2e-04"},
+		{`toExp(-3)`, "-3e+00"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output("print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestCoreLib_ConversionErrors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`toNumber("abc")`, `error at line 1, col 15: toNumber: cannot convert "abc" to a number`},
+		{`toNumber("")`, `error at line 1, col 15: toNumber: cannot convert "" to a number`},
+		{`toNumber("NaN")`, `error at line 1, col 15: toNumber: cannot convert "NaN" to a number`},
+		{`toNumber("Inf")`, `error at line 1, col 15: toNumber: cannot convert "Inf" to a number`},
+		{`toNumber("0x1p4")`, `error at line 1, col 15: toNumber: cannot convert "0x1p4" to a number`},
+		{`toNumber(" 1")`, `error at line 1, col 15: toNumber: cannot convert " 1" to a number`},
+		{`toNumber(".5")`, `error at line 1, col 15: toNumber: cannot convert ".5" to a number`},
+		{`toNumber("1e")`, `error at line 1, col 15: toNumber: cannot convert "1e" to a number`},
+		{`toNumber("--1")`, `error at line 1, col 15: toNumber: cannot convert "--1" to a number`},
+		{`toNumber("1e400")`, `error at line 1, col 15: toNumber: cannot convert "1e400" to a number`},
+		{`toNumber(true)`, "error at line 1, col 15: toNumber: argument 1 must be a number or a string, got bool"},
+		{`toHex(3.5)`, "error at line 1, col 12: toHex: argument 1 must be an integer, got 3.5"},
+		{`toHex(10 ** 400)`, "error at line 1, col 12: toHex: argument 1 must be an integer, got +Inf"},
+		{`toHex("ff")`, "error at line 1, col 12: toHex: argument 1 must be a number, got string"},
+		{`toExp(nil)`, "error at line 1, col 12: toExp: argument 1 must be a number, got <nil>"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output("print " + test.in + ";")
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestCoreLib_DeepEqual(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`deepEqual([1, [2, "a"]], [1, [2, "a"]])`, "true"},
+		{`deepEqual([1, 2], [1, 2, 3])`, "false"},
+		{`deepEqual([1, [2]], [1, [3]])`, "false"},
+		{`deepEqual({"a": [1], "b": 2}, {"b": 2, "a": [1]})`, "true"},
+		{`deepEqual({"a": 1}, {"b": 1})`, "false"},
+		{`deepEqual(P(1, [2]), P(1, [2]))`, "true"},
+		{`deepEqual(P(1, 2), P(1, 3))`, "false"},
+		{`deepEqual(P(1, 2), Q(1, 2))`, "false"},
+		{`deepEqual(1, 1) and !deepEqual(1, "1")`, "true"},
+		{`[1] == [1]`, "false"},
+		{`deepEqual(a, a) and deepEqual(a, b)`, "true"},
+		{`deepEqual(a, c)`, "false"},
+	}
+
+	// a and b are lists holding themselves, c holds a different tail
+	prelude := `class P { init(x, y) { this.x = x; this.y = y; } }
+	class Q < P {}
+	var a = [1];
+	push(a, a);
+	var b = [1];
+	push(b, b);
+	var c = [1, [2]];
+	`
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(prelude + "print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestCoreLib_Copy(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`shallowCopy(a)`, `[1, [2], {"k": [3]}]`},
+		{`shallowCopy(a) == a`, "false"},
+		{`shallowCopy(a)[1] == a[1]`, "true"},
+		{`deepCopy(a)[1] == a[1]`, "false"},
+		{`deepEqual(deepCopy(a), a)`, "true"},
+		{`fun () { var b = shallowCopy(a); push(b[1], 4); return a[1]; }()`, "[2, 4]"},
+		{`fun () { var b = deepCopy(a); push(b[1], 4); b[2]["k"] = 0; return a; }()`, `[1, [2], {"k": [3]}]`},
+		{`fun () { var b = shallowCopy(m); b["x"] = 0; return m["x"]; }()`, "[1]"},
+		{`shallowCopy(m)["x"] == m["x"] and deepCopy(m)["x"] != m["x"]`, "true"},
+		{`fun () { var q = shallowCopy(p); q.x = 0; return p.x; }()`, "1"},
+		{`shallowCopy(p).y == p.y and deepCopy(p).y != p.y`, "true"},
+		{`deepCopy(p) is P`, "true"},
+		{`fun () { var s = deepCopy(r); s.list[0] = 0; return r; }()`, "{name: \"r\", list: [1]}"},
+		{`deepCopy(c)[1] == c[1]`, "false"},
+		{`fun () { var d = deepCopy(c); return d[1] == d and d != c; }()`, "true"},
+		{`shallowCopy(1) + deepCopy(2)`, "3"},
+		{`deepCopy("s")`, "s"},
+		{`deepCopy(nil)`, "nil"},
+		{`deepCopy(P) == P`, "true"},
+	}
+
+	// c is a list holding itself
+	prelude := `class P { init(x, y) { this.x = x; this.y = y; } }
+	var a = [1, [2], {"k": [3]}];
+	var m = {"x": [1]};
+	var p = P(1, [2]);
+	var r = {name: "r", list: [1]};
+	var c = [1];
+	push(c, c);
+	`
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(prelude + "print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
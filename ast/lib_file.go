@@ -0,0 +1,98 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "errors"
+
+// FileLib provides readFile, which returns the contents of a file as a
+// string, writeFile, which replaces them, and appendFile, which adds to
+// them. They access the file system of the interpreter, and fail if it
+// has none.
+func FileLib(i *Interpreter) {
+	i.define("readFile", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		path, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fs, err := i.files()
+		if err != nil {
+			return nil, err
+		}
+
+		return fs.ReadFile(path)
+	})
+
+	i.define("writeFile", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		path, contents, err := fileArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		fs, err := i.files()
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fs.WriteFile(path, contents)
+	})
+
+	i.define("appendFile", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		path, contents, err := fileArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		fs, err := i.files()
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, fs.AppendFile(path, contents)
+	})
+}
+
+// fileArguments returns the path and the contents passed to writeFile and
+// appendFile.
+func fileArguments(arguments []interface{}) (string, string, error) {
+	path, err := str(arguments, 0)
+	if err != nil {
+		return "", "", err
+	}
+
+	contents, err := str(arguments, 1)
+	if err != nil {
+		return "", "", err
+	}
+
+	return path, contents, nil
+}
+
+// files returns the file system of the file natives.
+func (i *Interpreter) files() (FileSystem, error) {
+	if i.Files == nil {
+		return nil, errors.New("file access is disabled")
+	}
+
+	return i.Files, nil
+}
@@ -0,0 +1,149 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileLib(t *testing.T) {
+	table := []struct {
+		in   string
+		out  string
+		file string // contents of out.txt afterwards
+	}{
+		{`print readFile("in.txt");`, "hello\n", ""},
+		{`print readFile("./dir/../in.txt") + "!";`, "hello!\n", ""},
+		{`writeFile("out.txt", "a"); print readFile("out.txt");`, "a\n", "a"},
+		{`writeFile("out.txt", "a"); writeFile("out.txt", "b");`, "", "b"},
+		{`appendFile("out.txt", "a"); appendFile("out.txt", "b");`, "", "ab"},
+		{`writeFile("out.txt", readFile("in.txt") + "\n");`, "", "hello\n"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			var b bytes.Buffer
+
+			fs := MapFileSystem{"in.txt": "hello"}
+
+			i := NewInterpreter()
+			i.SetOutput(&b)
+			i.Files = fs
+
+			if err := exec(i, test.in); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b.String() != test.out {
+				t.Errorf("want %q, got %q", test.out, b.String())
+			}
+
+			if fs["out.txt"] != test.file {
+				t.Errorf("want out.txt to hold %q, got %q", test.file, fs["out.txt"])
+			}
+		})
+	}
+}
+
+func TestFileLib_Errors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`readFile("missing.txt");`, "error at line 1, col 9: readFile: open missing.txt: file does not exist"},
+		{`readFile(1);`, "error at line 1, col 9: readFile: argument 1 must be a string, got float64"},
+		{`writeFile("out.txt", nil);`, "error at line 1, col 10: writeFile: argument 2 must be a string, got <nil>"},
+		{`appendFile(nil, "a");`, "error at line 1, col 11: appendFile: argument 1 must be a string, got <nil>"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i := NewInterpreter()
+			i.Files = MapFileSystem{}
+
+			err := exec(i, test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+// without a file system, as in NewInterpreter
+func TestFileLib_Disabled(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`readFile("a");`, "error at line 1, col 9: readFile: file access is disabled"},
+		{`writeFile("a", "b");`, "error at line 1, col 10: writeFile: file access is disabled"},
+		{`appendFile("a", "b");`, "error at line 1, col 11: appendFile: file access is disabled"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestFileLib_OSFileSystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "log.txt")
+
+	i := NewInterpreter()
+	i.Files = OSFileSystem{}
+	i.SetGlobal("path", path)
+
+	if err := exec(i, `writeFile(path, "a"); appendFile(path, "b"); appendFile(path, "c");`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "abc" {
+		t.Errorf("want %q, got %q", "abc", b)
+	}
+
+	err = exec(i, `readFile(path + ".missing");`)
+	// the message of the operating system, with the path
+	if err == nil || !strings.Contains(err.Error(), "readFile: open "+path+".missing: ") {
+		t.Errorf("want a missing file error, got %v", err)
+	}
+}
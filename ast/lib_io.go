@@ -0,0 +1,75 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// IOLib provides readLine, which returns the next line of the input
+// without its line terminator, and readNumber, which parses it as a
+// number. Both return nil at the end of the input, Interpreter.Input.
+func IOLib(i *Interpreter) {
+	i.define("readLine", 0, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		line, ok, err := i.readLine()
+		if err != nil || !ok {
+			return nil, err
+		}
+
+		return line, nil
+	})
+
+	i.define("readNumber", 0, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		line, ok, err := i.readLine()
+		if err != nil || !ok {
+			return nil, err
+		}
+
+		n, ok := parseNumber(strings.TrimSpace(line))
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %q to a number", line)
+		}
+
+		return n, nil
+	})
+}
+
+// readLine reads a line of the input, ok is false at the end of it.
+func (i *Interpreter) readLine() (string, bool, error) {
+	line, err := i.input().ReadString('\n')
+	if err == io.EOF {
+		// the last line might not be terminated
+		if line == "" {
+			return "", false, nil
+		}
+	} else if err != nil {
+		return "", false, err
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+
+	return line, true, nil
+}
@@ -0,0 +1,99 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIOLib(t *testing.T) {
+	table := []struct {
+		in     string
+		source string
+		out    string
+	}{
+		{"hello\nworld\n", "print readLine(); print readLine(); print readLine();", "hello\nworld\nnil\n"},
+		{"first\r\nlast", "print readLine(); print readLine(); print readLine();", "first\nlast\nnil\n"},
+		{"\n\n", "print len(readLine()); print len(readLine()); print readLine();", "0\n0\nnil\n"},
+		{"3\n 4.5 \n", "print readNumber() + readNumber(); print readNumber();", "7.5\nnil\n"},
+		{"2\nlines\n", "print readNumber() * 2; print readLine();", "4\nlines\n"},
+		{"", "print readLine(); print readNumber();", "nil\nnil\n"},
+		{"-2e3\n0x1F\n", "print readNumber(); print readNumber();", "-2000\n31\n"},
+	}
+
+	for _, test := range table {
+		t.Run(test.source, func(t *testing.T) {
+			var b bytes.Buffer
+
+			i := NewInterpreter()
+			i.Input = strings.NewReader(test.in)
+			i.SetOutput(&b)
+
+			if err := exec(i, test.source); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b.String() != test.out {
+				t.Errorf("want %q, got %q", test.out, b.String())
+			}
+		})
+	}
+}
+
+func TestIOLib_Input(t *testing.T) {
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+
+	i.Input = strings.NewReader("one\ntwo\n")
+	if err := exec(i, "print readLine();"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the lines buffered from the first reader are dropped
+	i.Input = strings.NewReader("three\n")
+	if err := exec(i, "print readLine(); print readLine();"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "one\nthree\nnil\n"; b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+}
+
+func TestIOLib_ReadNumberError(t *testing.T) {
+	// only what the scanner reads as a number is converted
+	for _, line := range []string{"abc", "NaN", "Inf", "-inf", "0x1p4"} {
+		i := NewInterpreter()
+		i.SetInput(strings.NewReader(line + "\n"))
+
+		err := exec(i, "readNumber();")
+		if want := fmt.Sprintf("error at line 1, col 11: readNumber: cannot convert %q to a number", line); err == nil || err.Error() != want {
+			t.Errorf("want %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// JSONLib provides jsonParse and jsonStringify, which convert between JSON
+// text and Lox values: objects are maps, arrays lists and numbers floats.
+// Records are written as objects too.
+func JSONLib(i *Interpreter) {
+	i.define("jsonParse", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(s))
+
+		v, err := jsonValue(decoder)
+		if err == nil {
+			if _, end := decoder.Token(); end != io.EOF {
+				err = fmt.Errorf("unexpected data after the value")
+			}
+		}
+
+		if err == io.EOF {
+			err = fmt.Errorf("unexpected end of JSON input")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+
+		return v, nil
+	})
+
+	// the text is compact, map entries are written in insertion order
+	i.define("jsonStringify", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		var b strings.Builder
+		if err := writeJSON(&b, arguments[0], make(map[interface{}]bool)); err != nil {
+			return nil, err
+		}
+
+		return b.String(), nil
+	})
+}
+
+// jsonValue decodes the next value, objects are decoded token by token to
+// keep the order of their keys.
+func jsonValue(decoder *json.Decoder) (interface{}, error) {
+	t, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case json.Delim('['):
+		{
+			l := &ListValue{[]interface{}{}}
+			for decoder.More() {
+				v, err := jsonValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+
+				l.Elements = append(l.Elements, v)
+			}
+
+			_, err := decoder.Token()
+
+			return l, err
+		}
+	case json.Delim('{'):
+		{
+			m := NewMapValue()
+			for decoder.More() {
+				k, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+
+				v, err := jsonValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+
+				m.Set(MapKey{k}, v)
+			}
+
+			_, err := decoder.Token()
+
+			return m, err
+		}
+	}
+
+	// strings, numbers as float64, booleans and nil
+	return t, nil
+}
+
+// writeJSON writes the JSON text of v. Lists and maps being written are
+// in path, meeting one of them again means the value is cyclic.
+func writeJSON(b *strings.Builder, v interface{}, path map[interface{}]bool) error {
+	switch v := v.(type) {
+	case nil:
+		{
+			b.WriteString("null")
+		}
+	case bool:
+		{
+			b.WriteString(strconv.FormatBool(v))
+		}
+	case float64:
+		{
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return fmt.Errorf("cannot convert %v to JSON", Literal{v})
+			}
+
+			data, _ := json.Marshal(v)
+			b.Write(data)
+		}
+	case string:
+		{
+			b.WriteString(jsonString(v))
+		}
+	case *ListValue:
+		{
+			if path[v] {
+				return fmt.Errorf("cannot convert a cyclic list to JSON")
+			}
+
+			path[v] = true
+			defer delete(path, v)
+
+			b.WriteString("[")
+			for j, e := range v.Elements {
+				if j > 0 {
+					b.WriteString(",")
+				}
+
+				if err := writeJSON(b, e, path); err != nil {
+					return err
+				}
+			}
+			b.WriteString("]")
+		}
+	case *MapValue:
+		{
+			if path[v] {
+				return fmt.Errorf("cannot convert a cyclic map to JSON")
+			}
+
+			path[v] = true
+			defer delete(path, v)
+
+			b.WriteString("{")
+			for j, k := range v.Keys() {
+				if j > 0 {
+					b.WriteString(",")
+				}
+
+				// keys are always strings in JSON
+				b.WriteString(jsonString(Literal{k.Value}.String()))
+				b.WriteString(":")
+
+				e, _ := v.Get(k)
+				if err := writeJSON(b, e, path); err != nil {
+					return err
+				}
+			}
+			b.WriteString("}")
+		}
+	case *RecordValue:
+		{
+			if path[v] {
+				return fmt.Errorf("cannot convert a cyclic record to JSON")
+			}
+
+			path[v] = true
+			defer delete(path, v)
+
+			b.WriteString("{")
+			for j, name := range v.Names() {
+				if j > 0 {
+					b.WriteString(",")
+				}
+
+				b.WriteString(jsonString(name) + ":")
+				if err := writeJSON(b, v.fields[name], path); err != nil {
+					return err
+				}
+			}
+			b.WriteString("}")
+		}
+	default:
+		{
+			return fmt.Errorf("cannot convert a value of type %s to JSON", typeName(v))
+		}
+	}
+
+	return nil
+}
+
+// jsonString quotes s as a JSON string, leaving HTML characters as they are.
+func jsonString(s string) string {
+	var b bytes.Buffer
+
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
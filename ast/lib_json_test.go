@@ -0,0 +1,87 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestJSONLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`jsonParse("{\"a\": [1, 2.5, {\"b\": null}], \"c\": true}")`, `{"a": [1, 2.5, {"b": nil}], "c": true}`},
+		{`jsonParse("[]")`, "[]"},
+		{`jsonParse(" \"x\" ")`, "x"},
+		{`type(jsonParse("{}"))`, "map"},
+		{`jsonParse("{\"z\": 1, \"a\": 2}")["a"]`, "2"},
+		{`jsonStringify({"z": 1, "a": [true, nil, "q\"<"], 3: {}})`, `{"z":1,"a":[true,null,"q\"<"],"3":{}}`},
+		{`jsonStringify(0.1)`, "0.1"},
+		{`jsonStringify([])`, "[]"},
+		{`jsonStringify(jsonParse("{\"b\":[1,{\"c\":[]}],\"a\":\"é\"}"))`, `{"b":[1,{"c":[]}],"a":"é"}`},
+		{`deepEqual(jsonParse(jsonStringify(nested)), nested)`, "true"},
+		// shared values are not cycles
+		{`jsonStringify([shared, shared])`, "[[1],[1]]"},
+	}
+
+	prelude := `var nested = {"list": [1, [2, [3]], {"k": "v"}], "n": -1.5, "t": false};
+	var shared = [1];
+	`
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(prelude + "print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestJSONLib_Errors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`var l = [1]; push(l, l); jsonStringify(l);`, "error at line 1, col 39: jsonStringify: cannot convert a cyclic list to JSON"},
+		{`var m = {}; m["m"] = [m]; jsonStringify(m);`, "error at line 1, col 40: jsonStringify: cannot convert a cyclic map to JSON"},
+		{`jsonStringify([clock]);`, "error at line 1, col 14: jsonStringify: cannot convert a value of type function to JSON"},
+		{`class A {} jsonStringify({"a": A()});`, "error at line 1, col 25: jsonStringify: cannot convert a value of type instance to JSON"},
+		{`jsonParse("[1,");`, "error at line 1, col 10: jsonParse: invalid JSON: unexpected end of JSON input"},
+		{`jsonParse("");`, "error at line 1, col 10: jsonParse: invalid JSON: unexpected end of JSON input"},
+		{`jsonParse("1 2");`, "error at line 1, col 10: jsonParse: invalid JSON: unexpected data after the value"},
+		{`jsonParse(1);`, "error at line 1, col 10: jsonParse: argument 1 must be a string, got float64"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxRange is the most numbers range counts.
+const maxRange = 1 << 24
+
+// ListLib provides map, filter and reduce, which call back a function
+// for every element of a list in order, and push, pop, insert and remove,
+// which change a list in place, and range, which counts. sort returns a
+// sorted copy of a list and sortInPlace sorts the list itself, both are
+// stable.
+func ListLib(i *Interpreter) {
+	i.define("map", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fn, err := callable(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		mapped := make([]interface{}, 0, len(l.Elements))
+		for _, e := range l.Elements {
+			v, err := i.callback(fn, e)
+			if err != nil {
+				return nil, err
+			}
+
+			mapped = append(mapped, v)
+		}
+
+		return &ListValue{mapped}, nil
+	})
+
+	i.define("filter", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fn, err := callable(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		filtered := []interface{}{}
+		for _, e := range l.Elements {
+			v, err := i.callback(fn, e)
+			if err != nil {
+				return nil, err
+			}
+
+			if truthy(v) {
+				filtered = append(filtered, e)
+			}
+		}
+
+		return &ListValue{filtered}, nil
+	})
+
+	i.define("reduce", 3, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		fn, err := callable(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		accumulator := arguments[2]
+		for _, e := range l.Elements {
+			if accumulator, err = i.callback(fn, accumulator, e); err != nil {
+				return nil, err
+			}
+		}
+
+		return accumulator, nil
+	})
+
+	i.define("push", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		l.Elements = append(l.Elements, arguments[1])
+
+		return nil, nil
+	})
+
+	i.define("pop", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(l.Elements) == 0 {
+			return nil, fmt.Errorf("pop from an empty list")
+		}
+
+		last := l.Elements[len(l.Elements)-1]
+		l.Elements = l.Elements[:len(l.Elements)-1]
+
+		return last, nil
+	})
+
+	i.define("insert", 3, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// inserting past the last element appends, index errors are
+		// located at the call of the native
+		j := len(l.Elements)
+		if arguments[1] != float64(j) {
+			if j, err = l.index(Token{}, arguments[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		l.Elements = append(l.Elements, nil)
+		copy(l.Elements[j+1:], l.Elements[j:])
+		l.Elements[j] = arguments[2]
+
+		return nil, nil
+	})
+
+	i.define("remove", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		j, err := l.index(Token{}, arguments[1])
+		if err != nil {
+			return nil, err
+		}
+
+		removed := l.Elements[j]
+		l.Elements = append(l.Elements[:j], l.Elements[j+1:]...)
+
+		return removed, nil
+	})
+
+	// range(stop), range(start, stop) and range(start, stop, step) list
+	// the numbers from start, 0 if omitted, up to but excluding stop
+	i.defineVariadic("range", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		if len(arguments) > 3 {
+			return nil, fmt.Errorf("expected at most 3 arguments but got %d", len(arguments))
+		}
+
+		bounds := []int{0, 0, 1}
+		for j := range arguments {
+			n, err := integer(arguments, j)
+			if err != nil {
+				return nil, err
+			}
+
+			bounds[j] = n
+		}
+
+		start, stop, step := bounds[0], bounds[1], bounds[2]
+		if len(arguments) == 1 {
+			start, stop = 0, bounds[0]
+		}
+
+		if step == 0 {
+			return nil, fmt.Errorf("step must not be zero")
+		}
+
+		count := 0
+		if step > 0 && stop > start {
+			count = (stop - start + step - 1) / step
+		} else if step < 0 && stop < start {
+			count = (start - stop - step - 1) / -step
+		}
+
+		if count > maxRange {
+			return nil, fmt.Errorf("range of %d numbers is longer than %d", count, maxRange)
+		}
+
+		numbers := make([]interface{}, 0, count)
+		for n := start; (step > 0 && n < stop) || (step < 0 && n > stop); n += step {
+			numbers = append(numbers, float64(n))
+		}
+
+		return &ListValue{numbers}, nil
+	})
+
+	i.defineVariadic("sort", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, cmp, err := sortArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		elements := make([]interface{}, len(l.Elements))
+		copy(elements, l.Elements)
+
+		if err := i.sort(elements, cmp); err != nil {
+			return nil, err
+		}
+
+		return &ListValue{elements}, nil
+	})
+
+	i.defineVariadic("sortInPlace", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, cmp, err := sortArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, i.sort(l.Elements, cmp)
+	})
+}
+
+// sortArguments returns the list and the optional comparator passed to
+// the sort natives.
+func sortArguments(arguments []interface{}) (*ListValue, Callable, error) {
+	if len(arguments) > 2 {
+		return nil, nil, fmt.Errorf("expected at most 2 arguments but got %d", len(arguments))
+	}
+
+	l, err := list(arguments, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(arguments) == 1 {
+		return l, nil, nil
+	}
+
+	cmp, err := callable(arguments, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return l, cmp, nil
+}
+
+// sort sorts elements stably, by cmp if not nil, which returns a negative
+// number if its first argument comes first, 0 if the two are equal and a
+// positive number otherwise. Without it, elements must be all numbers or
+// all strings and are sorted in ascending order.
+func (i *Interpreter) sort(elements []interface{}, cmp Callable) error {
+	if cmp == nil {
+		for _, e := range elements {
+			switch e.(type) {
+			case float64, string:
+				{
+					if typeName(e) != typeName(elements[0]) {
+						return fmt.Errorf("cannot sort %ss and %ss without a comparator", typeName(elements[0]), typeName(e))
+					}
+				}
+			default:
+				{
+					return fmt.Errorf("cannot sort values of type %s without a comparator", typeName(e))
+				}
+			}
+		}
+
+		sort.SliceStable(elements, func(a, b int) bool {
+			if s, ok := elements[a].(string); ok {
+				return s < elements[b].(string)
+			}
+
+			return elements[a].(float64) < elements[b].(float64)
+		})
+
+		return nil
+	}
+
+	// the first error stops the comparisons, the order is then partial
+	var err error
+	sort.SliceStable(elements, func(a, b int) bool {
+		if err != nil {
+			return false
+		}
+
+		v, e := i.callback(cmp, elements[a], elements[b])
+		if e != nil {
+			err = e
+			return false
+		}
+
+		n, ok := v.(float64)
+		if !ok {
+			err = fmt.Errorf("comparator must return a number, got %s", typeName(v))
+			return false
+		}
+
+		return n < 0
+	})
+
+	return err
+}
@@ -0,0 +1,183 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestListLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`map([1, 2, 3], fun (x) { return x * 2; })`, "[2, 4, 6]"},
+		{`map([], fun (x) { return x * 2; })`, "[]"},
+		{`map(["a", "b"], toUpper)`, `["A", "B"]`},
+		{`filter([1, 2, 3, 4, 5, 6], fun (x) { return x % 2 == 0; })`, "[2, 4, 6]"},
+		{`filter([1, nil, false, 0], fun (x) { return x; })`, "[1, 0]"},
+		{`reduce([1, 2, 3, 4], fun (sum, x) { return sum + x; }, 0)`, "10"},
+		{`reduce([], fun (sum, x) { return sum + x; }, 0)`, "0"},
+		{`reduce(["a", "b"], fun (s, x) { return s + x; }, ">")`, ">ab"},
+		{`map([1, 2], adder(10))`, "[11, 12]"},
+		{`reduce(map(filter([1, 2, 3, 4], even), square), add, 0)`, "20"},
+		{`range(3)`, "[0, 1, 2]"},
+		{`range(0)`, "[]"},
+		{`range(-2)`, "[]"},
+		{`range(2, 5)`, "[2, 3, 4]"},
+		{`range(1, 10, 2)`, "[1, 3, 5, 7, 9]"},
+		{`range(5, 0, -1)`, "[5, 4, 3, 2, 1]"},
+		{`range(0, 5, -1)`, "[]"},
+		{`range(0, 1000000000000, 999999999999)`, "[0, 999999999999]"},
+		{`range(5, 0, -2)`, "[5, 3, 1]"},
+		{`reduce(range(1, 5), add, 0)`, "10"},
+		{`sort([3, 1, 2.5, -4])`, "[-4, 1, 2.5, 3]"},
+		{`sort(["b", "c", "a"])`, `["a", "b", "c"]`},
+		{`sort([])`, "[]"},
+		{`sort([3, 1, 2], fun (a, b) { return b - a; })`, "[3, 2, 1]"},
+		{`sort([[1, "b"], [0, "c"], [1, "a"]], fun (a, b) { return a[0] - b[0]; })`, `[[0, "c"], [1, "b"], [1, "a"]]`},
+	}
+
+	prelude := `fun adder(n) { return fun (x) { return x + n; }; }
+	fun even(x) { return x % 2 == 0; }
+	fun square(x) { return x * x; }
+	fun add(a, b) { return a + b; }
+	`
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(prelude + "print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestListLib_Errors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`map(1, toString);`, "error at line 1, col 4: map: argument 1 must be a list, got float64"},
+		{`map([1], 1);`, "error at line 1, col 4: map: argument 2 must be a function, got float64"},
+		{`map([1], fun (a, b) { return a; });`, "error at line 1, col 4: map: expected 2 arguments but got 1"},
+		{`filter([0], fun (x) { return 1 / x; });`, "error at line 1, col 32: division by zero"},
+		{`reduce(["a"], fun (s, x) { return s - x; }, 0);`, "error at line 1, col 37: invalid operands for binary -: float64, string"},
+		{`map([true], toNumber);`, "error at line 1, col 4: toNumber: argument 1 must be a number or a string, got bool"},
+		{`range(1, 5, 0);`, "error at line 1, col 6: range: step must not be zero"},
+		{`range(1.5);`, "error at line 1, col 6: range: argument 1 must be an integer, got 1.5"},
+		{`range("3");`, "error at line 1, col 6: range: argument 1 must be a number, got string"},
+		{`range(1, 2, 3, 4);`, "error at line 1, col 6: range: expected at most 3 arguments but got 4"},
+		{`range(0, 100000000000000000000);`, "error at line 1, col 6: range: argument 2 is out of range, got 100000000000000000000"},
+		{`range(-(10 ** 400));`, "error at line 1, col 6: range: argument 1 is out of range, got -Inf"},
+		{`range(0, 1000000000000);`, "error at line 1, col 6: range: range of 1000000000000 numbers is longer than 16777216"},
+		{`range(1000000000000, 0, -1);`, "error at line 1, col 6: range: range of 1000000000000 numbers is longer than 16777216"},
+		{`sort([1, "a"]);`, "error at line 1, col 5: sort: cannot sort numbers and strings without a comparator"},
+		{`sort([nil]);`, "error at line 1, col 5: sort: cannot sort values of type nil without a comparator"},
+		{`sort([1, 2], fun (a, b) { return "x"; });`, "error at line 1, col 5: sort: comparator must return a number, got string"},
+		{`sort([1, 2], fun (a, b) { return a.x; });`, "error at line 1, col 36: invalid property: x"},
+		{`sort([1], 1);`, "error at line 1, col 5: sort: argument 2 must be a function, got float64"},
+		{`sort([1], toString, 1);`, "error at line 1, col 5: sort: expected at most 2 arguments but got 3"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestListLib_Throw(t *testing.T) {
+	out, err := output(`try {
+		map([1, 2], fun (x) { if (x == 2) throw "two"; return x; });
+	} catch (e) {
+		print e;
+	}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out != "two\n" {
+		t.Errorf("want %q, got %q", "two\n", out)
+	}
+}
+
+func TestListLib_Mutation(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`push(a, 4); print b;`, "[1, 2, 3, 4]"},
+		{`print pop(a); print b;`, "3\n[1, 2]"},
+		{`insert(a, 0, 0); print b;`, "[0, 1, 2, 3]"},
+		{`insert(a, 1, "x"); print b;`, `[1, "x", 2, 3]`},
+		{`insert(a, 3, 4); print b;`, "[1, 2, 3, 4]"},
+		{`print remove(a, 1); print b;`, "2\n[1, 3]"},
+		{`push(a, pop(b)); push(a, remove(b, 0)); print a;`, "[2, 3, 1]"},
+		{`while (len(a) > 0) pop(a); print b;`, "[]"},
+		{`print sort(a, fun (x, y) { return y - x; }); print b;`, "[3, 2, 1]\n[1, 2, 3]"},
+		{`sortInPlace(a, fun (x, y) { return y - x; }); print b;`, "[3, 2, 1]"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output("var a = [1, 2, 3]; var b = a;\n" + test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestListLib_MutationErrors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`pop([]);`, "error at line 1, col 4: pop: pop from an empty list"},
+		{`pop(nil);`, "error at line 1, col 4: pop: argument 1 must be a list, got <nil>"},
+		{`insert([1], 2, 0);`, "error at line 1, col 7: insert: list index 2 out of bounds [0, 1)"},
+		{`insert([1], -1, 0);`, "error at line 1, col 7: insert: negative list index -1"},
+		{`remove([], 0);`, "error at line 1, col 7: remove: list index 0 out of bounds [0, 0)"},
+		{`remove([1], 0.5);`, "error at line 1, col 7: remove: list index must be an integer, got 0.5"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// MapLib provides keys and values, which list the entries of a map in
+// insertion order, has, which tells whether a map has a key, and delete,
+// which removes an entry in place.
+func MapLib(i *Interpreter) {
+	i.define("keys", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		m, err := mapping(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]interface{}, 0, m.Len())
+		for _, k := range m.Keys() {
+			keys = append(keys, k.Value)
+		}
+
+		return &ListValue{keys}, nil
+	})
+
+	i.define("values", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		m, err := mapping(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]interface{}, 0, m.Len())
+		for _, k := range m.Keys() {
+			v, _ := m.Get(k)
+			values = append(values, v)
+		}
+
+		return &ListValue{values}, nil
+	})
+
+	i.define("has", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		m, err := mapping(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := NewMapKey(i.site, arguments[1])
+		if err != nil {
+			return nil, err
+		}
+
+		_, ok := m.Get(k)
+
+		return ok, nil
+	})
+
+	i.define("delete", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		m, err := mapping(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		k, err := NewMapKey(i.site, arguments[1])
+		if err != nil {
+			return nil, err
+		}
+
+		m.Delete(k)
+
+		return nil, nil
+	})
+}
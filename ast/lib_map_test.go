@@ -0,0 +1,100 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestMapLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`keys(m)`, `["b", 1, "a"]`},
+		{`values(m)`, `[2, "one", 3]`},
+		{`keys({})`, "[]"},
+		{`values({})`, "[]"},
+		{`has(m, "a")`, "true"},
+		{`has(m, 1)`, "true"},
+		{`has(m, "1")`, "false"},
+		{`has(m, "c")`, "false"},
+		{`delete(m, "b")`, "nil"},
+	}
+
+	prelude := `var m = {"b": 2, 1: "one", "a": 3};
+	`
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output(prelude + "print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestMapLib_Delete(t *testing.T) {
+	source := `var m = {"a": 1, "b": 2, "c": 3};
+delete(m, "b");
+delete(m, "missing");
+print has(m, "b");
+print keys(m);
+print values(m);
+m["b"] = 4;
+print keys(m);`
+
+	out, err := output(source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "false\n[\"a\", \"c\"]\n[1, 3]\n[\"a\", \"c\", \"b\"]\n"
+	if out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestMapLib_Errors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`keys([1]);`, "error at line 1, col 5: keys: argument 1 must be a map, got *ast.ListValue"},
+		{`values(nil);`, "error at line 1, col 7: values: argument 1 must be a map, got <nil>"},
+		{`has({}, nil);`, "error at line 1, col 4: has: invalid map key nil of type <nil>"},
+		{`delete({}, [1]);`, "error at line 1, col 7: delete: invalid map key [1] of type *ast.ListValue"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"math"
+)
+
+// MathLib provides sqrt, floor, ceil, abs, round and pow, min and max of
+// two or more numbers, and clamp(x, lo, hi), which bounds x to [lo, hi].
+func MathLib(i *Interpreter) {
+	unary := map[string]func(float64) float64{
+		"sqrt":  math.Sqrt,
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"abs":   math.Abs,
+		"round": math.Round,
+	}
+
+	for name, f := range unary {
+		f := f
+		i.define(name, 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+			n, err := number(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			return f(n), nil
+		})
+	}
+
+	i.define("pow", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		base, err := number(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		exp, err := number(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		return math.Pow(base, exp), nil
+	})
+
+	extrema := map[string]func(float64, float64) float64{
+		"min": math.Min,
+		"max": math.Max,
+	}
+
+	for name, f := range extrema {
+		f := f
+		i.defineVariadic(name, 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+			result, err := number(arguments, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			for j := 1; j < len(arguments); j++ {
+				n, err := number(arguments, j)
+				if err != nil {
+					return nil, err
+				}
+
+				result = f(result, n)
+			}
+
+			return result, nil
+		})
+	}
+
+	i.define("clamp", 3, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		x, err := number(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		lo, err := number(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		hi, err := number(arguments, 2)
+		if err != nil {
+			return nil, err
+		}
+
+		if lo > hi {
+			return nil, fmt.Errorf("lower bound %v is greater than upper bound %v", Literal{lo}, Literal{hi})
+		}
+
+		return math.Max(lo, math.Min(x, hi)), nil
+	})
+}
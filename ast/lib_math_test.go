@@ -0,0 +1,102 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestMathLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"sqrt(16)", "4"},
+		{"sqrt(2)", "1.4142135623730951"},
+		{"floor(2.7)", "2"},
+		{"floor(-2.2)", "-3"},
+		{"ceil(2.2)", "3"},
+		{"ceil(-2.7)", "-2"},
+		{"abs(-3)", "3"},
+		{"abs(3)", "3"},
+		{"round(2.5)", "3"},
+		{"round(-2.5)", "-3"},
+		{"round(2.4)", "2"},
+		{"pow(2, 10)", "1024"},
+		{"pow(4, 0.5)", "2"},
+		{"min(3, 1)", "1"},
+		{"max(3, 1)", "3"},
+		{"min(2, -1, 5)", "-1"},
+		{"max(2, -1, 5)", "5"},
+		{"max(1.5, 1.25, 1.5, 1)", "1.5"},
+		{"clamp(5, 0, 10)", "5"},
+		{"clamp(-5, 0, 10)", "0"},
+		{"clamp(15, 0, 10)", "10"},
+		{"clamp(2, 2, 2)", "2"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output("print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %v, got %v", test.out, out)
+			}
+		})
+	}
+}
+
+func TestMathLib_InvalidArgument(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`sqrt("16");`, "error at line 1, col 5: sqrt: argument 1 must be a number, got string"},
+		{`floor(nil);`, "error at line 1, col 6: floor: argument 1 must be a number, got <nil>"},
+		{`ceil(true);`, "error at line 1, col 5: ceil: argument 1 must be a number, got bool"},
+		{`abs([]);`, "error at line 1, col 4: abs: argument 1 must be a number, got *ast.ListValue"},
+		{`round("1");`, "error at line 1, col 6: round: argument 1 must be a number, got string"},
+		{`pow(2, "3");`, "error at line 1, col 4: pow: argument 2 must be a number, got string"},
+		{`min(1, "2");`, "error at line 1, col 4: min: argument 2 must be a number, got string"},
+		{`max(1, 2, nil);`, "error at line 1, col 4: max: argument 3 must be a number, got <nil>"},
+		{`min(1);`, "error at line 1, col 4: expected at least 2 arguments but got 1"},
+		{`clamp(1, "0", 2);`, "error at line 1, col 6: clamp: argument 2 must be a number, got string"},
+		{`clamp(5, 10, 0);`, "error at line 1, col 6: clamp: lower bound 10 is greater than upper bound 0"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.out {
+				t.Errorf("want %q, got %v", test.out, err)
+			}
+		})
+	}
+}
+
+func TestMathLib_Sandbox(t *testing.T) {
+	if _, err := run("sqrt(4);"); err == nil {
+		t.Errorf("want undefined sqrt in a bare interpreter, got nil")
+	}
+}
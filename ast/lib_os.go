@@ -0,0 +1,50 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// OSLib provides args, which lists the arguments of the program, and
+// getEnv, which returns the value of an environment variable or nil if
+// unset. Both read the interpreter, the embedder decides what they see.
+func OSLib(i *Interpreter) {
+	i.define("args", 0, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		args := make([]interface{}, len(i.Args))
+		for j, arg := range i.Args {
+			args[j] = arg
+		}
+
+		return &ListValue{args}, nil
+	})
+
+	i.define("getEnv", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		name, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if v, ok := i.Env[name]; ok {
+			return v, nil
+		}
+
+		return nil, nil
+	})
+}
@@ -0,0 +1,80 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOSLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`args()`, `["-v", "in.txt"]`},
+		{`len(args())`, "2"},
+		{`args()[1]`, "in.txt"},
+		{`fun () { var a = args(); pop(a); return args(); }()`, `["-v", "in.txt"]`},
+		{`getEnv("HOME")`, "/home/lox"},
+		{`getEnv("EMPTY") == ""`, "true"},
+		{`getEnv("UNSET")`, "nil"},
+		{`getEnv("UNSET") ?? "default"`, "default"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			var b bytes.Buffer
+
+			i := NewInterpreter()
+			i.SetOutput(&b)
+			i.Args = []string{"-v", "in.txt"}
+			i.Env = map[string]string{"HOME": "/home/lox", "EMPTY": ""}
+
+			if err := exec(i, "print "+test.in+";"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b.String() != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, b.String())
+			}
+		})
+	}
+}
+
+// nothing leaks from the process by default
+func TestOSLib_Unset(t *testing.T) {
+	out, err := output(`print args(); print getEnv("PATH");`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out != "[]\nnil\n" {
+		t.Errorf("want %q, got %q", "[]\nnil\n", out)
+	}
+
+	_, err = output(`getEnv(1);`)
+	if want := "error at line 1, col 7: getEnv: argument 1 must be a string, got float64"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,74 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// random returns the generator of the random natives, seeded with the
+// current time unless seedRandom was called.
+func (i *Interpreter) random() *rand.Rand {
+	if i.rand == nil {
+		i.rand = rand.New(rand.NewSource(i.time().Now().UnixNano()))
+	}
+
+	return i.rand
+}
+
+// RandomLib provides random, randomInt and seedRandom.
+func RandomLib(i *Interpreter) {
+	i.define("random", 0, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return i.random().Float64(), nil
+	})
+
+	i.define("randomInt", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		lo, err := integer(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		hi, err := integer(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if lo > hi {
+			return nil, fmt.Errorf("empty range %d to %d", lo, hi)
+		}
+
+		return float64(int64(lo) + i.random().Int63n(int64(hi)-int64(lo)+1)), nil
+	})
+
+	i.define("seedRandom", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		seed, err := integer(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		i.rand = rand.New(rand.NewSource(int64(seed)))
+
+		return nil, nil
+	})
+}
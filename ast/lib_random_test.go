@@ -0,0 +1,106 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestRandomLib(t *testing.T) {
+	var b bytes.Buffer
+
+	i := &Interpreter{}
+	i.SetOutput(&b)
+	i.Install(RandomLib)
+
+	program := `seedRandom(42);
+	print random();
+	print random();
+	print randomInt(1, 6);
+	print randomInt(-10, 10);
+	print randomInt(3, 3);`
+
+	if err := exec(i, program); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := rand.New(rand.NewSource(42))
+	want := fmt.Sprintf("%v\n%v\n%v\n%v\n3\n",
+		Literal{r.Float64()}, Literal{r.Float64()}, 1+r.Int63n(6), -10+r.Int63n(21))
+
+	if b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+
+	// seeding again replays the same sequence
+	first := b.String()
+	b.Reset()
+
+	if err := exec(i, program); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if b.String() != first {
+		t.Errorf("want %q, got %q", first, b.String())
+	}
+}
+
+func TestRandomLibRange(t *testing.T) {
+	i := &Interpreter{}
+	i.Install(RandomLib)
+
+	err := exec(i, `for (var j = 0; j < 100; j = j + 1) {
+		var x = random();
+		if (x < 0 or x >= 1) x = nil + 1;
+		var n = randomInt(0, 2);
+		if (n != 0 and n != 1 and n != 2) n = nil + 1;
+	}`)
+	if err != nil {
+		t.Errorf("value out of range: %v", err)
+	}
+}
+
+func TestRandomLibError(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"randomInt(5, 1);", "error at line 1, col 10: randomInt: empty range 5 to 1"},
+		{"randomInt(1.5, 2);", "error at line 1, col 10: randomInt: argument 1 must be an integer, got 1.5"},
+		{"seedRandom(\"x\");", "error at line 1, col 11: seedRandom: argument 1 must be a number, got string"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i := &Interpreter{}
+			i.Install(RandomLib)
+
+			if err := exec(i, test.in); err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "regexp"
+
+// maxRegexps is the number of patterns an interpreter keeps compiled.
+const maxRegexps = 64
+
+// RegexpLib provides matches, which tells whether a string contains a
+// match of a pattern, findAll, which lists the matches in order, and
+// replaceAll, which replaces them, expanding $1 or ${name} in the
+// replacement to the submatches. Patterns use the syntax of Go's regexp
+// package. It is not part of StdLib.
+func RegexpLib(i *Interpreter) {
+	i.define("matches", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, re, err := i.regexpArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		return re.MatchString(s), nil
+	})
+
+	i.define("findAll", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, re, err := i.regexpArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		found := re.FindAllString(s, -1)
+
+		matches := make([]interface{}, len(found))
+		for j, m := range found {
+			matches[j] = m
+		}
+
+		return &ListValue{matches}, nil
+	})
+
+	i.define("replaceAll", 3, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, re, err := i.regexpArguments(arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		repl, err := str(arguments, 2)
+		if err != nil {
+			return nil, err
+		}
+
+		return re.ReplaceAllString(s, repl), nil
+	})
+}
+
+// regexpArguments returns the string and the compiled pattern passed to
+// the regexp natives.
+func (i *Interpreter) regexpArguments(arguments []interface{}) (string, *regexp.Regexp, error) {
+	s, err := str(arguments, 0)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pattern, err := str(arguments, 1)
+	if err != nil {
+		return "", nil, err
+	}
+
+	re, err := i.regexp(pattern)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return s, re, nil
+}
+
+// regexp compiles a pattern, or returns it compiled by an earlier call.
+// The cache is emptied when full, so that patterns built at run time do
+// not pile up.
+func (i *Interpreter) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := i.regexps[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.regexps == nil || len(i.regexps) >= maxRegexps {
+		i.regexps = make(map[string]*regexp.Regexp)
+	}
+	i.regexps[pattern] = re
+
+	return re, nil
+}
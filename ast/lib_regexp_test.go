@@ -0,0 +1,105 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegexpLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`matches("hello world", "o w")`, "true"},
+		{`matches("hello", "^h.*o$")`, "true"},
+		{`matches("hello", "^world")`, "false"},
+		{`findAll("a1 b22 c333", "[0-9]+")`, `["1", "22", "333"]`},
+		{`findAll("abc", "[0-9]+")`, "[]"},
+		{`replaceAll("a1 b22", "[0-9]+", "#")`, "a# b#"},
+		{`replaceAll("john smith", "(\\w+) (\\w+)", "$2, $1")`, "smith, john"},
+		{`replaceAll("abc", "x", "y")`, "abc"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			var b bytes.Buffer
+
+			i := NewInterpreter()
+			i.SetOutput(&b)
+			i.Install(RegexpLib)
+
+			if err := exec(i, "print "+test.in+";"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b.String() != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, b.String())
+			}
+		})
+	}
+}
+
+func TestRegexpLib_Errors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`matches("a", "(");`, "error at line 1, col 8: matches: error parsing regexp: missing closing ): `(`"},
+		{`findAll("a", "[a");`, "error at line 1, col 8: findAll: error parsing regexp: missing closing ]: `[a`"},
+		{`replaceAll("a", "a", 1);`, "error at line 1, col 11: replaceAll: argument 3 must be a string, got float64"},
+		{`matches(1, "a");`, "error at line 1, col 8: matches: argument 1 must be a string, got float64"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i := NewInterpreter()
+			i.Install(RegexpLib)
+
+			err := exec(i, test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRegexpLib_Cache(t *testing.T) {
+	i := NewInterpreter()
+	i.Install(RegexpLib)
+
+	if err := exec(i, `for (var n = 0; n < 100; n = n + 1) { matches("a", "a"); matches("a", toString(n)); }`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(i.regexps) == 0 || len(i.regexps) > maxRegexps {
+		t.Errorf("want at most %d patterns, got %d", maxRegexps, len(i.regexps))
+	}
+}
+
+func TestRegexpLib_NotInstalled(t *testing.T) {
+	if _, err := output(`matches("a", "a");`); err == nil {
+		t.Error("matches is not part of the standard library")
+	}
+}
@@ -0,0 +1,326 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StringLib provides len, substring, indexOf, toUpper and toLower. Strings
+// are indexed by runes, not bytes, and so ord, chr and chars convert
+// between characters, that is runes, and their code points. split cuts a
+// string at every separator, or into runes if the separator is empty, and
+// join concatenates a list, rendering every element as toString does.
+//
+// It also provides format(fmt, ...args), which returns fmt with its verbs
+// replaced by the arguments following it, and printf(fmt, ...args), which
+// prints it without a trailing newline. The verbs are %d for integers, %f
+// for numbers, with an optional precision of up to 100 digits like %.2f,
+// %s for any value as print renders it and %% for a percent sign. There
+// must be exactly one argument per verb.
+func StringLib(i *Interpreter) {
+	i.define("len", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		switch v := arguments[0].(type) {
+		case string:
+			return float64(utf8.RuneCountInString(v)), nil
+		case *ListValue:
+			return float64(len(v.Elements)), nil
+		case *MapValue:
+			return float64(v.Len()), nil
+		}
+
+		return nil, fmt.Errorf("argument 1 must be a string, a list or a map, got %T", arguments[0])
+	})
+
+	i.define("substring", 3, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		start, err := integer(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		end, err := integer(arguments, 2)
+		if err != nil {
+			return nil, err
+		}
+
+		runes := []rune(s)
+		if start < 0 || end > len(runes) || start > end {
+			return nil, fmt.Errorf("range [%d, %d) out of bounds [0, %d)", start, end, len(runes))
+		}
+
+		return string(runes[start:end]), nil
+	})
+
+	i.define("indexOf", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		sub, err := str(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		j := strings.Index(s, sub)
+		if j < 0 {
+			return -1.0, nil
+		}
+
+		return float64(utf8.RuneCountInString(s[:j])), nil
+	})
+
+	i.define("toUpper", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.ToUpper(s), nil
+	})
+
+	i.define("toLower", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.ToLower(s), nil
+	})
+
+	i.define("ord", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if utf8.RuneCountInString(s) != 1 {
+			return nil, fmt.Errorf("expected a single character, got %q", s)
+		}
+
+		r, _ := utf8.DecodeRuneInString(s)
+
+		return float64(r), nil
+	})
+
+	i.define("chr", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		n, err := integer(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if n < 0 || n > utf8.MaxRune || !utf8.ValidRune(rune(n)) {
+			return nil, fmt.Errorf("invalid code point %d", n)
+		}
+
+		return string(rune(n)), nil
+	})
+
+	i.define("chars", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		chars := []interface{}{}
+		for _, r := range s {
+			chars = append(chars, string(r))
+		}
+
+		return &ListValue{chars}, nil
+	})
+
+	i.define("split", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		s, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		sep, err := str(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := strings.Split(s, sep)
+
+		elements := make([]interface{}, len(parts))
+		for j, part := range parts {
+			elements[j] = part
+		}
+
+		return &ListValue{elements}, nil
+	})
+
+	i.define("join", 2, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		l, err := list(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		sep, err := str(arguments, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		parts := make([]string, len(l.Elements))
+		for j, e := range l.Elements {
+			if parts[j], err = i.stringify(i.site, Literal{e}); err != nil {
+				return nil, err
+			}
+		}
+
+		return strings.Join(parts, sep), nil
+	})
+
+	i.defineVariadic("format", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		f, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return i.format(f, arguments[1:])
+	})
+
+	i.defineVariadic("printf", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		f, err := str(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := i.format(f, arguments[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprint(i.output(), s)
+
+		return nil, nil
+	})
+}
+
+// maxPrecision is the most digits %f prints after the decimal point.
+const maxPrecision = 100
+
+// format replaces the verbs of f with the arguments in order.
+func (i *Interpreter) format(f string, arguments []interface{}) (string, error) {
+	var b strings.Builder
+
+	next := 0
+	runes := []rune(f)
+
+	for j := 0; j < len(runes); j++ {
+		if runes[j] != '%' {
+			b.WriteRune(runes[j])
+			continue
+		}
+
+		start := j
+		j++
+
+		// an optional precision for %f
+		precision := -1
+		if j < len(runes) && runes[j] == '.' {
+			precision = 0
+			for j++; j < len(runes) && runes[j] >= '0' && runes[j] <= '9'; j++ {
+				// kept from growing, it is reported below
+				if precision <= maxPrecision {
+					precision = precision*10 + int(runes[j]-'0')
+				}
+			}
+
+			if precision > maxPrecision {
+				return "", fmt.Errorf("precision of %s is greater than %d", string(runes[start:j]), maxPrecision)
+			}
+		}
+
+		if j >= len(runes) {
+			return "", fmt.Errorf("missing verb at the end of the format")
+		}
+
+		verb := runes[j]
+		if verb == '%' && precision < 0 {
+			b.WriteRune('%')
+			continue
+		}
+
+		if verb != 'd' && verb != 'f' && verb != 's' || precision >= 0 && verb != 'f' {
+			return "", fmt.Errorf("invalid verb %s", string(runes[start:j+1]))
+		}
+
+		if next >= len(arguments) {
+			return "", fmt.Errorf("missing argument for verb %d", next+1)
+		}
+
+		v := arguments[next]
+		next++
+
+		switch verb {
+		case 'd':
+			{
+				n, ok := v.(float64)
+				if !ok || n != math.Trunc(n) {
+					return "", fmt.Errorf("argument %d for %%d must be an integer, got %s", next+1, repr(v))
+				}
+
+				b.WriteString(strconv.FormatFloat(n, 'f', 0, 64))
+			}
+		case 'f':
+			{
+				n, ok := v.(float64)
+				if !ok {
+					return "", fmt.Errorf("argument %d for %%f must be a number, got %s", next+1, repr(v))
+				}
+
+				if precision < 0 {
+					precision = 6
+				}
+
+				b.WriteString(strconv.FormatFloat(n, 'f', precision, 64))
+			}
+		case 's':
+			{
+				s, err := i.stringify(i.site, Literal{v})
+				if err != nil {
+					return "", err
+				}
+
+				b.WriteString(s)
+			}
+		}
+	}
+
+	if next < len(arguments) {
+		return "", fmt.Errorf("too many arguments, %d verbs but got %d arguments", next, len(arguments))
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,187 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "testing"
+
+func TestStringLib(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`len("hello")`, "5"},
+		{`len("")`, "0"},
+		{`len("héllo wörld")`, "11"},
+		{`len("日本語")`, "3"},
+		{`len([1, 2, 3])`, "3"},
+		{`len({"a": 1})`, "1"},
+		{`substring("hello", 1, 3)`, "el"},
+		{`substring("hello", 0, 5)`, "hello"},
+		{`substring("hello", 2, 2)`, ""},
+		{`substring("日本語です", 1, 3)`, "本語"},
+		{`indexOf("hello", "l")`, "2"},
+		{`indexOf("hello", "z")`, "-1"},
+		{`indexOf("日本語", "語")`, "2"},
+		{`toUpper("Hello, wörld")`, "HELLO, WÖRLD"},
+		{`toLower("HeLLo")`, "hello"},
+		{`ord("A")`, "65"},
+		{`ord("é")`, "233"},
+		{`chr(97)`, "a"},
+		{`chr(26085)`, "日"},
+		{`chr(ord("A"))`, "A"},
+		{`ord(chr(128512)) == 128512`, "true"},
+		{`chars("héllo")`, `["h", "é", "l", "l", "o"]`},
+		{`chars("")`, "[]"},
+		{`split("a,b,,c", ",")`, `["a", "b", "", "c"]`},
+		{`split("a -> b", " -> ")`, `["a", "b"]`},
+		{`split("abc", ";")`, `["abc"]`},
+		{`split("héllo", "")`, `["h", "é", "l", "l", "o"]`},
+		{`split("", ",")`, `[""]`},
+		{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`join([1, 2.5, 3], "+")`, "1+2.5+3"},
+		{`join([nil, true, [1]], " ")`, "nil true [1]"},
+		{`join([], ",")`, ""},
+		{`join(split("a b c", " "), "")`, "abc"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output("print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestStringLib_Error(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`len(1);`, "error at line 1, col 4: len: argument 1 must be a string, a list or a map, got float64"},
+		{`substring("hello", 2, 6);`, "error at line 1, col 10: substring: range [2, 6) out of bounds [0, 5)"},
+		{`substring("hello", -1, 2);`, "error at line 1, col 10: substring: range [-1, 2) out of bounds [0, 5)"},
+		{`substring("hello", 3, 2);`, "error at line 1, col 10: substring: range [3, 2) out of bounds [0, 5)"},
+		{`substring("hello", 0.5, 2);`, "error at line 1, col 10: substring: argument 2 must be an integer, got 0.5"},
+		{`substring("hello", 0, 10000000000000000000);`, "error at line 1, col 10: substring: argument 3 is out of range, got 10000000000000000000"},
+		{`indexOf("hello", 1);`, "error at line 1, col 8: indexOf: argument 2 must be a string, got float64"},
+		{`toUpper(nil);`, "error at line 1, col 8: toUpper: argument 1 must be a string, got <nil>"},
+		{`ord("ab");`, "error at line 1, col 4: ord: expected a single character, got \"ab\""},
+		{`ord("");`, "error at line 1, col 4: ord: expected a single character, got \"\""},
+		{`chr(-1);`, "error at line 1, col 4: chr: invalid code point -1"},
+		{`chr(1114112);`, "error at line 1, col 4: chr: invalid code point 1114112"},
+		{`chr(55296);`, "error at line 1, col 4: chr: invalid code point 55296"},
+		{`chr(65.5);`, "error at line 1, col 4: chr: argument 1 must be an integer, got 65.5"},
+		{`chars(1);`, "error at line 1, col 6: chars: argument 1 must be a string, got float64"},
+		{`split(1, ",");`, "error at line 1, col 6: split: argument 1 must be a string, got float64"},
+		{`split("a", nil);`, "error at line 1, col 6: split: argument 2 must be a string, got <nil>"},
+		{`join("abc", ",");`, "error at line 1, col 5: join: argument 1 must be a list, got string"},
+		{`join([1], 0);`, "error at line 1, col 5: join: argument 2 must be a string, got float64"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.out {
+				t.Errorf("want %q, got %v", test.out, err)
+			}
+		})
+	}
+}
+
+func TestStringLib_Format(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`format("plain")`, "plain"},
+		{`format("%d apples", 3)`, "3 apples"},
+		{`format("%d", -42)`, "-42"},
+		{`format("%f", 1.5)`, "1.500000"},
+		{`format("%.2f", 3.14159)`, "3.14"},
+		{`format("%.0f", 2.5)`, "2"},
+		{`len(format("%.100f", 1))`, "102"},
+		{`format("%s and %s", "salt", [1, "a"])`, `salt and [1, "a"]`},
+		{`format("%s %s %s", nil, true, 0.1)`, "nil true 0.1"},
+		{`format("100%%")`, "100%"},
+		{`format("%d%% of %s", 50, "héllo")`, "50% of héllo"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			out, err := output("print " + test.in + ";")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out+"\n" {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestStringLib_Printf(t *testing.T) {
+	out, err := output(`printf("%s=%d;", "x", 1); printf("%.1f\n", 2);`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "x=1;2.0\n"; out != want {
+		t.Errorf("want %q, got %q", want, out)
+	}
+}
+
+func TestStringLib_FormatErrors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{`format("%d and %d", 1);`, "error at line 1, col 7: format: missing argument for verb 2"},
+		{`printf("%s", 1, 2);`, "error at line 1, col 7: printf: too many arguments, 1 verbs but got 2 arguments"},
+		{`format();`, "error at line 1, col 7: expected at least 1 arguments but got 0"},
+		{`format("%d", 1.5);`, "error at line 1, col 7: format: argument 2 for %d must be an integer, got 1.5"},
+		{`format("%f", "1");`, `error at line 1, col 7: format: argument 2 for %f must be a number, got "1"`},
+		{`format("%x", 1);`, "error at line 1, col 7: format: invalid verb %x"},
+		{`format("%.2d", 1);`, "error at line 1, col 7: format: invalid verb %.2d"},
+		{`format("%.101f", 1);`, "error at line 1, col 7: format: precision of %.101 is greater than 100"},
+		{`format("%.999999999f", 1);`, "error at line 1, col 7: format: precision of %.999999999 is greater than 100"},
+		{`format("%.٣f", 1);`, "error at line 1, col 7: format: invalid verb %.٣"},
+		{`format("50%");`, "error at line 1, col 7: format: missing verb at the end of the format"},
+		{`format(1);`, "error at line 1, col 7: format: argument 1 must be a string, got float64"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := output(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeSource is the clock used by the time natives.
+type TimeSource interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type systemTime struct{}
+
+func (systemTime) Now() time.Time {
+	return time.Now()
+}
+
+func (systemTime) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (i *Interpreter) time() TimeSource {
+	if i.Time == nil {
+		return systemTime{}
+	}
+
+	return i.Time
+}
+
+// TimeLib provides clock, now and sleep. clock returns the seconds
+// elapsed since the library was installed, now the seconds since the
+// Unix epoch. sleep returns early once the run is canceled.
+func TimeLib(i *Interpreter) {
+	i.define("clock", 0, clock(i.time().Now()))
+
+	i.define("now", 0, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return float64(i.time().Now().UnixNano()) / float64(time.Second), nil
+	})
+
+	i.define("sleep", 1, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		seconds, err := number(arguments, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if seconds < 0 {
+			return nil, fmt.Errorf("argument 1 must not be negative, got %v", Literal{seconds})
+		}
+
+		d := time.Duration(seconds * float64(time.Second))
+		if i.Time != nil {
+			i.Time.Sleep(d)
+			return nil, nil
+		}
+
+		// the system clock wakes up early if the run is done
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-i.Context().Done():
+		}
+
+		return nil, nil
+	})
+}
+
+// clock returns the native clock, counting the seconds elapsed since start.
+func clock(start time.Time) func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+	return func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		return i.time().Now().Sub(start).Seconds(), nil
+	}
+}
@@ -0,0 +1,116 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// fakeTime is a clock that only moves when slept on.
+type fakeTime struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (f *fakeTime) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeTime) Sleep(d time.Duration) {
+	f.slept = append(f.slept, d)
+	f.now = f.now.Add(d)
+}
+
+func TestTimeLib(t *testing.T) {
+	var b bytes.Buffer
+
+	clock := &fakeTime{now: time.Unix(1000, 500000000)}
+
+	i := &Interpreter{Time: clock}
+	i.SetOutput(&b)
+	i.Install(TimeLib)
+
+	err := exec(i, `print clock();
+	print now();
+	sleep(1.5);
+	print clock();
+	print now();
+	sleep(0);`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "0\n1000.5\n1.5\n1002\n"; b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+
+	slept := []time.Duration{1500 * time.Millisecond, 0}
+	if len(clock.slept) != len(slept) || clock.slept[0] != slept[0] || clock.slept[1] != slept[1] {
+		t.Errorf("want %v, got %v", slept, clock.slept)
+	}
+}
+
+func TestTimeLib_ZeroValue(t *testing.T) {
+	var b bytes.Buffer
+
+	clock := &fakeTime{now: time.Unix(1000, 0)}
+
+	// clock is there without installing TimeLib, the other natives are not
+	i := &Interpreter{Time: clock}
+	i.SetOutput(&b)
+
+	if err := exec(i, "print clock();"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "0\n"; b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+
+	if err := exec(i, "now();"); err == nil {
+		t.Error("want an error, now is not defined")
+	}
+}
+
+func TestTimeLibError(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"sleep(-1);", "error at line 1, col 6: sleep: argument 1 must not be negative, got -1"},
+		{"sleep(\"1\");", "error at line 1, col 6: sleep: argument 1 must be a number, got string"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			i := &Interpreter{Time: &fakeTime{}}
+			i.Install(TimeLib)
+
+			if err := exec(i, test.in); err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ListValue is the runtime value of a list, it is always handled by
+// pointer so that every variable referencing a list sees its changes.
+type ListValue struct {
+	Elements []interface{}
+}
+
+// index validates v as an index of the list.
+func (l *ListValue) index(t Token, v interface{}) (int, error) {
+	f, ok := v.(float64)
+	if !ok || f != math.Trunc(f) {
+		return 0, errorAt(t, "list index must be an integer, got %v", Literal{v})
+	}
+
+	if f < 0 {
+		return 0, errorAt(t, "negative list index %d", int64(f))
+	}
+
+	if f >= float64(len(l.Elements)) {
+		return 0, errorAt(t, "list index %d out of bounds [0, %d)", int64(f), len(l.Elements))
+	}
+
+	return int(f), nil
+}
+
+func (l *ListValue) String() string {
+	return l.render(rendering{})
+}
+
+func (l *ListValue) render(seen rendering) string {
+	if seen[l] {
+		return "[...]"
+	}
+
+	seen[l] = true
+	defer delete(seen, l)
+
+	elements := make([]string, len(l.Elements))
+
+	for i, e := range l.Elements {
+		elements[i] = seen.repr(e)
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// rendering is the set of the collections being rendered, a collection
+// nested in itself is rendered as [...] or {...}.
+type rendering map[interface{}]bool
+
+// repr renders a value nested in a collection, quoting strings.
+func repr(v interface{}) string {
+	return rendering{}.repr(v)
+}
+
+func (seen rendering) repr(v interface{}) string {
+	switch c := v.(type) {
+	case string:
+		return strconv.Quote(c)
+	case *ListValue:
+		return c.render(seen)
+	case *MapValue:
+		return c.render(seen)
+	case *RecordValue:
+		return c.render(seen)
+	}
+
+	return Literal{v}.String()
+}
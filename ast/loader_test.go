@@ -0,0 +1,161 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// runFile parses and runs the file at path with loader.
+func runFile(loader Loader, path string) (string, error) {
+	source, err := loader.Load(path)
+	if err != nil {
+		return "", err
+	}
+
+	scanner := Scanner{source}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		return "", err
+	}
+
+	parser := Parser{Tokens: tokens, Path: path, Loader: loader}
+	stmts, err := parser.Parse()
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+
+	err = i.Run(stmts)
+
+	return b.String(), err
+}
+
+func TestImport(t *testing.T) {
+	loader := MapLoader{
+		"main.lox":          `import "lib/math.lox"; import "lib/greet.lox"; print square(3); greet("world");`,
+		"lib/math.lox":      `import "consts.lox"; fun square(x) { return x * x; }`,
+		"lib/consts.lox":    `var HELLO = "hello"; print "consts";`,
+		"lib/greet.lox":     `import "./consts.lox"; fun greet(name) { print HELLO + " " + name; }`,
+		"/abs/main.lox":     `import "/abs/lib.lox"; print value;`,
+		"/abs/lib.lox":      `var value = 42;`,
+		"nested/a/main.lox": `import "../b/lib.lox"; print value;`,
+		"nested/b/lib.lox":  `var value = "up";`,
+	}
+
+	table := []struct {
+		path string
+		out  string
+	}{
+		{"main.lox", "consts\n9\nhello world\n"},
+		{"/abs/main.lox", "42\n"},
+		{"nested/a/main.lox", "up\n"},
+	}
+
+	for _, test := range table {
+		t.Run(test.path, func(t *testing.T) {
+			out, err := runFile(loader, test.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out != test.out {
+				t.Errorf("want %q, got %q", test.out, out)
+			}
+		})
+	}
+}
+
+func TestImport_Errors(t *testing.T) {
+	loader := MapLoader{
+		"a.lox":      `import "b.lox";`,
+		"b.lox":      `var b = 1;` + "\n" + `import "a.lox";`,
+		"self.lox":   `import "self.lox";`,
+		"nested.lox": `{ import "lib.lox"; }`,
+		"lib.lox":    `var x = 1;`,
+		"bad.lox":    `import "broken.lox";`,
+		"broken.lox": `var = 1;`,
+		"lost.lox":   `import "missing.lox";`,
+	}
+
+	table := []struct {
+		path string
+		err  string
+	}{
+		{"a.lox", "error at line 1, col 8: b.lox: error at line 2, col 8: circular import a.lox -> b.lox -> a.lox"},
+		{"self.lox", "error at line 1, col 8: circular import self.lox -> self.lox"},
+		{"nested.lox", "error at line 1, col 3: import must be at the top level"},
+		{"bad.lox", "error at line 1, col 8: broken.lox: error at line 1, col 5: expected 'IDENTIFIER'"},
+		{"lost.lox", "error at line 1, col 8: cannot import missing.lox: file not found"},
+	}
+
+	for _, test := range table {
+		t.Run(test.path, func(t *testing.T) {
+			_, err := runFile(loader, test.path)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestImport_FileLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"main.lox":     `import "lib/util.lox"; print twice(21);`,
+		"lib/util.lox": `fun twice(x) { return 2 * x; }`,
+	}
+
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := runFile(FileLoader{}, filepath.Join(dir, "main.lox"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if out != "42\n" {
+		t.Errorf("want %q, got %q", "42\n", out)
+	}
+}
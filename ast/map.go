@@ -0,0 +1,123 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"math"
+	"strings"
+)
+
+// MapKey is the normalized form of a map key. Only strings and numbers
+// can be used as keys, and the number 1 and the string "1" are distinct.
+type MapKey struct {
+	Value interface{}
+}
+
+func NewMapKey(t Token, v interface{}) (MapKey, error) {
+	switch k := v.(type) {
+	case string:
+		return MapKey{k}, nil
+	case float64:
+		if math.IsNaN(k) {
+			return MapKey{}, errorAt(t, "NaN is not a valid map key")
+		}
+
+		// +0 and -0 are the same key
+		if k == 0 {
+			k = 0
+		}
+
+		return MapKey{k}, nil
+	}
+
+	return MapKey{}, errorAt(t, "invalid map key %v of type %T", Literal{v}, v)
+}
+
+// MapValue is the runtime value of a map, like lists it is always handled
+// by pointer. Entries are kept in insertion order.
+type MapValue struct {
+	entries map[MapKey]interface{}
+	keys    []MapKey
+}
+
+func NewMapValue() *MapValue {
+	return &MapValue{make(map[MapKey]interface{}), nil}
+}
+
+func (m *MapValue) Get(k MapKey) (interface{}, bool) {
+	v, ok := m.entries[k]
+	return v, ok
+}
+
+func (m *MapValue) Set(k MapKey, v interface{}) {
+	if _, ok := m.entries[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+
+	m.entries[k] = v
+}
+
+func (m *MapValue) Delete(k MapKey) {
+	if _, ok := m.entries[k]; !ok {
+		return
+	}
+
+	delete(m.entries, k)
+
+	for i, key := range m.keys {
+		if key == k {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *MapValue) Len() int {
+	return len(m.keys)
+}
+
+// Keys returns the keys of the map in insertion order.
+func (m *MapValue) Keys() []MapKey {
+	return m.keys
+}
+
+func (m *MapValue) String() string {
+	return m.render(rendering{})
+}
+
+func (m *MapValue) render(seen rendering) string {
+	if seen[m] {
+		return "{...}"
+	}
+
+	seen[m] = true
+	defer delete(seen, m)
+
+	entries := make([]string, len(m.keys))
+
+	for i, k := range m.keys {
+		entries[i] = seen.repr(k.Value) + ": " + seen.repr(m.entries[k])
+	}
+
+	return "{" + strings.Join(entries, ", ") + "}"
+}
@@ -0,0 +1,258 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Native is a function implemented in Go and callable from Lox.
+type Native struct {
+	Name     string
+	arity    int
+	variadic bool // takes arity or more arguments
+	fn       func(i *Interpreter, arguments []interface{}) (interface{}, error)
+}
+
+func (n *Native) Arity() int {
+	return n.arity
+}
+
+// checkArguments checks the number of arguments count of a call.
+func (n *Native) checkArguments(paren Token, count int) error {
+	if n.variadic && count < n.arity {
+		return errorAt(paren, "expected at least %d arguments but got %d", n.arity, count)
+	}
+
+	if !n.variadic && count != n.arity {
+		return errorAt(paren, "expected %d arguments but got %d", n.arity, count)
+	}
+
+	return nil
+}
+
+func (n *Native) Call(i *Interpreter, arguments []Expr) (Literal, error) {
+	values := make([]interface{}, len(arguments))
+
+	for j, argument := range arguments {
+		l, err := i.Evaluate(argument)
+		if err != nil {
+			return Literal{}, err
+		}
+
+		values[j] = l.Value
+	}
+
+	v, err := n.fn(i, values)
+	if err != nil {
+		return Literal{}, err
+	}
+
+	return Literal{v}, nil
+}
+
+func (n *Native) String() string {
+	return "<native fn " + n.Name + ">"
+}
+
+// RegisterNative installs fn into the global scope as a function named
+// name taking exactly arity arguments.
+//
+// Lox numbers, strings, booleans and nil are passed to fn as float64,
+// string, bool and nil, and fn is expected to return one of these types;
+// Go integers are converted to float64. Other Lox values, like lists or
+// functions, are passed as their runtime representation and can be
+// returned as they are. A non-nil error returned by fn is reported as a
+// runtime error at the call site. Goroutines started by fn may read and
+// assign globals through i.Globals, but must not call back into i.
+func (i *Interpreter) RegisterNative(name string, arity int, fn func(arguments []interface{}) (interface{}, error)) {
+	i.RegisterNativeContext(name, arity, func(ctx context.Context, arguments []interface{}) (interface{}, error) {
+		return fn(arguments)
+	})
+}
+
+// RegisterNativeContext is like RegisterNative, but fn also receives the
+// context of the run, so that it can return early once it is canceled.
+func (i *Interpreter) RegisterNativeContext(name string, arity int, fn func(ctx context.Context, arguments []interface{}) (interface{}, error)) {
+	i.define(name, arity, func(i *Interpreter, arguments []interface{}) (interface{}, error) {
+		v, err := fn(i.Context(), arguments)
+		if err != nil {
+			return nil, err
+		}
+
+		return fromGo(v), nil
+	})
+}
+
+// fromGo converts the Go integers in the place of Lox numbers to float64.
+func fromGo(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+
+	return v
+}
+
+// SetGlobal defines the global variable name with value, or assigns it if
+// already defined, so that programs run afterwards can read it. Values are
+// given as to natives, see RegisterNative.
+func (i *Interpreter) SetGlobal(name string, value interface{}) {
+	_ = i.globals().Declare(Variable{Token{Lexeme: name}, nil}, Literal{fromGo(value)})
+}
+
+// GetGlobal returns the value of the global variable name, as passed to
+// natives, and whether it is defined. Globals include the natives.
+func (i *Interpreter) GetGlobal(name string) (interface{}, bool) {
+	return i.globals().Lookup(name)
+}
+
+func (i *Interpreter) define(name string, arity int, fn func(i *Interpreter, arguments []interface{}) (interface{}, error)) {
+	i.globals().Set(name, &Native{name, arity, false, fn})
+}
+
+// defineVariadic defines a native taking arity or more arguments, all of
+// them are passed to fn.
+func (i *Interpreter) defineVariadic(name string, arity int, fn func(i *Interpreter, arguments []interface{}) (interface{}, error)) {
+	i.globals().Set(name, &Native{name, arity, true, fn})
+}
+
+// A Library is a set of natives installed together in the global scope.
+type Library func(i *Interpreter)
+
+// StdLib is the standard library installed by NewInterpreter.
+var StdLib = []Library{CoreLib, MathLib, StringLib, ListLib, MapLib, ClassLib, IOLib, FileLib, OSLib, TimeLib, RandomLib, JSONLib}
+
+// NewInterpreter returns an interpreter with the standard library
+// installed. The zero value Interpreter has no natives besides clock, so
+// sandboxed embedders can Install only the libraries they trust.
+func NewInterpreter() *Interpreter {
+	i := &Interpreter{}
+	i.Install(StdLib...)
+
+	return i
+}
+
+// Install installs libraries in the global scope.
+func (i *Interpreter) Install(libraries ...Library) {
+	for _, library := range libraries {
+		library(i)
+	}
+}
+
+// callback calls a callable passed to a native, its errors are located at
+// the call of the native.
+func (i *Interpreter) callback(callee interface{}, arguments ...interface{}) (interface{}, error) {
+	exprs := make([]Expr, len(arguments))
+	for j, argument := range arguments {
+		exprs[j] = Literal{argument}
+	}
+
+	l, err := i.call(i.site, Literal{callee}, exprs)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Value, nil
+}
+
+// callable returns the j-th argument of a native, which must be callable.
+func callable(arguments []interface{}, j int) (Callable, error) {
+	if c, ok := arguments[j].(Callable); ok {
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("argument %d must be a function, got %T", j+1, arguments[j])
+}
+
+// class returns the j-th argument of a native, which must be a class.
+func class(arguments []interface{}, j int) (*ClassValue, error) {
+	if c, ok := arguments[j].(*ClassValue); ok {
+		return c, nil
+	}
+
+	return nil, fmt.Errorf("argument %d must be a class, got %T", j+1, arguments[j])
+}
+
+// list returns the j-th argument of a native, which must be a list.
+func list(arguments []interface{}, j int) (*ListValue, error) {
+	if l, ok := arguments[j].(*ListValue); ok {
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("argument %d must be a list, got %T", j+1, arguments[j])
+}
+
+// mapping returns the j-th argument of a native, which must be a map.
+func mapping(arguments []interface{}, j int) (*MapValue, error) {
+	if m, ok := arguments[j].(*MapValue); ok {
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("argument %d must be a map, got %T", j+1, arguments[j])
+}
+
+// number returns the j-th argument of a native, which must be a number.
+func number(arguments []interface{}, j int) (float64, error) {
+	if n, ok := arguments[j].(float64); ok {
+		return n, nil
+	}
+
+	return 0, fmt.Errorf("argument %d must be a number, got %T", j+1, arguments[j])
+}
+
+// maxInteger is the largest magnitude of the integers natives take, every
+// integer up to it is exactly a float64.
+const maxInteger = 1 << 53
+
+// integer returns the j-th argument of a native, which must be a number
+// without a fractional part.
+func integer(arguments []interface{}, j int) (int, error) {
+	n, err := number(arguments, j)
+	if err != nil {
+		return 0, err
+	}
+
+	if n != math.Trunc(n) {
+		return 0, fmt.Errorf("argument %d must be an integer, got %v", j+1, Literal{n})
+	}
+
+	if math.Abs(n) > maxInteger {
+		return 0, fmt.Errorf("argument %d is out of range, got %v", j+1, Literal{n})
+	}
+
+	return int(n), nil
+}
+
+// str returns the j-th argument of a native, which must be a string.
+func str(arguments []interface{}, j int) (string, error) {
+	if s, ok := arguments[j].(string); ok {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("argument %d must be a string, got %T", j+1, arguments[j])
+}
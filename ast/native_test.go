@@ -0,0 +1,254 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInterpreter_RegisterNative(t *testing.T) {
+	i := &Interpreter{}
+	i.RegisterNative("double", 1, func(arguments []interface{}) (interface{}, error) {
+		n, ok := arguments[0].(float64)
+		if !ok {
+			return nil, errors.New("expected a number")
+		}
+
+		return n * 2, nil
+	})
+
+	if err := exec(i, "var x = double(21);"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if x := global(i, "x"); x != 42.0 {
+		t.Errorf("want 42, got %v", x)
+	}
+
+	err := exec(i, "var y = double(\"a\");")
+	if want := "error at line 1, col 15: double: expected a number"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+
+	err = exec(i, "double(1, 2);")
+	if want := "error at line 1, col 7: expected 1 arguments but got 2"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestInterpreter_RegisterNativeInteger(t *testing.T) {
+	i := &Interpreter{}
+	i.RegisterNative("answer", 0, func(arguments []interface{}) (interface{}, error) {
+		return 42, nil
+	})
+
+	if err := exec(i, "var x = answer() + 1;"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if x := global(i, "x"); x != 43.0 {
+		t.Errorf("want 43, got %v", x)
+	}
+}
+
+func TestInterpreter_SetGlobal(t *testing.T) {
+	var b bytes.Buffer
+
+	i := &Interpreter{}
+	i.SetOutput(&b)
+	i.SetGlobal("limit", 10)
+	i.SetGlobal("name", "lox")
+	i.SetGlobal("debug", true)
+	i.SetGlobal("missing", nil)
+
+	// setting again overwrites
+	i.SetGlobal("limit", 3.5)
+
+	source := `print limit * 2;
+print name + "!";
+print debug and missing == nil;
+var total = limit + 1;
+var items = [name];`
+
+	if err := exec(i, source); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "7\nlox!\ntrue\n"; b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+
+	if v, ok := i.GetGlobal("total"); !ok || v != 4.5 {
+		t.Errorf("want 4.5, got %v, %v", v, ok)
+	}
+
+	if v, ok := i.GetGlobal("items"); !ok || v.(*ListValue).String() != `["lox"]` {
+		t.Errorf("want [\"lox\"], got %v, %v", v, ok)
+	}
+
+	if v, ok := i.GetGlobal("missing"); !ok || v != nil {
+		t.Errorf("want nil, got %v, %v", v, ok)
+	}
+
+	if v, ok := i.GetGlobal("undefined"); ok {
+		t.Errorf("want no global, got %v", v)
+	}
+}
+
+// run with -race, readers on other goroutines see the globals assigned by
+// the interpreter
+func TestInterpreter_ConcurrentGlobals(t *testing.T) {
+	i := &Interpreter{}
+
+	var wg, started sync.WaitGroup
+	done := make(chan struct{})
+
+	i.RegisterNative("watch", 0, func(arguments []interface{}) (interface{}, error) {
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			started.Add(1)
+			go func() {
+				defer wg.Done()
+
+				started.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						i.Globals.Lookup("count")
+						i.Globals.Get(Variable{Token{Lexeme: "count"}, nil})
+					}
+				}
+			}()
+		}
+
+		started.Wait()
+
+		return nil, nil
+	})
+
+	i.RegisterNative("stop", 0, func(arguments []interface{}) (interface{}, error) {
+		close(done)
+		wg.Wait()
+
+		return nil, nil
+	})
+
+	source := "var count = 0;\nwatch();\nfor (var j = 0; j < 1000; j = j + 1) count = count + 1;\nstop();"
+	if err := exec(i, source); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if count := global(i, "count"); count != 1000.0 {
+		t.Errorf("want 1000, got %v", count)
+	}
+}
+
+func TestInterpreter_RunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// cancel stops the run at the statement following its call
+	i := &Interpreter{}
+	i.RegisterNative("cancel", 0, func(arguments []interface{}) (interface{}, error) {
+		cancel()
+		return nil, nil
+	})
+
+	var b bytes.Buffer
+	i.SetOutput(&b)
+
+	err := i.RunContext(ctx, "for (var n = 0; ; n = n + 1) {\n  if (n == 3) cancel();\n  print n;\n}")
+
+	want := "error at line 3, col 3: execution canceled"
+	if err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+
+	if b.String() != "0\n1\n2\n" {
+		t.Errorf("want 3 iterations, got %q", b.String())
+	}
+}
+
+func TestInterpreter_RunContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	i := NewInterpreter()
+	i.RegisterNativeContext("started", 0, func(ctx context.Context, arguments []interface{}) (interface{}, error) {
+		close(started)
+		return nil, nil
+	})
+
+	done := make(chan error)
+	go func() {
+		done <- i.RunContext(ctx, "started();\nsleep(60);\nwhile (true) {}")
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		{
+			want := "error at line 3, col 1: execution canceled"
+			if err == nil || err.Error() != want {
+				t.Errorf("want %q, got %v", want, err)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		{
+			t.Fatal("the run was not canceled")
+		}
+	}
+}
+
+func TestInterpreter_RegisterNativeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	i := &Interpreter{}
+	var seen []error
+	i.RegisterNativeContext("check", 1, func(ctx context.Context, arguments []interface{}) (interface{}, error) {
+		if arguments[0] == true {
+			cancel()
+		}
+
+		seen = append(seen, ctx.Err())
+		return nil, nil
+	})
+
+	if err := i.RunContext(ctx, "check(false);\ncheck(true);\ncheck(false);"); err == nil {
+		t.Error("want an error running with a canceled context")
+	}
+
+	if len(seen) != 2 || seen[0] != nil || seen[1] != context.Canceled {
+		t.Errorf("want [<nil> %v], got %v", context.Canceled, seen)
+	}
+}
@@ -23,13 +23,34 @@
 package ast
 
 import (
-	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
 	Tokens  []Token
+	Path    string // of the parsed file, imports are relative to its directory
+	Loader  Loader // of the imported files, FileLoader if nil
 	current int
+	imports *imports
+	errors  ErrorList // syntax errors found so far
+
+	comments []Token // not attached yet, in source order
+
+	// comments around the members of classes and enums and around the
+	// parameters, by their name, see annotate
+	annotations map[Token]annotation
+
+	// Lines is set for tokens scanned with ScanLines, the semicolon ending
+	// a statement may then be left out before a closing brace
+	Lines bool
+}
+
+// imports tracks the files imported by a program and its imports.
+type imports struct {
+	chain    []string // files being imported, the outermost first
+	imported map[string]bool
 }
 
 func (p Parser) peek() Token {
@@ -73,7 +94,7 @@ func (p *Parser) consume(t TokenType) (Token, error) {
 	token := p.peek()
 
 	if token.TokenType != t {
-		return Token{}, fmt.Errorf("error at line %d: expected '%v'", token.Line, t.String())
+		return Token{}, errorAt(token, "expected '%v'", t.String())
 	}
 
 	p.advance()
@@ -82,19 +103,90 @@ func (p *Parser) consume(t TokenType) (Token, error) {
 }
 
 func (p *Parser) declaration() (Stmt, error) {
+	if p.match(Import) {
+		return p.importDeclaration()
+	}
+
 	if p.match(Var) {
 		return p.variable()
 	}
 
+	if p.match(Const) {
+		return p.constant()
+	}
+
 	return p.statement()
 }
 
+// importDeclaration loads and parses an imported file, every file is
+// imported once by a program.
+func (p *Parser) importDeclaration() (Stmt, error) {
+	token, _ := p.previous()
+
+	name, err := p.consume(String)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.terminator(); err != nil {
+		return nil, err
+	}
+
+	path := name.Literal
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(p.Path), path)
+	}
+	path = filepath.Clean(path)
+
+	for j, file := range p.imports.chain {
+		if file == path {
+			cycle := append(p.imports.chain[j:len(p.imports.chain):len(p.imports.chain)], path)
+			return nil, errorAt(name, "circular import %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if p.imports.imported[path] {
+		return ImportStmt{token, path, nil}, nil
+	}
+	p.imports.imported[path] = true
+
+	loader := p.Loader
+	if loader == nil {
+		loader = FileLoader{}
+	}
+
+	source, err := loader.Load(path)
+	if err != nil {
+		return nil, errorAt(name, "cannot import %s: %v", path, err)
+	}
+
+	scanner := Scanner{source}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		return nil, errorAt(name, "%s: %v", path, err)
+	}
+
+	parser := Parser{Tokens: tokens, Path: path, Loader: p.Loader, imports: p.imports}
+	stmts, err := parser.Parse()
+	if err != nil {
+		return nil, errorAt(name, "%s: %v", path, err)
+	}
+
+	return ImportStmt{token, path, stmts}, nil
+}
+
 func (p *Parser) variable() (Stmt, error) {
+	keyword, _ := p.previous()
+
 	token, err := p.consume(Identifier)
 	if err != nil {
 		return nil, err
 	}
 
+	if p.match(Comma) {
+		return p.destructuring(keyword, token, false)
+	}
+
 	var initializer Expr
 	if p.match(Equal) {
 		if initializer, err = p.expression(); err != nil {
@@ -102,43 +194,223 @@ func (p *Parser) variable() (Stmt, error) {
 		}
 	}
 
-	if _, err := p.consume(Semicolon); err != nil {
+	if err := p.terminator(); err != nil {
+		return nil, err
+	}
+
+	return Declaration{token, initializer, false, newSlot()}, nil
+}
+
+func (p *Parser) constant() (Stmt, error) {
+	keyword, _ := p.previous()
+
+	token, err := p.consume(Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.match(Comma) {
+		return p.destructuring(keyword, token, true)
+	}
+
+	// a constant without a value would be nil forever
+	if _, err := p.consume(Equal); err != nil {
+		return nil, err
+	}
+
+	initializer, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.terminator(); err != nil {
+		return nil, err
+	}
+
+	return Declaration{token, initializer, true, newSlot()}, nil
+}
+
+// destructuring parses the rest of a declaration of several names, after
+// the first one and its comma, which must have a value.
+func (p *Parser) destructuring(keyword Token, first Token, constant bool) (Stmt, error) {
+	names := []Token{first}
+	for true {
+		name, err := p.consume(Identifier)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range names {
+			if n.Lexeme == name.Lexeme {
+				return nil, errorAt(name, "duplicate name %s", name.Lexeme)
+			}
+		}
+
+		names = append(names, name)
+
+		if !p.match(Comma) {
+			break
+		}
+	}
+
+	if _, err := p.consume(Equal); err != nil {
+		return nil, err
+	}
+
+	initializer, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.terminator(); err != nil {
 		return nil, err
 	}
 
-	return Declaration{token, initializer}, nil
+	slots := make([]*Slot, len(names))
+	for j := range slots {
+		slots[j] = newSlot()
+	}
+
+	return Destructuring{keyword, names, initializer, constant, slots}, nil
+}
+
+// terminator consumes the semicolon ending a statement.
+func (p *Parser) terminator() error {
+	if p.omitted() {
+		return nil
+	}
+
+	_, err := p.consume(Semicolon)
+	return err
+}
+
+// omitted reports whether the semicolon ending a statement is left out
+// before a closing brace.
+func (p *Parser) omitted() bool {
+	return p.Lines && p.peek().TokenType == RightSquare
+}
+
+// newlines skips the semicolons inserted at the ends of lines by
+// ScanLines where a statement or a body may start, and returns the next
+// token.
+func (p *Parser) newlines() Token {
+	for t := p.peek(); t.TokenType == Semicolon && t.Literal == insertedSemicolon; t = p.peek() {
+		p.advance()
+	}
+
+	return p.peek()
 }
 
 func (p *Parser) statement() (Stmt, error) {
+	p.newlines()
+
+	if p.current+1 < len(p.Tokens) && p.peek().TokenType == Identifier && p.Tokens[p.current+1].TokenType == Colon {
+		return p.labeled()
+	}
+
+	if p.match(Break) {
+		token, _ := p.previous()
+
+		var label Token
+		if p.match(Identifier) {
+			label, _ = p.previous()
+		}
+
+		if err := p.terminator(); err != nil {
+			return nil, err
+		}
+
+		return BreakStmt{token, label}, nil
+	}
+
 	if p.match(Class) {
 		token, err := p.consume(Identifier)
 		if err != nil {
 			return nil, err
 		}
 
+		var superclass Expr
+		if p.match(Less) {
+			name, err := p.consume(Identifier)
+			if err != nil {
+				return nil, err
+			}
+
+			superclass = Variable{name, newSlot()}
+		}
+
+		p.newlines()
 		if _, err := p.consume(LeftSquare); err != nil {
 			return nil, err
 		}
 
-		var methods []Function
-		for p.peek().TokenType != RightSquare && !p.isEnd() {
-			f, err := p.function()
+		var methods, statics []Function
+		var fields []Declaration
+		for p.newlines().TokenType != RightSquare && !p.isEnd() {
+			comments := p.leading()
+
+			if p.match(Var) {
+				d, err := p.variable()
+				if err != nil {
+					return nil, err
+				}
+
+				fields = append(fields, d.(Declaration))
+				p.annotate(d.(Declaration).Token, comments, p.trailing())
+
+				continue
+			}
+
+			if p.match(Class) {
+				f, err := p.function()
+				if err != nil {
+					return nil, err
+				}
+
+				m, _ := f.(Function)
+				statics = append(statics, m)
+				p.annotate(m.Name, comments, p.trailing())
+
+				continue
+			}
+
+			m, err := p.method()
 			if err != nil {
 				return nil, err
 			}
 
-			m, _ := f.(Function)
 			methods = append(methods, m)
+			p.annotate(m.Name, comments, p.trailing())
 		}
 
+		// the comments closing the body are kept by the name of the class
+		p.annotate(token, p.leading(), nil)
+
 		if _, err := p.consume(RightSquare); err != nil {
 			return nil, err
 		}
 
-		return ClassStmt{token, methods}, nil
+		return ClassStmt{token, superclass, methods, statics, fields, newSlot()}, nil
+	}
+
+	if p.match(Continue) {
+		token, _ := p.previous()
+
+		var label Token
+		if p.match(Identifier) {
+			label, _ = p.previous()
+		}
+
+		if err := p.terminator(); err != nil {
+			return nil, err
+		}
+
+		return ContinueStmt{token, label}, nil
 	}
 
 	if p.match(If) {
+		token, _ := p.previous()
+
 		if _, err := p.consume(LeftParenthesis); err != nil {
 			return nil, err
 		}
@@ -164,14 +436,20 @@ func (p *Parser) statement() (Stmt, error) {
 			}
 		}
 
-		return IfStmt{condition, thenBranch, elseBranch}, nil
+		return IfStmt{token, condition, thenBranch, elseBranch}, nil
 	}
 
 	if p.match(For) {
+		token, _ := p.previous()
+
 		if _, err := p.consume(LeftParenthesis); err != nil {
 			return nil, err
 		}
 
+		if p.iterates() {
+			return p.forIn(token)
+		}
+
 		var init Stmt
 		var err error
 
@@ -222,7 +500,7 @@ func (p *Parser) statement() (Stmt, error) {
 			return nil, err
 		}
 
-		return ForStmt{init, condition, increment, body}, nil
+		return ForStmt{token, init, condition, increment, body}, nil
 	}
 
 	if p.match(Fun) {
@@ -230,32 +508,83 @@ func (p *Parser) statement() (Stmt, error) {
 	}
 
 	if p.match(Print) {
+		token, _ := p.previous()
 		expr, err := p.expression()
 		if err != nil {
 			return nil, err
 		}
 
-		if _, err := p.consume(Semicolon); err != nil {
+		if err := p.terminator(); err != nil {
 			return nil, err
 		}
 
-		return PrintStmt{expr}, nil
+		return PrintStmt{token, expr}, nil
 	}
 
 	if p.match(Return) {
+		token, _ := p.previous()
+
+		// a bare return returns nil
+		var expr Expr
+		if p.peek().TokenType != Semicolon && !p.omitted() {
+			var err error
+			if expr, err = p.expression(); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := p.terminator(); err != nil {
+			return nil, err
+		}
+
+		return ReturnStmt{token, expr}, nil
+	}
+
+	if p.match(Switch) {
+		return p.switchStatement()
+	}
+
+	if p.match(Defer) {
+		token, _ := p.previous()
+
+		stmt, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+
+		return DeferStmt{token, stmt}, nil
+	}
+
+	if p.match(Do) {
+		return p.doWhile()
+	}
+
+	if p.match(Enum) {
+		return p.enum()
+	}
+
+	if p.match(Throw) {
+		token, _ := p.previous()
+
 		expr, err := p.expression()
 		if err != nil {
 			return nil, err
 		}
 
-		if _, err := p.consume(Semicolon); err != nil {
+		if err := p.terminator(); err != nil {
 			return nil, err
 		}
 
-		return ReturnStmt{expr}, nil
+		return ThrowStmt{token, expr}, nil
+	}
+
+	if p.match(Try) {
+		return p.tryStatement()
 	}
 
 	if p.match(While) {
+		token, _ := p.previous()
+
 		if _, err := p.consume(LeftParenthesis); err != nil {
 			return nil, err
 		}
@@ -274,7 +603,7 @@ func (p *Parser) statement() (Stmt, error) {
 			return nil, err
 		}
 
-		return WhileStmt{condition, body}, nil
+		return WhileStmt{token, condition, body}, nil
 	}
 
 	if p.match(LeftSquare) {
@@ -291,122 +620,444 @@ func (p *Parser) statement() (Stmt, error) {
 		return nil, err
 	}
 
-	if _, err := p.consume(Semicolon); err != nil {
+	if err := p.terminator(); err != nil {
 		return nil, err
 	}
 
 	return ExprStmt{expr}, nil
 }
 
-func (p *Parser) function() (Stmt, error) {
-	name, err := p.consume(Identifier)
-	if err != nil {
-		return nil, err
+// iterates reports whether the clauses of a for loop are those of a
+// for..in, like (x in xs) or (var x in xs). in is not a keyword, it is
+// only special there, after the name of the variable: it can still name
+// a variable, like in for (in in ins).
+func (p *Parser) iterates() bool {
+	next := p.current
+	if p.Tokens[next].TokenType == Var {
+		next++
 	}
 
-	if _, err := p.consume(LeftParenthesis); err != nil {
-		return nil, err
-	}
+	return next+1 < len(p.Tokens) && p.Tokens[next].TokenType == Identifier && isIn(p.Tokens[next+1])
+}
 
-	var arguments []Token
-	if p.peek().TokenType != RightParenthesis {
-		for true {
-			token, err := p.consume(Identifier)
-			if err != nil {
-				return nil, err
-			}
+// isIn reports whether t is the in of a for..in.
+func isIn(t Token) bool {
+	return t.TokenType == Identifier && t.Lexeme == "in"
+}
 
-			arguments = append(arguments, token)
+func (p *Parser) forIn(token Token) (Stmt, error) {
+	p.match(Var)
 
-			if !p.match(Comma) {
-				break
-			}
-		}
+	name, err := p.consume(Identifier)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, err := p.consume(RightParenthesis); err != nil {
+	// checked by iterates
+	p.advance()
+
+	iterable, err := p.expression()
+	if err != nil {
 		return nil, err
 	}
 
-	if _, err := p.consume(LeftSquare); err != nil {
+	if _, err := p.consume(RightParenthesis); err != nil {
 		return nil, err
 	}
 
-	body, err := p.block()
+	body, err := p.statement()
 	if err != nil {
 		return nil, err
 	}
 
-	return Function{name, nil, arguments, body}, nil
+	return ForIn{token, name, iterable, body}, nil
 }
 
-func (p *Parser) block() ([]Stmt, error) {
-	var stmts []Stmt
-
-	for !(p.peek().TokenType == RightSquare) && !p.isEnd() {
-		stmt, err := p.declaration()
-		if err != nil {
-			return nil, err
-		}
+func (p *Parser) switchStatement() (Stmt, error) {
+	token, _ := p.previous()
 
-		stmts = append(stmts, stmt)
+	if _, err := p.consume(LeftParenthesis); err != nil {
+		return nil, err
 	}
 
-	if _, err := p.consume(RightSquare); err != nil {
+	discriminant, err := p.expression()
+	if err != nil {
 		return nil, err
 	}
 
-	return stmts, nil
-}
-
-func (p *Parser) expression() (Expr, error) {
-	return p.assignment()
-}
+	if _, err := p.consume(RightParenthesis); err != nil {
+		return nil, err
+	}
 
-func (p *Parser) assignment() (Expr, error) {
-	expr, err := p.or()
-	if err != nil {
+	p.newlines()
+	if _, err := p.consume(LeftSquare); err != nil {
 		return nil, err
 	}
 
-	if p.match(Equal) {
-		if t, ok := p.previous(); ok {
-			value, err := p.assignment()
-			if err != nil {
-				return nil, err
-			}
+	// the body of a clause runs until the next clause or the closing brace
+	body := func() ([]Stmt, error) {
+		if _, err := p.consume(Colon); err != nil {
+			return nil, err
+		}
 
-			if v, ok := expr.(Variable); ok {
-				return Assign{v, t, value}, nil
-			} else if g, ok := expr.(Get); ok {
-				return Set{g.Object, g.Name, value}, nil
+		var stmts []Stmt
+		for t := p.newlines().TokenType; t != Case && t != Default && t != RightSquare && !p.isEnd(); t = p.newlines().TokenType {
+			start := p.current
+			comments := p.leading()
+
+			stmt, err := p.declaration()
+			if err != nil {
+				p.synchronize(err, start, true)
+				continue
 			}
 
-			return nil, fmt.Errorf("error at line %d: invalid assignment target", t.Line)
+			stmts = append(stmts, p.attach(stmt, comments))
 		}
+
+		return stmts, nil
 	}
 
-	return expr, nil
-}
+	var cases []CaseClause
+	var otherwise Stmt
 
-func (p *Parser) or() (Expr, error) {
-	expr, err := p.and()
-	if err != nil {
-		return nil, err
-	}
+	for p.newlines().TokenType != RightSquare && !p.isEnd() {
+		if p.match(Case) {
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
 
-	for p.match(Or) {
-		if operator, ok := p.previous(); ok {
-			right, err := p.and()
+			stmts, err := body()
 			if err != nil {
 				return nil, err
 			}
 
-			expr = Logical{expr, operator, right}
+			cases = append(cases, CaseClause{value, Block{stmts}})
+		} else if p.match(Default) {
+			if otherwise != nil {
+				t, _ := p.previous()
+				return nil, errorAt(t, "multiple default clauses")
+			}
+
+			stmts, err := body()
+			if err != nil {
+				return nil, err
+			}
+
+			otherwise = Block{stmts}
+		} else {
+			return nil, errorAt(p.peek(), "expected 'CASE' or 'DEFAULT'")
 		}
 	}
 
-	return expr, nil
+	if _, err := p.consume(RightSquare); err != nil {
+		return nil, err
+	}
+
+	return SwitchStmt{token, discriminant, cases, otherwise}, nil
+}
+
+func (p *Parser) tryStatement() (Stmt, error) {
+	if _, err := p.consume(LeftSquare); err != nil {
+		return nil, err
+	}
+
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(Catch); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(LeftParenthesis); err != nil {
+		return nil, err
+	}
+
+	name, err := p.consume(Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(RightParenthesis); err != nil {
+		return nil, err
+	}
+
+	p.newlines()
+	if _, err := p.consume(LeftSquare); err != nil {
+		return nil, err
+	}
+
+	catch, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+
+	return TryStmt{Block{body}, name, Block{catch}}, nil
+}
+
+func (p *Parser) function() (Stmt, error) {
+	name, err := p.consume(Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := p.functionBody()
+	if err != nil {
+		return nil, err
+	}
+
+	f.Name = name
+
+	return f, nil
+}
+
+// method parses a method of a class, a method without a parameter list is
+// a getter.
+func (p *Parser) method() (Function, error) {
+	name, err := p.consume(Identifier)
+	if err != nil {
+		return Function{}, err
+	}
+
+	if p.match(LeftSquare) {
+		body, err := p.block()
+		if err != nil {
+			return Function{}, err
+		}
+
+		return Function{name, nil, nil, nil, false, true, body, newSlot()}, nil
+	}
+
+	f, err := p.functionBody()
+	if err != nil {
+		return Function{}, err
+	}
+
+	f.Name = name
+
+	return f, nil
+}
+
+// functionBody parses the parameter list and the body shared by function
+// declarations and lambdas, a trailing '...name' makes the function variadic
+// and 'name = expr' gives a parameter a default value. Like argument lists
+// and list, map and record literals, it may end with a comma.
+func (p *Parser) functionBody() (Function, error) {
+	if _, err := p.consume(LeftParenthesis); err != nil {
+		return Function{}, err
+	}
+
+	var arguments []Token
+	var defaults []Expr
+	variadic := false
+
+	if p.peek().TokenType != RightParenthesis {
+		for true {
+			comments := p.leading()
+			variadic = p.match(Ellipsis)
+
+			token, err := p.consume(Identifier)
+			if err != nil {
+				return Function{}, err
+			}
+
+			var value Expr
+			if !variadic && p.match(Equal) {
+				if value, err = p.expression(); err != nil {
+					return Function{}, err
+				}
+			}
+
+			arguments = append(arguments, token)
+			defaults = append(defaults, value)
+
+			more := p.match(Comma)
+			p.annotate(token, comments, p.trailing())
+
+			if !more || p.peek().TokenType == RightParenthesis {
+				break
+			}
+
+			if variadic {
+				return Function{}, errorAt(token, "variadic parameter must be the last one")
+			}
+		}
+	}
+
+	if _, err := p.consume(RightParenthesis); err != nil {
+		return Function{}, err
+	}
+
+	p.newlines()
+	if _, err := p.consume(LeftSquare); err != nil {
+		return Function{}, err
+	}
+
+	body, err := p.block()
+	if err != nil {
+		return Function{}, err
+	}
+
+	return Function{Token{}, nil, arguments, defaults, variadic, false, body, newSlot()}, nil
+}
+
+func (p *Parser) block() ([]Stmt, error) {
+	var stmts []Stmt
+
+	for p.newlines().TokenType != RightSquare && !p.isEnd() {
+		start := p.current
+		comments := p.leading()
+
+		stmt, err := p.declaration()
+		if err != nil {
+			p.synchronize(err, start, true)
+			continue
+		}
+
+		stmts = append(stmts, p.attach(stmt, comments))
+	}
+
+	if comments := p.leading(); len(comments) > 0 {
+		stmts = append(stmts, Commented{nil, comments, nil})
+	}
+
+	if _, err := p.consume(RightSquare); err != nil {
+		return nil, err
+	}
+
+	return stmts, nil
+}
+
+func (p *Parser) expression() (Expr, error) {
+	return p.assignment()
+}
+
+func (p *Parser) assignment() (Expr, error) {
+	expr, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.match(Equal) {
+		if t, ok := p.previous(); ok {
+			value, err := p.assignment()
+			if err != nil {
+				return nil, err
+			}
+
+			if v, ok := expr.(Variable); ok {
+				return Assign{v, t, value}, nil
+			} else if g, ok := expr.(Get); ok && !g.Optional {
+				return Set{g.Object, g.Name, value, Token{}}, nil
+			} else if i, ok := expr.(Index); ok {
+				return IndexSet{i.Object, i.Bracket, i.Index, value, Token{}}, nil
+			}
+
+			return nil, errorAt(t, "invalid assignment target")
+		}
+	}
+
+	if p.match(PlusEqual, MinusEqual, StarEqual, SlashEqual) {
+		if t, ok := p.previous(); ok {
+			value, err := p.assignment()
+			if err != nil {
+				return nil, err
+			}
+
+			// the binary operator of the compound assignment, e.g. '+' for '+='
+			operator := Token{compound[t.TokenType], t.Lexeme[:1], "", t.Line, t.Column, t.File}
+
+			if v, ok := expr.(Variable); ok {
+				return Assign{v, t, Binary{v, operator, value}}, nil
+			} else if g, ok := expr.(Get); ok && !g.Optional {
+				return Set{g.Object, g.Name, value, operator}, nil
+			} else if i, ok := expr.(Index); ok {
+				return IndexSet{i.Object, i.Bracket, i.Index, value, operator}, nil
+			}
+
+			return nil, errorAt(t, "invalid assignment target")
+		}
+	}
+
+	return expr, nil
+}
+
+var compound = map[TokenType]TokenType{
+	PlusEqual:  Plus,
+	MinusEqual: Minus,
+	StarEqual:  Star,
+	SlashEqual: Slash,
+}
+
+func (p *Parser) ternary() (Expr, error) {
+	condition, err := p.coalesce()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.match(Question) {
+		then, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.consume(Colon); err != nil {
+			return nil, err
+		}
+
+		// right-associative: a ? b : c ? d : e is a ? b : (c ? d : e)
+		otherwise, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+
+		return Ternary{condition, then, otherwise}, nil
+	}
+
+	return condition, nil
+}
+
+// coalesce parses a ?? b, which binds looser than or.
+func (p *Parser) coalesce() (Expr, error) {
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.match(QuestionQuestion) {
+		if operator, ok := p.previous(); ok {
+			right, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+
+			expr = Logical{expr, operator, right}
+		}
+	}
+
+	return expr, nil
+}
+
+func (p *Parser) or() (Expr, error) {
+	expr, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.match(Or) {
+		if operator, ok := p.previous(); ok {
+			right, err := p.and()
+			if err != nil {
+				return nil, err
+			}
+
+			expr = Logical{expr, operator, right}
+		}
+	}
+
+	return expr, nil
 }
 
 func (p *Parser) and() (Expr, error) {
@@ -455,7 +1106,7 @@ func (p *Parser) comparison() (Expr, error) {
 		return nil, err
 	}
 
-	for p.match(Greater, GreaterEqual, Less, LessEqual) {
+	for p.match(Greater, GreaterEqual, Less, LessEqual, Is) {
 		if operator, ok := p.previous(); ok {
 			right, err := p.addition()
 			if err != nil {
@@ -495,7 +1146,7 @@ func (p *Parser) multiplication() (Expr, error) {
 		return nil, err
 	}
 
-	for p.match(Slash, Star) {
+	for p.match(Slash, SlashSlash, Star, Percent) {
 		if operator, ok := p.previous(); ok {
 			right, err := p.unary()
 			if err != nil {
@@ -521,7 +1172,29 @@ func (p *Parser) unary() (Expr, error) {
 		}
 	}
 
-	return p.call()
+	return p.power()
+}
+
+func (p *Parser) power() (Expr, error) {
+	expr, err := p.call()
+	if err != nil {
+		return nil, err
+	}
+
+	// right-associative and binding tighter than unary operators on its
+	// left, so -2 ** 2 is -(2 ** 2) and 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if p.match(StarStar) {
+		if operator, ok := p.previous(); ok {
+			right, err := p.unary()
+			if err != nil {
+				return nil, err
+			}
+
+			return Binary{expr, operator, right}, nil
+		}
+	}
+
+	return expr, nil
 }
 
 func (p *Parser) call() (Expr, error) {
@@ -532,17 +1205,22 @@ func (p *Parser) call() (Expr, error) {
 
 	for true {
 		if p.match(LeftParenthesis) {
+			paren, _ := p.previous()
+
 			var arguments []Expr
 			if p.peek().TokenType != RightParenthesis {
 				for true {
+					comments := p.leading()
+
 					argument, err := p.expression()
 					if err != nil {
 						return nil, err
 					}
 
-					arguments = append(arguments, argument)
+					more := p.match(Comma)
+					arguments = append(arguments, p.element(argument, comments))
 
-					if !p.match(Comma) {
+					if !more || p.peek().TokenType == RightParenthesis {
 						break
 					}
 				}
@@ -552,14 +1230,29 @@ func (p *Parser) call() (Expr, error) {
 				return nil, err
 			}
 
-			return Call{expr, arguments}, nil
-		} else if p.match(Dot) {
+			expr = Call{expr, paren, arguments}
+		} else if p.match(Dot, QuestionDot) {
+			dot, _ := p.previous()
+
 			property, err := p.consume(Identifier)
 			if err != nil {
 				return nil, err
 			}
 
-			return Get{property, expr}, nil
+			expr = Get{property, expr, dot.TokenType == QuestionDot}
+		} else if p.match(LeftBracket) {
+			bracket, _ := p.previous()
+
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+
+			if _, err := p.consume(RightBracket); err != nil {
+				return nil, err
+			}
+
+			expr = Index{expr, bracket, index}
 		} else {
 			break
 		}
@@ -600,7 +1293,27 @@ func (p *Parser) primary() (Expr, error) {
 
 	if p.match(Identifier) {
 		if token, ok := p.previous(); ok {
-			return Variable{token}, nil
+			return Variable{token, newSlot()}, nil
+		}
+	}
+
+	if p.match(Super) {
+		token, _ := p.previous()
+		if _, err := p.consume(Dot); err != nil {
+			return nil, err
+		}
+
+		method, err := p.consume(Identifier)
+		if err != nil {
+			return nil, err
+		}
+
+		return SuperExpr{token, method, newSlot(), newSlot()}, nil
+	}
+
+	if p.match(This) {
+		if token, ok := p.previous(); ok {
+			return ThisExpr{token, newSlot()}, nil
 		}
 	}
 
@@ -617,19 +1330,450 @@ func (p *Parser) primary() (Expr, error) {
 		return Grouping{expr}, nil
 	}
 
-	return nil, fmt.Errorf("error at line %d: unknown token '%s'", p.peek().Line, p.peek().Literal)
+	if p.match(Fun) {
+		token, _ := p.previous()
+
+		f, err := p.functionBody()
+		if err != nil {
+			return nil, err
+		}
+
+		return Lambda{token, f.Arguments, f.Defaults, f.Variadic, f.Body}, nil
+	}
+
+	if p.match(LeftBracket) {
+		bracket, _ := p.previous()
+
+		var elements []Expr
+		if p.peek().TokenType != RightBracket {
+			for true {
+				comments := p.leading()
+
+				element, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+
+				more := p.match(Comma)
+				elements = append(elements, p.element(element, comments))
+
+				if !more || p.peek().TokenType == RightBracket {
+					break
+				}
+			}
+		}
+
+		if _, err := p.consume(RightBracket); err != nil {
+			return nil, err
+		}
+
+		return List{bracket, elements}, nil
+	}
+
+	if p.match(LeftSquare) {
+		brace, _ := p.previous()
+
+		// bare names as keys make a record, {(k): v} is a map keyed by
+		// the value of the variable k
+		if p.current+1 < len(p.Tokens) && p.peek().TokenType == Identifier && p.Tokens[p.current+1].TokenType == Colon {
+			return p.record(brace)
+		}
+
+		var keys, values []Expr
+		if p.peek().TokenType != RightSquare {
+			for true {
+				comments := p.leading()
+
+				key, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+
+				if _, err := p.consume(Colon); err != nil {
+					return nil, err
+				}
+
+				value, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+
+				// the key holds the comments of the entry
+				more := p.match(Comma)
+				keys = append(keys, p.element(key, comments))
+				values = append(values, value)
+
+				if !more || p.peek().TokenType == RightSquare {
+					break
+				}
+			}
+		}
+
+		if _, err := p.consume(RightSquare); err != nil {
+			return nil, err
+		}
+
+		return Map{brace, keys, values}, nil
+	}
+
+	return nil, errorAt(p.peek(), "unknown token '%s'", p.peek().Lexeme)
+}
+
+// record parses the fields of a record literal after its brace.
+func (p *Parser) record(brace Token) (Expr, error) {
+	var names []Token
+	var values []Expr
+
+	for true {
+		comments := p.leading()
+
+		name, err := p.consume(Identifier)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, n := range names {
+			if n.Lexeme == name.Lexeme {
+				return nil, errorAt(name, "duplicate field %s", name.Lexeme)
+			}
+		}
+
+		if _, err := p.consume(Colon); err != nil {
+			return nil, err
+		}
+
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		// the value holds the comments of the field
+		more := p.match(Comma)
+		names = append(names, name)
+		values = append(values, p.element(value, comments))
+
+		if !more || p.peek().TokenType == RightSquare {
+			break
+		}
+	}
+
+	if _, err := p.consume(RightSquare); err != nil {
+		return nil, err
+	}
+
+	return Record{brace, names, values}, nil
 }
 
 func (p *Parser) Parse() ([]Stmt, error) {
+	stmts := p.parse()
+
+	if len(p.errors) > 0 {
+		return nil, p.errors
+	}
+
+	return stmts, nil
+}
+
+// parse parses the statements of the program, leaving out the ones with
+// syntax errors, which are recorded.
+func (p *Parser) parse() []Stmt {
 	var stmts []Stmt
 
-	for !p.isEnd() {
+	if p.imports == nil {
+		p.imports = &imports{nil, make(map[string]bool)}
+		if p.Path != "" {
+			p.imports.imported[filepath.Clean(p.Path)] = true
+		}
+	}
+
+	if p.Path != "" {
+		p.imports.chain = append(p.imports.chain, filepath.Clean(p.Path))
+		defer func() { p.imports.chain = p.imports.chain[:len(p.imports.chain)-1] }()
+	}
+
+	p.takeComments()
+
+	for p.newlines(); !p.isEnd(); p.newlines() {
+		start := p.current
+		comments := p.leading()
+
 		if stmt, err := p.declaration(); err != nil {
-			return nil, err
+			p.synchronize(err, start, false)
 		} else {
-			stmts = append(stmts, stmt)
+			stmts = append(stmts, p.attach(stmt, comments))
 		}
 	}
 
-	return stmts, nil
+	if comments := p.leading(); len(comments) > 0 {
+		stmts = append(stmts, Commented{nil, comments, nil})
+	}
+
+	return stmts
+}
+
+// takeComments moves the comments out of the tokens, they are given
+// back by leading as the parser reaches them.
+func (p *Parser) takeComments() {
+	tokens := make([]Token, 0, len(p.Tokens))
+	for _, t := range p.Tokens {
+		if t.TokenType == Comment {
+			p.comments = append(p.comments, t)
+		} else {
+			tokens = append(tokens, t)
+		}
+	}
+
+	if len(p.comments) > 0 {
+		p.Tokens = tokens
+	}
+}
+
+// leading returns the comments before the next token.
+func (p *Parser) leading() []Token {
+	next := p.peek()
+
+	n := 0
+	for n < len(p.comments) && before(p.comments[n], next) {
+		n++
+	}
+
+	comments := p.comments[:n]
+	p.comments = p.comments[n:]
+
+	return comments
+}
+
+// trailing returns the comments between the last token parsed and the
+// next one, on the line of the last one.
+func (p *Parser) trailing() []Token {
+	last, _ := p.previous()
+	next := p.peek()
+
+	var comments, rest []Token
+	for _, c := range p.comments {
+		if c.Line == last.Line && before(last, c) && before(c, next) {
+			comments = append(comments, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+
+	if len(comments) > 0 {
+		p.comments = rest
+	}
+
+	return comments
+}
+
+// before reports whether token a comes before token b in the source.
+func before(a Token, b Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// attach returns stmt with the comments preceding it, leading, and the
+// ones following it on its last line, if any.
+func (p *Parser) attach(stmt Stmt, leading []Token) Stmt {
+	if len(p.comments) == 0 && len(leading) == 0 {
+		return stmt
+	}
+
+	trailing := p.trailing()
+	if len(leading) == 0 && len(trailing) == 0 {
+		return stmt
+	}
+
+	return Commented{stmt, leading, trailing}
+}
+
+// element returns an element of a comma separated list with the comments
+// preceding it, leading, and the ones following it on its line, if any.
+func (p *Parser) element(e Expr, leading []Token) Expr {
+	if len(p.comments) == 0 && len(leading) == 0 {
+		return e
+	}
+
+	trailing := p.trailing()
+	if len(leading) == 0 && len(trailing) == 0 {
+		return e
+	}
+
+	return CommentedExpr{e, leading, trailing}
+}
+
+// annotation holds the comments around a member of a class or an enum or
+// around a parameter, which are not statements nor expressions.
+type annotation struct {
+	leading  []Token
+	trailing []Token
+}
+
+// annotate records the comments around the member or the parameter named
+// by t, if any.
+func (p *Parser) annotate(t Token, leading []Token, trailing []Token) {
+	if len(leading) == 0 && len(trailing) == 0 {
+		return
+	}
+
+	if p.annotations == nil {
+		p.annotations = make(map[Token]annotation)
+	}
+
+	p.annotations[t] = annotation{leading, trailing}
+}
+
+// synchronize records a syntax error of the declaration starting at start
+// and skips to the beginning of the next statement, so that the following
+// errors are reported too. A nested declaration is in a block, which ends
+// at the first unbalanced '}'.
+func (p *Parser) synchronize(err error, start int, nested bool) {
+	p.errors = append(p.errors, parseError(err))
+
+	// skip at least a token not to stop on the same error
+	if p.current == start {
+		p.advance()
+	}
+
+	depth := 0 // of the braces being skipped, like a function body
+	for !p.isEnd() {
+		if t, _ := p.previous(); depth == 0 && t.TokenType == Semicolon {
+			return
+		}
+
+		switch t := p.peek().TokenType; {
+		case t == LeftSquare:
+			{
+				depth++
+			}
+		case t == RightSquare && depth > 0:
+			{
+				depth--
+				if depth == 0 {
+					p.advance()
+					return
+				}
+			}
+		case t == RightSquare && nested:
+			{
+				return
+			}
+		case depth == 0 && isStatementStart(t):
+			{
+				return
+			}
+		}
+
+		p.advance()
+	}
+}
+
+// labeled parses a loop after a label, like outer: while (true) {}.
+func (p *Parser) labeled() (Stmt, error) {
+	label, _ := p.advance()
+	p.advance() // the colon
+
+	switch p.peek().TokenType {
+	case Do, For, While:
+		{
+			loop, err := p.statement()
+			if err != nil {
+				return nil, err
+			}
+
+			return Labeled{label, loop}, nil
+		}
+	}
+
+	return nil, errorAt(p.peek(), "expected a loop after label %s", label.Lexeme)
+}
+
+// doWhile parses a do/while loop after its do keyword.
+func (p *Parser) doWhile() (Stmt, error) {
+	token, _ := p.previous()
+
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(While); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(LeftParenthesis); err != nil {
+		return nil, err
+	}
+
+	condition, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.consume(RightParenthesis); err != nil {
+		return nil, err
+	}
+
+	if err := p.terminator(); err != nil {
+		return nil, err
+	}
+
+	return DoWhile{token, body, condition}, nil
+}
+
+// enum parses the name and the members of an enum after its keyword, the
+// members are separated by commas.
+func (p *Parser) enum() (Stmt, error) {
+	name, err := p.consume(Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	p.newlines()
+	if _, err := p.consume(LeftSquare); err != nil {
+		return nil, err
+	}
+
+	var members []Token
+	for p.newlines().TokenType != RightSquare && !p.isEnd() {
+		comments := p.leading()
+
+		member, err := p.consume(Identifier)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range members {
+			if m.Lexeme == member.Lexeme {
+				return nil, errorAt(member, "duplicate member %s", member.Lexeme)
+			}
+		}
+
+		members = append(members, member)
+
+		more := p.match(Comma)
+		p.annotate(member, comments, p.trailing())
+
+		if !more {
+			break
+		}
+	}
+
+	// the comments closing the body are kept by the name of the enum
+	p.annotate(name, p.leading(), nil)
+
+	p.newlines()
+	if _, err := p.consume(RightSquare); err != nil {
+		return nil, err
+	}
+
+	return EnumStmt{name, members, newSlot()}, nil
+}
+
+// isStatementStart reports whether a token type starts a statement.
+func isStatementStart(t TokenType) bool {
+	switch t {
+	case Break, Class, Const, Continue, Defer, Do, Enum, For, Fun, If, Import, Print, Return, Switch, Throw, Try, Var, While:
+		return true
+	}
+
+	return false
 }
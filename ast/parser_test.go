@@ -0,0 +1,229 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParser_Recovery(t *testing.T) {
+	table := []struct {
+		in   string
+		errs []string
+	}{
+		{
+			"var = 1;\nprint 1 +;\nfun f( { return 1; }\nprint \"fine\";",
+			[]string{
+				"error at line 1, col 5: expected 'IDENTIFIER'",
+				"error at line 2, col 10: unknown token ';'",
+				"error at line 3, col 8: expected 'IDENTIFIER'",
+			},
+		},
+		{
+			"fun f() {\n  var x = ;\n  print x\n}\nif (true) print 1;\nclass { }",
+			[]string{
+				"error at line 2, col 11: unknown token ';'",
+				"error at line 4, col 1: expected 'SEMICOLON'",
+				"error at line 6, col 7: expected 'IDENTIFIER'",
+			},
+		},
+		{
+			"{ print 1 }\nwhile (x print 2;\nswitch (x) { case 1: var; }",
+			[]string{
+				"error at line 1, col 11: expected 'SEMICOLON'",
+				"error at line 2, col 10: expected 'RIGHT_PARENTHESIS'",
+				"error at line 3, col 25: expected 'IDENTIFIER'",
+			},
+		},
+		{
+			"}\nprint 1;",
+			[]string{
+				"error at line 1, col 1: unknown token '}'",
+			},
+		},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := parse(test.in)
+
+			errs, ok := err.(ErrorList)
+			if !ok {
+				t.Fatalf("want ErrorList, got %T: %v", err, err)
+			}
+
+			if len(errs) != len(test.errs) {
+				t.Fatalf("want %d errors, got %d: %v", len(test.errs), len(errs), err)
+			}
+
+			for j, e := range errs {
+				if e.Error() != test.errs[j] {
+					t.Errorf("error %d: want %q, got %q", j, test.errs[j], e.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestParser_RecoveryErrorString(t *testing.T) {
+	_, err := parse("var = 1;\nvar = 2;")
+	if want := "error at line 1, col 5: expected 'IDENTIFIER'\nerror at line 2, col 5: expected 'IDENTIFIER'"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestParser_Comments(t *testing.T) {
+	source := `// greets
+print "hi"; // inline
+{
+  var x = 1;
+  // closing
+}
+// the end`
+
+	scanner := Scanner{source}
+	tokens, err := scanner.ScanComments()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stmts) != 3 {
+		t.Fatalf("want 3 statements, got %d", len(stmts))
+	}
+
+	first, ok := stmts[0].(Commented)
+	if !ok {
+		t.Fatalf("want a commented statement, got %T", stmts[0])
+	}
+
+	if _, ok := first.Stmt.(PrintStmt); !ok {
+		t.Errorf("want the print statement, got %T", first.Stmt)
+	}
+
+	if len(first.Leading) != 1 || first.Leading[0].Lexeme != "// greets" {
+		t.Errorf("want the leading comment, got %v", first.Leading)
+	}
+
+	if len(first.Trailing) != 1 || first.Trailing[0].Lexeme != "// inline" {
+		t.Errorf("want the trailing comment, got %v", first.Trailing)
+	}
+
+	// the comments closing a block stay in it
+	block, ok := stmts[1].(Block)
+	if !ok {
+		t.Fatalf("want a block, got %T", stmts[1])
+	}
+
+	if c, ok := block.Stmts[1].(Commented); !ok || c.Stmt != nil || c.Leading[0].Lexeme != "// closing" {
+		t.Errorf("want the closing comment, got %v", block.Stmts[1])
+	}
+
+	if c, ok := stmts[2].(Commented); !ok || c.Stmt != nil || c.Leading[0].Lexeme != "// the end" {
+		t.Errorf("want the final comment, got %v", stmts[2])
+	}
+
+	// comments do not change what the program does
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+
+	if err := i.Run(stmts); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != "hi\n" {
+		t.Errorf("want hi, got %q", b.String())
+	}
+}
+
+func TestParser_TrailingCommas(t *testing.T) {
+	table := []struct {
+		in   string
+		want string
+	}{
+		{"f(1, 2,);", "f(1, 2);"},
+		{"f(1,);", "f(1);"},
+		{"f();", "f();"},
+		{"fun g(a, b,) {}", "fun g(a, b) {}"},
+		{"fun g(a = 1,) {}", "fun g(a = 1) {}"},
+		{"fun g(a, ...rest,) {}", "fun g(a, ...rest) {}"},
+		{"var v = fun (a,) {};", "var v = fun (a) {};"},
+		{"[1, 2,];", "[1, 2];"},
+		{"[[],];", "[[]];"},
+		{"[];", "[];"},
+		{`var v = {"a": 1, "b": 2,};`, `var v = {"a": 1, "b": 2};`},
+		{"var v = {};", "var v = {};"},
+		{"var v = {x: 1, y: 2,};", "var v = {x: 1, y: 2};"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			stmts, err := parse(test.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want, err := parse(test.want)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var p Printer
+			if got, want := p.Stmt(stmts[0]), p.Stmt(want[0]); got != want {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestParser_TrailingCommaErrors(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"f(,);", "error at line 1, col 3: unknown token ','"},
+		{"f(1,,);", "error at line 1, col 5: unknown token ','"},
+		{"fun g(,) {}", "error at line 1, col 7: expected 'IDENTIFIER'"},
+		{"[,];", "error at line 1, col 2: unknown token ','"},
+		{"[1,,];", "error at line 1, col 4: unknown token ','"},
+		{`var v = {"a": 1,,};`, "error at line 1, col 17: unknown token ','"},
+		{"var v = {x: 1,,};", "error at line 1, col 15: expected 'IDENTIFIER'"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			_, err := parse(test.in)
+			if err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Printer renders the AST as parenthesized Lisp-style strings, e.g. the
+// expression (1 + 2) * 3 is printed as (* (group (+ 1 2)) 3).
+type Printer struct {
+	out string
+}
+
+// PrintExpr returns the Lisp-style representation of an expression.
+func PrintExpr(e Expr) string {
+	p := Printer{}
+	return p.Expr(e)
+}
+
+// PrintStmts returns the Lisp-style representation of a program, one
+// statement per line.
+func PrintStmts(stmts []Stmt) string {
+	p := Printer{}
+
+	return strings.Join(p.stmts(stmts), "\n")
+}
+
+func (p *Printer) Expr(e Expr) string {
+	_ = e.Accept(p)
+	return p.out
+}
+
+func (p *Printer) Stmt(s Stmt) string {
+	if commentsOnly(s) {
+		return ""
+	}
+
+	_ = s.Accept(p)
+	return p.out
+}
+
+func (p *Printer) parenthesize(name string, parts ...string) error {
+	p.out = "(" + strings.Join(append([]string{name}, parts...), " ") + ")"
+	return nil
+}
+
+func (p *Printer) exprs(exprs []Expr) []string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = p.Expr(e)
+	}
+
+	return parts
+}
+
+// stmts renders stmts, leaving out the ones holding only comments.
+func (p *Printer) stmts(stmts []Stmt) []string {
+	parts := make([]string, 0, len(stmts))
+	for _, s := range stmts {
+		if !commentsOnly(s) {
+			parts = append(parts, p.Stmt(s))
+		}
+	}
+
+	return parts
+}
+
+func (p *Printer) function(f Function) error {
+	if f.Getter {
+		return p.parenthesize("get", append([]string{f.Name.Lexeme}, p.stmts(f.Body)...)...)
+	}
+
+	names := make([]string, len(f.Arguments))
+	for i, argument := range f.Arguments {
+		names[i] = argument.Lexeme
+
+		if i < len(f.Defaults) && f.Defaults[i] != nil {
+			names[i] = "(= " + names[i] + " " + p.Expr(f.Defaults[i]) + ")"
+		}
+	}
+
+	if f.Variadic {
+		names[len(names)-1] = "..." + names[len(names)-1]
+	}
+
+	parts := []string{"(" + strings.Join(names, " ") + ")"}
+	if f.Name.Lexeme != "" {
+		parts = append([]string{f.Name.Lexeme}, parts...)
+	}
+
+	return p.parenthesize("fun", append(parts, p.stmts(f.Body)...)...)
+}
+
+func (p *Printer) visitAssign(a Assign) error {
+	return p.parenthesize("=", a.Variable.Lexeme, p.Expr(a.Expr))
+}
+
+func (p *Printer) visitBinary(b Binary) error {
+	return p.parenthesize(b.Operator.Lexeme, p.Expr(b.Left), p.Expr(b.Right))
+}
+
+func (p *Printer) visitCall(c Call) error {
+	return p.parenthesize("call", append([]string{p.Expr(c.Callee)}, p.exprs(c.Arguments)...)...)
+}
+
+func (p *Printer) visitGet(g Get) error {
+	if g.Optional {
+		return p.parenthesize("?.", p.Expr(g.Object), g.Name.Lexeme)
+	}
+
+	return p.parenthesize(".", p.Expr(g.Object), g.Name.Lexeme)
+}
+
+func (p *Printer) visitGrouping(g Grouping) error {
+	return p.parenthesize("group", p.Expr(g.Expr))
+}
+
+func (p *Printer) visitIndex(i Index) error {
+	return p.parenthesize("index", p.Expr(i.Object), p.Expr(i.Index))
+}
+
+func (p *Printer) visitIndexSet(i IndexSet) error {
+	target := "(index " + p.Expr(i.Object) + " " + p.Expr(i.Index) + ")"
+	return p.parenthesize(i.Operator.Lexeme+"=", target, p.Expr(i.Value))
+}
+
+func (p *Printer) visitLambda(l Lambda) error {
+	return p.function(*l.function(nil))
+}
+
+func (p *Printer) visitList(l List) error {
+	return p.parenthesize("list", p.exprs(l.Elements)...)
+}
+
+func (p *Printer) visitLiteral(l Literal) error {
+	if s, ok := l.Value.(string); ok {
+		p.out = strconv.Quote(s)
+	} else {
+		p.out = l.String()
+	}
+
+	return nil
+}
+
+func (p *Printer) visitLogical(l Logical) error {
+	return p.parenthesize(l.Operator.Lexeme, p.Expr(l.Left), p.Expr(l.Right))
+}
+
+func (p *Printer) visitMap(m Map) error {
+	var parts []string
+	for i := range m.Keys {
+		parts = append(parts, p.Expr(m.Keys[i]), p.Expr(m.Values[i]))
+	}
+
+	return p.parenthesize("map", parts...)
+}
+
+func (p *Printer) visitRecord(r Record) error {
+	var parts []string
+	for i, name := range r.Names {
+		parts = append(parts, name.Lexeme, p.Expr(r.Values[i]))
+	}
+
+	return p.parenthesize("record", parts...)
+}
+
+func (p *Printer) visitSet(s Set) error {
+	target := "(. " + p.Expr(s.Object) + " " + s.Name.Lexeme + ")"
+	return p.parenthesize(s.Operator.Lexeme+"=", target, p.Expr(s.Value))
+}
+
+func (p *Printer) visitSuperExpr(s SuperExpr) error {
+	return p.parenthesize("super", s.Method.Lexeme)
+}
+
+func (p *Printer) visitTernary(t Ternary) error {
+	return p.parenthesize("?:", p.Expr(t.Condition), p.Expr(t.Then), p.Expr(t.Else))
+}
+
+func (p *Printer) visitThisExpr(t ThisExpr) error {
+	p.out = "this"
+	return nil
+}
+
+func (p *Printer) visitUnary(u Unary) error {
+	return p.parenthesize(u.Operator.Lexeme, p.Expr(u.Right))
+}
+
+func (p *Printer) visitVariable(v Variable) error {
+	p.out = v.Lexeme
+	return nil
+}
+
+func (p *Printer) visitBlock(b Block) error {
+	return p.parenthesize("block", p.stmts(b.Stmts)...)
+}
+
+func (p *Printer) visitBreakStmt(b BreakStmt) error {
+	if b.Label.Lexeme != "" {
+		return p.parenthesize("break", b.Label.Lexeme)
+	}
+
+	return p.parenthesize("break")
+}
+
+func (p *Printer) visitClassStmt(c ClassStmt) error {
+	parts := []string{c.Name.Lexeme}
+	if c.Superclass != nil {
+		parts = append(parts, "(< "+p.Expr(c.Superclass)+")")
+	}
+
+	for _, field := range c.Fields {
+		parts = append(parts, p.Stmt(field))
+	}
+
+	for _, method := range c.Methods {
+		parts = append(parts, p.Stmt(method))
+	}
+
+	for _, method := range c.Statics {
+		parts = append(parts, "(static "+p.Stmt(method)+")")
+	}
+
+	return p.parenthesize("class", parts...)
+}
+
+func (p *Printer) visitEnumStmt(e EnumStmt) error {
+	parts := []string{e.Name.Lexeme}
+	for _, member := range e.Members {
+		parts = append(parts, member.Lexeme)
+	}
+
+	return p.parenthesize("enum", parts...)
+}
+
+func (p *Printer) visitContinueStmt(c ContinueStmt) error {
+	if c.Label.Lexeme != "" {
+		return p.parenthesize("continue", c.Label.Lexeme)
+	}
+
+	return p.parenthesize("continue")
+}
+
+func (p *Printer) visitDeclaration(d Declaration) error {
+	if d.Const {
+		return p.parenthesize("const", d.Lexeme, p.Expr(d.Expr))
+	}
+
+	if d.Expr == nil {
+		return p.parenthesize("var", d.Lexeme)
+	}
+
+	return p.parenthesize("var", d.Lexeme, p.Expr(d.Expr))
+}
+
+func (p *Printer) visitDeferStmt(d DeferStmt) error {
+	return p.parenthesize("defer", p.Stmt(d.Deferred))
+}
+
+func (p *Printer) visitDestructuring(d Destructuring) error {
+	parts := make([]string, len(d.Names))
+	for j, name := range d.Names {
+		parts[j] = name.Lexeme
+	}
+
+	keyword := "var"
+	if d.Const {
+		keyword = "const"
+	}
+
+	return p.parenthesize(keyword, "("+strings.Join(parts, " ")+")", p.Expr(d.Expr))
+}
+
+func (p *Printer) visitExprStmt(e ExprStmt) error {
+	return p.parenthesize(";", p.Expr(e.Expr))
+}
+
+func (p *Printer) visitForIn(f ForIn) error {
+	return p.parenthesize("for-in", f.Name.Lexeme, p.Expr(f.Iterable), p.Stmt(f.Body))
+}
+
+func (p *Printer) visitForStmt(f ForStmt) error {
+	// omitted clauses are printed as ()
+	init, condition, increment := "()", "()", "()"
+
+	if f.Init != nil {
+		init = p.Stmt(f.Init)
+	}
+
+	if f.Condition != nil {
+		condition = p.Expr(f.Condition)
+	}
+
+	if f.Increment != nil {
+		increment = p.Expr(f.Increment)
+	}
+
+	return p.parenthesize("for", init, condition, increment, p.Stmt(f.Body))
+}
+
+func (p *Printer) visitFunction(f Function) error {
+	return p.function(f)
+}
+
+func (p *Printer) visitImportStmt(i ImportStmt) error {
+	return p.parenthesize("import", strconv.Quote(i.Path))
+}
+
+func (p *Printer) visitLabeled(l Labeled) error {
+	return p.parenthesize("label", l.Label.Lexeme, p.Stmt(l.Loop))
+}
+
+func (p *Printer) visitIfStmt(i IfStmt) error {
+	if i.Else == nil {
+		return p.parenthesize("if", p.Expr(i.Condition), p.Stmt(i.Then))
+	}
+
+	return p.parenthesize("if", p.Expr(i.Condition), p.Stmt(i.Then), p.Stmt(i.Else))
+}
+
+func (p *Printer) visitPrintStmt(s PrintStmt) error {
+	return p.parenthesize("print", p.Expr(s.Expr))
+}
+
+func (p *Printer) visitReturnStmt(r ReturnStmt) error {
+	if r.Expr == nil {
+		return p.parenthesize("return")
+	}
+
+	return p.parenthesize("return", p.Expr(r.Expr))
+}
+
+func (p *Printer) visitSwitchStmt(s SwitchStmt) error {
+	parts := []string{p.Expr(s.Discriminant)}
+	for _, c := range s.Cases {
+		parts = append(parts, "(case "+p.Expr(c.Value)+" "+p.Stmt(c.Body)+")")
+	}
+
+	if s.Default != nil {
+		parts = append(parts, "(default "+p.Stmt(s.Default)+")")
+	}
+
+	return p.parenthesize("switch", parts...)
+}
+
+func (p *Printer) visitThrowStmt(t ThrowStmt) error {
+	return p.parenthesize("throw", p.Expr(t.Expr))
+}
+
+func (p *Printer) visitTryStmt(t TryStmt) error {
+	return p.parenthesize("try", p.Stmt(t.Body), "(catch "+t.Name.Lexeme+" "+p.Stmt(t.Catch)+")")
+}
+
+func (p *Printer) visitDoWhile(d DoWhile) error {
+	return p.parenthesize("do", p.Stmt(d.Body), p.Expr(d.Condition))
+}
+
+func (p *Printer) visitWhileStmt(w WhileStmt) error {
+	return p.parenthesize("while", p.Expr(w.Condition), p.Stmt(w.Body))
+}
@@ -0,0 +1,91 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestPrinter_Golden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "printer", "*.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			scanner := Scanner{string(source)}
+			tokens, err := scanner.Scan()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parser := Parser{Tokens: tokens}
+			stmts, err := parser.Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := PrintStmts(stmts) + "\n"
+
+			golden := strings.TrimSuffix(file, ".lox") + ".golden"
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(want) {
+				t.Errorf("want:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestPrintExpr(t *testing.T) {
+	expr := Binary{
+		Binary{Literal{1.0}, Token{Plus, "+", "", 1, 3, ""}, Literal{2.0}},
+		Token{Star, "*", "", 1, 8, ""},
+		Literal{3.0},
+	}
+
+	if s := PrintExpr(expr); s != "(* (+ 1 2) 3)" {
+		t.Errorf("want (* (+ 1 2) 3), got %s", s)
+	}
+}
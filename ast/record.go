@@ -0,0 +1,92 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "strings"
+
+// RecordValue is the runtime value of a record literal, an object without
+// a class whose fields are fixed by the literal. Like maps it is always
+// handled by pointer, fields are kept in the order of the literal.
+type RecordValue struct {
+	fields map[string]interface{}
+	names  []string
+}
+
+func NewRecordValue() *RecordValue {
+	return &RecordValue{make(map[string]interface{}), nil}
+}
+
+// define adds a field, or sets it if already there.
+func (r *RecordValue) define(name string, v interface{}) {
+	if _, ok := r.fields[name]; !ok {
+		r.names = append(r.names, name)
+	}
+
+	r.fields[name] = v
+}
+
+func (r *RecordValue) Get(t Token) (interface{}, error) {
+	v, ok := r.fields[t.Lexeme]
+	if !ok {
+		return nil, errorAt(t, "undefined field %s", t.Lexeme)
+	}
+
+	return v, nil
+}
+
+// Set sets a field of the literal, records cannot grow new fields.
+func (r *RecordValue) Set(t Token, v interface{}) error {
+	if _, ok := r.fields[t.Lexeme]; !ok {
+		return errorAt(t, "undefined field %s", t.Lexeme)
+	}
+
+	r.fields[t.Lexeme] = v
+
+	return nil
+}
+
+// Names returns the names of the fields in the order of the literal.
+func (r *RecordValue) Names() []string {
+	return r.names
+}
+
+func (r *RecordValue) String() string {
+	return r.render(rendering{})
+}
+
+func (r *RecordValue) render(seen rendering) string {
+	if seen[r] {
+		return "{...}"
+	}
+
+	seen[r] = true
+	defer delete(seen, r)
+
+	fields := make([]string, len(r.names))
+
+	for i, name := range r.names {
+		fields[i] = name + ": " + seen.repr(r.fields[name])
+	}
+
+	return "{" + strings.Join(fields, ", ") + "}"
+}
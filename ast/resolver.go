@@ -22,12 +22,22 @@
 
 package ast
 
-import "fmt"
+import "sort"
 
-type Scope map[string]bool
+// Binding is a name declared in a scope, Index is its slot in the
+// environment of the scope at runtime.
+type Binding struct {
+	Index   int
+	Defined bool
+	Const   bool  // const bindings cannot be reassigned
+	Read    bool  // the value is used after the declaration
+	Token   Token // of the declaration of local variables, to warn if never read
+}
+
+type Scope map[string]*Binding
 
 func NewScope() Scope {
-	return make(map[string]bool, 0)
+	return make(Scope)
 }
 
 type Stack struct {
@@ -63,47 +73,200 @@ func (s *Stack) Pop() (Scope, bool) {
 	return scope, false
 }
 
-func (s *Stack) Declare(name string) {
-	if s, ok := s.Head(); ok {
-		s[name] = false
+// Declare declares name in the innermost scope and returns its slot, a
+// name declared again in the same scope keeps its slot.
+func (s *Stack) Declare(name string) int {
+	h, ok := s.Head()
+	if !ok {
+		return -1
+	}
+
+	if b, ok := h[name]; ok {
+		b.Defined = false
+		b.Const = false
+
+		return b.Index
 	}
+
+	h[name] = &Binding{len(h), false, false, false, Token{}}
+
+	return h[name].Index
+}
+
+// DeclareConst declares a name that cannot be reassigned.
+func (s *Stack) DeclareConst(name string) int {
+	index := s.Declare(name)
+
+	if h, ok := s.Head(); ok {
+		h[name].Const = true
+	}
+
+	return index
 }
 
 func (s *Stack) Define(name string) {
-	if s, ok := s.Head(); ok {
-		s[name] = true
+	if h, ok := s.Head(); ok {
+		if b, ok := h[name]; ok {
+			b.Defined = true
+		}
 	}
 }
 
+// Lookup returns the innermost binding of name and the number of scopes
+// between it and the innermost scope.
+func (s *Stack) Lookup(name string) (*Binding, int, bool) {
+	for i := len(s.stack) - 1; i >= 0; i-- {
+		if b, ok := s.stack[i][name]; ok {
+			return b, len(s.stack) - 1 - i, true
+		}
+	}
+
+	return nil, 0, false
+}
+
+// IsConst reports whether the innermost binding of name is const.
+func (s *Stack) IsConst(name string) bool {
+	b, _, ok := s.Lookup(name)
+	return ok && b.Const
+}
+
 type Resolver struct {
 	Stack
-	Locals map[string]int
+	loops     int  // number of loops enclosing the current statement
+	classes   int  // number of classes enclosing the current statement
+	functions int  // number of functions enclosing the current statement
+	static    bool // the current statement is in a static method
+	derived   bool // the innermost class has a superclass
+	deferred  bool // the current statement is deferred by the innermost function
+
+	initializer bool // the innermost function is the init method of a class
+
+	labels []string // of the loops enclosing the current statement
+
+	// Warnings are the problems found that do not stop a program, like
+	// local variables never read, in source order
+	Warnings []*Warning
+
+	// Constants are the names of the global constants declared before the
+	// program, like by an earlier run of the same interpreter. Resolve
+	// replaces them with the ones declared after it.
+	Constants map[string]bool
 }
 
 func (r *Resolver) Resolve(stmts []Stmt) error {
-	r.Stack = NewStack()
-	r.Stack.Push(NewScope())
-	r.Locals = make(map[string]int, 0)
+	r.reset()
+	r.Warnings = nil
 
 	for _, stmt := range stmts {
 		if err := stmt.Accept(r); err != nil {
-			return err
+			return parseError(err)
+		}
+	}
+
+	// a global declared again with var is not constant anymore
+	constants := make(map[string]bool)
+	for name, b := range r.stack[0] {
+		if b.Const {
+			constants[name] = true
 		}
 	}
 
+	r.Constants = constants
+
 	return nil
 }
 
+// resolveAll resolves every top-level statement, returning the first
+// error of each.
+func (r *Resolver) resolveAll(stmts []Stmt) ErrorList {
+	var errs ErrorList
+
+	r.reset()
+	r.Warnings = nil
+
+	for _, stmt := range stmts {
+		if err := stmt.Accept(r); err != nil {
+			errs = append(errs, parseError(err))
+
+			// the scopes of the statement are left open by the error
+			global := r.stack[0]
+			r.reset()
+			r.stack[0] = global
+		}
+	}
+
+	return errs
+}
+
+func (r *Resolver) reset() {
+	r.Stack = NewStack()
+	r.Stack.Push(NewScope())
+
+	for name := range r.Constants {
+		r.Stack.DeclareConst(name)
+		r.Stack.Define(name)
+	}
+
+	r.loops = 0
+	r.classes = 0
+	r.functions = 0
+	r.static = false
+	r.deferred = false
+	r.initializer = false
+	r.derived = false
+	r.labels = nil
+}
+
+// declare declares the name of a variable, function or class and gives
+// it a slot unless it is global.
+func (r *Resolver) declare(name string, slot *Slot, constant bool) {
+	var index int
+	if constant {
+		index = r.Stack.DeclareConst(name)
+	} else {
+		index = r.Stack.Declare(name)
+	}
+
+	if slot == nil {
+		return
+	}
+
+	if len(r.stack) > 1 {
+		*slot = Slot{0, index}
+	} else {
+		*slot = *newSlot()
+	}
+}
+
 func (r *Resolver) beginScope() {
 	r.Stack.Push(NewScope())
 }
 
 func (r *Resolver) endScope() {
-	r.Stack.Pop()
+	scope, _ := r.Stack.Pop()
+
+	var unused []*Warning
+	for name, b := range scope {
+		if b.Token.Lexeme != "" && !b.Read {
+			unused = append(unused, warningAt(b.Token, "local variable %s is never read", name))
+		}
+	}
+
+	sort.Slice(unused, func(a, b int) bool {
+		s, t := unused[a], unused[b]
+		return s.Line < t.Line || s.Line == t.Line && s.Column < t.Column
+	})
+
+	r.Warnings = append(r.Warnings, unused...)
 }
 
 func (r *Resolver) visitAssign(a Assign) error {
-	if err := a.Variable.Accept(r); err != nil {
+	if r.Stack.IsConst(a.Variable.Lexeme) {
+		return errorAt(a.Variable.Token, "cannot assign to constant %s", a.Variable.Lexeme)
+	}
+
+	// assigning is not reading
+	if err := r.resolve(a.Variable); err != nil {
 		return err
 	}
 
@@ -138,9 +301,38 @@ func (r *Resolver) visitBlock(b Block) error {
 	return nil
 }
 
+func (r *Resolver) visitBreakStmt(b BreakStmt) error {
+	if r.loops == 0 {
+		return errorAt(b.Token, "cannot break outside of a loop")
+	}
+
+	return r.label(b.Label)
+}
+
+// label checks that the label of a break or continue, if any, is the one
+// of an enclosing loop.
+func (r *Resolver) label(label Token) error {
+	if label.Lexeme != "" && !r.labeled(label.Lexeme) {
+		return errorAt(label, "undefined label %s", label.Lexeme)
+	}
+
+	return nil
+}
+
+// labeled reports whether an enclosing loop has the label name.
+func (r *Resolver) labeled(name string) bool {
+	for _, l := range r.labels {
+		if l == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r *Resolver) visitCall(c Call) error {
 	if err := c.Callee.Accept(r); err != nil {
-		return nil
+		return err
 	}
 
 	for _, expr := range c.Arguments {
@@ -153,13 +345,88 @@ func (r *Resolver) visitCall(c Call) error {
 }
 
 func (r *Resolver) visitClassStmt(c ClassStmt) error {
-	r.Stack.Declare(c.Name.Lexeme)
+	r.declare(c.Name.Lexeme, c.Slot, false)
 	r.Stack.Define(c.Name.Lexeme)
+
+	if c.Superclass != nil {
+		if v, ok := c.Superclass.(Variable); ok && v.Lexeme == c.Name.Lexeme {
+			return errorAt(v.Token, "class %s cannot inherit from itself", v.Lexeme)
+		}
+
+		if err := c.Superclass.Accept(r); err != nil {
+			return err
+		}
+	}
+
+	r.classes++
+	enclosing, derived := r.static, r.derived
+
+	// super takes the only slot of a scope enclosing this
+	r.derived = c.Superclass != nil
+	if r.derived {
+		r.beginScope()
+		r.Stack.Declare("super")
+		r.Stack.Define("super")
+	}
+
+	r.static = false
+	for _, field := range c.Fields {
+		// initializers see the new instance as this, like methods
+		r.beginScope()
+		r.Stack.Declare("this")
+		r.Stack.Define("this")
+
+		if field.Expr != nil {
+			if err := field.Expr.Accept(r); err != nil {
+				return err
+			}
+		}
+		r.endScope()
+	}
+
+	for _, method := range c.Methods {
+		// this takes the only slot of a scope enclosing the method
+		r.beginScope()
+		r.Stack.Declare("this")
+		r.Stack.Define("this")
+
+		if err := r.resolveFunction(method, method.Name.Lexeme == "init" && !method.Getter); err != nil {
+			return err
+		}
+		r.endScope()
+	}
+
+	// static methods are not bound to an instance
+	r.static = true
+	for _, method := range c.Statics {
+		if err := r.resolveFunction(method, false); err != nil {
+			return err
+		}
+	}
+
+	if r.derived {
+		r.endScope()
+	}
+
+	r.static, r.derived = enclosing, derived
+	r.classes--
+
 	return nil
 }
 
+func (r *Resolver) visitContinueStmt(c ContinueStmt) error {
+	if r.loops == 0 {
+		return errorAt(c.Token, "cannot continue outside of a loop")
+	}
+
+	return r.label(c.Label)
+}
+
 func (r *Resolver) visitDeclaration(d Declaration) error {
-	r.Stack.Declare(d.Lexeme)
+	r.declare(d.Lexeme, d.Slot, d.Const)
+	if h, _ := r.Stack.Head(); len(r.stack) > 1 {
+		h[d.Lexeme].Token = d.Token
+	}
 	if d.Expr != nil {
 		if err := d.Expr.Accept(r); err != nil {
 			return err
@@ -170,6 +437,57 @@ func (r *Resolver) visitDeclaration(d Declaration) error {
 	return nil
 }
 
+// visitDeferStmt checks that the deferred statement cannot leave the
+// function or a loop enclosing the defer, which have exited when it runs.
+func (r *Resolver) visitDeferStmt(d DeferStmt) error {
+	if r.functions == 0 {
+		return errorAt(d.Token, "cannot defer outside of a function")
+	}
+
+	enclosing, labels, deferred := r.loops, r.labels, r.deferred
+	r.loops, r.labels, r.deferred = 0, nil, true
+
+	err := d.Deferred.Accept(r)
+
+	r.loops, r.labels, r.deferred = enclosing, labels, deferred
+
+	return err
+}
+
+func (r *Resolver) visitDestructuring(d Destructuring) error {
+	for j, name := range d.Names {
+		r.declare(name.Lexeme, d.Slots[j], d.Const)
+		if h, _ := r.Stack.Head(); len(r.stack) > 1 {
+			h[name.Lexeme].Token = name
+		}
+	}
+	if err := d.Expr.Accept(r); err != nil {
+		return err
+	}
+	for _, name := range d.Names {
+		r.Stack.Define(name.Lexeme)
+	}
+
+	return nil
+}
+
+func (r *Resolver) visitEnumStmt(e EnumStmt) error {
+	r.declare(e.Name.Lexeme, e.Slot, false)
+	r.Stack.Define(e.Name.Lexeme)
+
+	return nil
+}
+
+func (r *Resolver) visitDoWhile(d DoWhile) error {
+	r.loops++
+	if err := d.Body.Accept(r); err != nil {
+		return err
+	}
+	r.loops--
+
+	return d.Condition.Accept(r)
+}
+
 func (r *Resolver) visitExprStmt(e ExprStmt) error {
 	if err := e.Expr.Accept(r); err != nil {
 		return err
@@ -178,6 +496,26 @@ func (r *Resolver) visitExprStmt(e ExprStmt) error {
 	return nil
 }
 
+func (r *Resolver) visitForIn(f ForIn) error {
+	if err := f.Iterable.Accept(r); err != nil {
+		return err
+	}
+
+	// the element lives in a scope enclosing the body
+	r.beginScope()
+	r.Stack.Declare(f.Name.Lexeme)
+	r.Stack.Define(f.Name.Lexeme)
+
+	r.loops++
+	if err := f.Body.Accept(r); err != nil {
+		return err
+	}
+	r.loops--
+	r.endScope()
+
+	return nil
+}
+
 func (r *Resolver) visitForStmt(f ForStmt) error {
 	if f.Init != nil {
 		if err := f.Init.Accept(r); err != nil {
@@ -197,16 +535,52 @@ func (r *Resolver) visitForStmt(f ForStmt) error {
 		}
 	}
 
+	r.loops++
 	if err := f.Body.Accept(r); err != nil {
 		return err
 	}
+	r.loops--
 
 	return nil
 }
 
 func (r *Resolver) visitFunction(f Function) error {
+	// declared before the body so that the function can call itself
+	r.declare(f.Name.Lexeme, f.Slot, false)
+	r.Stack.Define(f.Name.Lexeme)
+
+	return r.resolveFunction(f, false)
+}
+
+// resolveFunction resolves the parameters and the body of a function, which
+// cannot return a value if it is an initializer.
+func (r *Resolver) resolveFunction(f Function, initializer bool) error {
+	// default values are evaluated in the scope of the declaration
+	defaulted := false
+	for j, argument := range f.Arguments {
+		if j < len(f.Defaults) && f.Defaults[j] != nil {
+			if err := f.Defaults[j].Accept(r); err != nil {
+				return err
+			}
+
+			defaulted = true
+		} else if defaulted && !(f.Variadic && j == len(f.Arguments)-1) {
+			return errorAt(argument, "required parameter %s follows a parameter with a default value", argument.Lexeme)
+		}
+	}
+
+	// a loop enclosing the declaration does not enclose the body
+	enclosing, labels, deferred, initializing := r.loops, r.labels, r.deferred, r.initializer
+	r.loops, r.labels, r.deferred, r.initializer = 0, nil, false, initializer
+	r.functions++
+
+	// arguments take the first slots in order, so they must be unique
 	r.beginScope()
 	for _, argument := range f.Arguments {
+		if h, _ := r.Stack.Head(); h[argument.Lexeme] != nil {
+			return errorAt(argument, "duplicate parameter %s", argument.Lexeme)
+		}
+
 		r.Stack.Declare(argument.Lexeme)
 		r.Stack.Define(argument.Lexeme)
 	}
@@ -218,6 +592,9 @@ func (r *Resolver) visitFunction(f Function) error {
 	}
 	r.endScope()
 
+	r.functions--
+	r.loops, r.labels, r.deferred, r.initializer = enclosing, labels, deferred, initializing
+
 	return nil
 }
 
@@ -233,6 +610,20 @@ func (r *Resolver) visitGrouping(g Grouping) error {
 	return nil
 }
 
+func (r *Resolver) visitImportStmt(i ImportStmt) error {
+	if len(r.stack) > 1 {
+		return errorAt(i.Token, "import must be at the top level")
+	}
+
+	for _, stmt := range i.Stmts {
+		if err := stmt.Accept(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Resolver) visitIfStmt(i IfStmt) error {
 	if err := i.Condition.Accept(r); err != nil {
 		return err
@@ -251,6 +642,54 @@ func (r *Resolver) visitIfStmt(i IfStmt) error {
 	return nil
 }
 
+func (r *Resolver) visitIndex(i Index) error {
+	if err := i.Object.Accept(r); err != nil {
+		return err
+	}
+
+	return i.Index.Accept(r)
+}
+
+func (r *Resolver) visitIndexSet(i IndexSet) error {
+	if err := i.Object.Accept(r); err != nil {
+		return err
+	}
+
+	if err := i.Index.Accept(r); err != nil {
+		return err
+	}
+
+	return i.Value.Accept(r)
+}
+
+func (r *Resolver) visitLambda(l Lambda) error {
+	return r.resolveFunction(*l.function(nil), false)
+}
+
+func (r *Resolver) visitLabeled(l Labeled) error {
+	if r.labeled(l.Label.Lexeme) {
+		return errorAt(l.Label, "label %s is already used by an enclosing loop", l.Label.Lexeme)
+	}
+
+	r.labels = append(r.labels, l.Label.Lexeme)
+	if err := l.Loop.Accept(r); err != nil {
+		return err
+	}
+	r.labels = r.labels[:len(r.labels)-1]
+
+	return nil
+}
+
+func (r *Resolver) visitList(l List) error {
+	for _, element := range l.Elements {
+		if err := element.Accept(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Resolver) visitLiteral(l Literal) error {
 	return nil
 }
@@ -267,6 +706,30 @@ func (r *Resolver) visitLogical(l Logical) error {
 	return nil
 }
 
+func (r *Resolver) visitMap(m Map) error {
+	for j := range m.Keys {
+		if err := m.Keys[j].Accept(r); err != nil {
+			return err
+		}
+
+		if err := m.Values[j].Accept(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Resolver) visitRecord(record Record) error {
+	for _, value := range record.Values {
+		if err := value.Accept(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (r *Resolver) visitPrintStmt(p PrintStmt) error {
 	if err := p.Expr.Accept(r); err != nil {
 		return err
@@ -276,11 +739,23 @@ func (r *Resolver) visitPrintStmt(p PrintStmt) error {
 }
 
 func (r *Resolver) visitReturnStmt(s ReturnStmt) error {
-	if err := s.Expr.Accept(r); err != nil {
-		return err
+	if r.functions == 0 {
+		return errorAt(s.Token, "cannot return from top-level code")
 	}
 
-	return nil
+	if r.deferred {
+		return errorAt(s.Token, "cannot return from a deferred statement")
+	}
+
+	if s.Expr == nil {
+		return nil
+	}
+
+	if r.initializer {
+		return errorAt(s.Token, "cannot return a value from an initializer")
+	}
+
+	return s.Expr.Accept(r)
 }
 
 func (r *Resolver) visitSet(s Set) error {
@@ -291,6 +766,69 @@ func (r *Resolver) visitSet(s Set) error {
 	return s.Value.Accept(r)
 }
 
+func (r *Resolver) visitSuperExpr(s SuperExpr) error {
+	if r.classes == 0 {
+		return errorAt(s.Token, "cannot use 'super' outside of a class")
+	}
+
+	if !r.derived {
+		return errorAt(s.Token, "cannot use 'super' in a class with no superclass")
+	}
+
+	if r.static {
+		return errorAt(s.Token, "cannot use 'super' in a static method")
+	}
+
+	if err := r.visitVariable(Variable{s.Token, s.Slot}); err != nil {
+		return err
+	}
+
+	this := s.Token
+	this.Lexeme = "this"
+
+	return r.visitVariable(Variable{this, s.This})
+}
+
+func (r *Resolver) visitSwitchStmt(s SwitchStmt) error {
+	if err := s.Discriminant.Accept(r); err != nil {
+		return err
+	}
+
+	for _, c := range s.Cases {
+		if err := c.Value.Accept(r); err != nil {
+			return err
+		}
+
+		if err := c.Body.Accept(r); err != nil {
+			return err
+		}
+	}
+
+	if s.Default != nil {
+		if err := s.Default.Accept(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Resolver) visitTernary(t Ternary) error {
+	if err := t.Condition.Accept(r); err != nil {
+		return err
+	}
+
+	if err := t.Then.Accept(r); err != nil {
+		return err
+	}
+
+	if err := t.Else.Accept(r); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (r *Resolver) visitUnary(u Unary) error {
 	if err := u.Right.Accept(r); err != nil {
 		return err
@@ -300,19 +838,72 @@ func (r *Resolver) visitUnary(u Unary) error {
 }
 
 func (r *Resolver) visitVariable(v Variable) error {
-	if s, ok := r.Stack.Head(); ok {
-		if b, ok := s[v.Lexeme]; ok && !b {
-			return fmt.Errorf("error at line %d: cannot read local variable in its own initializer\n", v.Line)
-		}
+	if err := r.resolve(v); err != nil {
+		return err
+	}
+
+	if b, _, ok := r.Stack.Lookup(v.Lexeme); ok {
+		b.Read = true
 	}
 
-	for i := len(r.stack) - 1; i >= 0; i-- {
-		if _, ok := r.stack[i][v.Lexeme]; ok {
-			r.Locals[fmt.Sprintf("%v%v", v.Lexeme, v.Line)] = len(r.stack) - 1 - i
-			break
+	return nil
+}
+
+// resolve gives the slot of the declaration of v to v.
+func (r *Resolver) resolve(v Variable) error {
+	// a global can be initialized with its previous value, or fail at
+	// runtime if there is none
+	if s, ok := r.Stack.Head(); ok && len(r.stack) > 1 {
+		if b, ok := s[v.Lexeme]; ok && !b.Defined {
+			return errorAt(v.Token, "cannot read local variable in its own initializer")
 		}
 	}
 
+	if v.Slot == nil {
+		return nil
+	}
+
+	// names of the outermost scope and undeclared ones are globals
+	if b, depth, ok := r.Stack.Lookup(v.Lexeme); ok && depth < len(r.stack)-1 {
+		*v.Slot = Slot{depth, b.Index}
+	} else {
+		*v.Slot = *newSlot()
+	}
+
+	return nil
+}
+
+func (r *Resolver) visitThisExpr(t ThisExpr) error {
+	if r.static {
+		return errorAt(t.Token, "cannot use 'this' in a static method")
+	}
+
+	if r.classes == 0 {
+		return errorAt(t.Token, "cannot use 'this' outside of a class")
+	}
+
+	return r.visitVariable(Variable{t.Token, t.Slot})
+}
+
+func (r *Resolver) visitThrowStmt(t ThrowStmt) error {
+	return t.Expr.Accept(r)
+}
+
+func (r *Resolver) visitTryStmt(t TryStmt) error {
+	if err := t.Body.Accept(r); err != nil {
+		return err
+	}
+
+	// the caught value lives in a scope enclosing the catch block
+	r.beginScope()
+	r.Stack.Declare(t.Name.Lexeme)
+	r.Stack.Define(t.Name.Lexeme)
+
+	if err := t.Catch.Accept(r); err != nil {
+		return err
+	}
+	r.endScope()
+
 	return nil
 }
 
@@ -321,9 +912,11 @@ func (r *Resolver) visitWhileStmt(w WhileStmt) error {
 		return err
 	}
 
+	r.loops++
 	if err := w.Body.Accept(r); err != nil {
 		return err
 	}
+	r.loops--
 
 	return nil
 }
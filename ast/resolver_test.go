@@ -0,0 +1,351 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"reflect"
+	"testing"
+)
+
+// resolve scans, parses and resolves a program.
+func resolve(source string) error {
+	scanner := Scanner{source}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		return err
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		return err
+	}
+
+	r := Resolver{}
+
+	return r.Resolve(stmts)
+}
+
+func TestResolver_Break(t *testing.T) {
+	table := []struct {
+		in  string
+		err bool
+	}{
+		{"while (true) break;", false},
+		{"for (;;) { if (true) break; }", false},
+		{"break;", true},
+		{"{ break; }", true},
+		{"if (true) break;", true},
+		{"while (true) { fun f() { break; } }", true},
+		{"fun f() { while (true) break; }", false},
+		{"do break; while (true);", false},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			err := resolve(test.in)
+			if test.err && err == nil {
+				t.Errorf("want error, got nil")
+			} else if !test.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestResolver_Continue(t *testing.T) {
+	table := []struct {
+		in  string
+		err bool
+	}{
+		{"while (true) continue;", false},
+		{"for (;;) { continue; }", false},
+		{"continue;", true},
+		{"while (true) { fun f() { continue; } }", true},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			err := resolve(test.in)
+			if test.err && err == nil {
+				t.Errorf("want error, got nil")
+			} else if !test.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestResolver_Const(t *testing.T) {
+	table := []struct {
+		in  string
+		err bool
+	}{
+		{"const PI = 3.14;", false},
+		{"const PI = 3.14; PI = 3;", true},
+		{"const PI = 3.14; PI += 1;", true},
+		{"{ const x = 1; x = 2; }", true},
+		{"const x = 1; fun f() { x = 2; }", true},
+		{"const x = 1; { var x = 2; x = 3; }", false},
+		{"const x = 1; fun f(x) { x = 2; }", false},
+		{"var x = 1; { const x = 2; } x = 3;", false},
+		{"const x = 1; var x = 2; x = 3;", false},
+		{"const x;", true},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			err := resolve(test.in)
+			if test.err && err == nil {
+				t.Errorf("want error, got nil")
+			} else if !test.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestResolver_ConstError(t *testing.T) {
+	err := resolve("const PI = 3.14;\nPI = 3;")
+	if want := "error at line 2, col 1: cannot assign to constant PI"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestResolver_DuplicateParameter(t *testing.T) {
+	err := resolve("fun f(a, b, a) {}")
+	if want := "error at line 1, col 13: duplicate parameter a"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestResolver_Slots(t *testing.T) {
+	scanner := Scanner{"var g = 1; fun f(a) { var b = a; { var c = b; print g + c; } }"}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parser := Parser{Tokens: tokens}
+	stmts, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	r := Resolver{}
+	if err := r.Resolve(stmts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f := stmts[1].(Function)
+	b := f.Body[0].(Declaration)
+	block := f.Body[1].(Block)
+	c := block.Stmts[0].(Declaration)
+	sum := block.Stmts[1].(PrintStmt).Expr.(Binary)
+
+	table := []struct {
+		name string
+		slot *Slot
+		want Slot
+	}{
+		{"g", stmts[0].(Declaration).Slot, Slot{-1, 0}},
+		{"f", f.Slot, Slot{-1, 0}},
+		{"b", b.Slot, Slot{0, 1}},
+		{"a in b", b.Expr.(Variable).Slot, Slot{0, 0}},
+		{"c", c.Slot, Slot{0, 0}},
+		{"b in c", c.Expr.(Variable).Slot, Slot{1, 1}},
+		{"g in print", sum.Left.(Variable).Slot, Slot{-1, 0}},
+		{"c in print", sum.Right.(Variable).Slot, Slot{0, 0}},
+	}
+
+	for _, test := range table {
+		t.Run(test.name, func(t *testing.T) {
+			if *test.slot != test.want {
+				t.Errorf("want %v, got %v", test.want, *test.slot)
+			}
+		})
+	}
+}
+
+func TestResolver_OwnInitializer(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"{ var a = a; }", "error at line 1, col 11: cannot read local variable in its own initializer"},
+		{"var a = 1; { var a = a + 1; }", "error at line 1, col 22: cannot read local variable in its own initializer"},
+		{"fun f() { var a = [1, a]; }", "error at line 1, col 23: cannot read local variable in its own initializer"},
+		{"{ const a = -a; }", "error at line 1, col 14: cannot read local variable in its own initializer"},
+		{"var a = 1; var a = a + 1;", ""},
+		{"{ var a = 1; { var b = a; print b; } }", ""},
+		// the function reads the variable when called, after it is defined
+		{"{ var f = fun () { return f; }; f(); }", ""},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			err := resolve(test.in)
+
+			if test.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestResolver_Unused(t *testing.T) {
+	table := []struct {
+		in   string
+		want []string
+	}{
+		{"{ var a = 1; }", []string{"warning at line 1, col 7: local variable a is never read"}},
+		{"{ var a = 1; print a; }", nil},
+		{"{ var a; a = 2; }", []string{"warning at line 1, col 7: local variable a is never read"}},
+		{"{ var a = 1; a = a + 1; }", nil},
+		{"{ var a = 1; fun f() { return a; } f(); }", nil},
+		{"{ const b = 1;\n  var a = 2; }", []string{
+			"warning at line 1, col 9: local variable b is never read",
+			"warning at line 2, col 7: local variable a is never read",
+		}},
+		// globals and parameters are not checked
+		{"var g = 1;", nil},
+		{"fun f(x) { return 1; }", nil},
+		{"fun f() { var x; }", []string{"warning at line 1, col 15: local variable x is never read"}},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			tokens, err := scanner.Scan()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			parser := Parser{Tokens: tokens}
+			stmts, err := parser.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			r := Resolver{}
+			if err := r.Resolve(stmts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, w := range r.Warnings {
+				got = append(got, w.String())
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestResolver_ThisOutsideClass(t *testing.T) {
+	err := resolve("fun f() { return this; }")
+	if want := "error at line 1, col 18: cannot use 'this' outside of a class"; err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestResolver_Call(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"this();", "error at line 1, col 1: cannot use 'this' outside of a class"},
+		{"fun f() { return this.g(); }", "error at line 1, col 18: cannot use 'this' outside of a class"},
+		{"f(this);", "error at line 1, col 3: cannot use 'this' outside of a class"},
+		{"print super.name();", "error at line 1, col 7: cannot use 'super' outside of a class"},
+		{"class A { name() { return super.name(); } }", "error at line 1, col 27: cannot use 'super' in a class with no superclass"},
+		{"class A {}\nclass B < A { class make() { return super.make(); } }", "error at line 2, col 37: cannot use 'super' in a static method"},
+	}
+
+	for _, row := range table {
+		t.Run(row.in, func(t *testing.T) {
+			if err := resolve(row.in); err == nil || err.Error() != row.err {
+				t.Errorf("want %q, got %v", row.err, err)
+			}
+		})
+	}
+}
+
+func TestResolver_ThisInStatic(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"class A { class f() { return this; } }", "error at line 1, col 30: cannot use 'this' in a static method"},
+		{"class A { class f() { fun g() { return this; } } }", "error at line 1, col 40: cannot use 'this' in a static method"},
+		{"class A { class f() { class B { g() { return this; } } } }", ""},
+	}
+
+	for _, row := range table {
+		t.Run(row.in, func(t *testing.T) {
+			err := resolve(row.in)
+			if row.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if row.err != "" && (err == nil || err.Error() != row.err) {
+				t.Errorf("want %q, got %v", row.err, err)
+			}
+		})
+	}
+}
+
+func TestResolver_Return(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"return 1;", "error at line 1, col 1: cannot return from top-level code"},
+		{"{ return; }", "error at line 1, col 3: cannot return from top-level code"},
+		{"while (true) { if (true) return 1; }", "error at line 1, col 26: cannot return from top-level code"},
+		{"class A { init() { return 1; } }", "error at line 1, col 20: cannot return a value from an initializer"},
+		{"class A { init(x) { if (x) return nil; } }", "error at line 1, col 28: cannot return a value from an initializer"},
+		{"class A { init() { return; } }", ""},
+		{"class A { init() { var f = fun () { return 1; }; } }", ""},
+		{"class A { class init() { return 1; } }", ""},
+		{"class A { get() { return 1; } }", ""},
+		{"fun f() { return; }", ""},
+		{"fun f() { return 1; }", ""},
+		{"var f = fun () { { return 1; } };", ""},
+	}
+
+	for _, row := range table {
+		t.Run(row.in, func(t *testing.T) {
+			err := resolve(row.in)
+			if row.err == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if row.err != "" && (err == nil || err.Error() != row.err) {
+				t.Errorf("want %q, got %v", row.err, err)
+			}
+		})
+	}
+}
@@ -22,21 +22,156 @@
 
 package ast
 
-import "fmt"
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'\\': '\\',
+	'"':  '"',
+	'0':  '\x00',
+}
 
 type Scanner struct {
 	Text string
 }
 
 func (s *Scanner) Scan() ([]Token, error) {
+	return s.scan(false, nil)
+}
+
+// ScanComments scans like Scan but keeps the comments, as Comment tokens
+// holding their text, for tools working on the source like the formatter.
+func (s *Scanner) ScanComments() ([]Token, error) {
+	return s.scan(true, nil)
+}
+
+// ScanLines scans like Scan but also ends statements at line breaks, so
+// that semicolons can be left out, with rules similar to those of Go. A
+// semicolon is inserted at the end of a line, and of the text, if its last
+// token is an identifier, a number, a string, true, false, nil, this,
+// break, continue, return or a closing parenthesis, bracket or brace, and
+// it is not inside parentheses or brackets, unless in braces within them,
+// like in the body of a lambda passed as an argument. An expression can
+// then span lines only by breaking them after an operator or a comma, and:
+//
+//   - a map or record on several lines must close on the line of its last
+//     entry;
+//   - else, catch and the while of a do must follow the closing brace
+//     before them on the same line;
+//   - as without ScanLines, a // after an operand is a floor division if
+//     more follows it on the line, so a comment after an expression must
+//     follow a semicolon or be a block comment.
+//
+// The parser skips inserted semicolons where a statement or a body may
+// start, like after the header of a loop or a function, so it can be on
+// the next line. With Parser.Lines set, a semicolon can also be left out
+// before a closing brace, like in if (x) { print x }. The files imported
+// by a program are scanned with Scan, they need their semicolons.
+func (s *Scanner) ScanLines() ([]Token, error) {
+	tokens, err := s.scan(false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return insertSemicolons(tokens), nil
+}
+
+// insertedSemicolon is the literal of the semicolons inserted by
+// ScanLines, which are not in the text.
+const insertedSemicolon = "\n"
+
+// insertSemicolons inserts semicolons at the ends of lines, the tokens end
+// with Eof.
+func insertSemicolons(tokens []Token) []Token {
+	inserted := make([]Token, 0, len(tokens))
+
+	// open parentheses, brackets and braces
+	var open []TokenType
+
+	for j, t := range tokens {
+		switch t.TokenType {
+		case LeftParenthesis, LeftBracket, LeftSquare:
+			{
+				open = append(open, t.TokenType)
+			}
+		case RightParenthesis, RightBracket, RightSquare:
+			{
+				if len(open) > 0 {
+					open = open[:len(open)-1]
+				}
+			}
+		}
+
+		inserted = append(inserted, t)
+
+		if t.TokenType == Eof || len(open) > 0 && open[len(open)-1] != LeftSquare {
+			continue
+		}
+
+		// a string can span lines, the next token must start after its end
+		line := t.Line + strings.Count(t.Lexeme, "\n")
+		if next := tokens[j+1]; next.TokenType != Eof && next.Line == line && next.File == t.File || !endsStatement(t) {
+			continue
+		}
+
+		column := t.Column + utf8.RuneCountInString(t.Lexeme)
+		if k := strings.LastIndex(t.Lexeme, "\n"); k >= 0 {
+			column = utf8.RuneCountInString(t.Lexeme[k+1:]) + 1
+		}
+
+		inserted = append(inserted, Token{Semicolon, ";", insertedSemicolon, line, column, t.File})
+	}
+
+	return inserted
+}
+
+// endsStatement reports whether a statement can end with t, so that a
+// semicolon is inserted after it at the end of a line.
+func endsStatement(t Token) bool {
+	switch t.TokenType {
+	case Break, Continue, False, Identifier, Nil, Number, Return, RightBracket, RightParenthesis, RightSquare, String, This, True:
+		return true
+	}
+
+	return false
+}
+
+// scan scans the text, keeping the comments if asked to. Errors stop the
+// scan unless recovered is given, then they are appended to it and the
+// scan goes on after them.
+func (s *Scanner) scan(comments bool, recovered *ErrorList) ([]Token, error) {
 	runes := []rune(s.Text)
 
 	start := 0
 	current := 0
 	line := 1
+	lineStart := 0 // offset of the first rune of the current line
+
+	// position of the token being scanned
+	startLine := 1
+	startColumn := 1
+
+	// set by #line directives, added to line and the file of the tokens
+	delta := 0
+	file := ""
 
 	tokens := make([]Token, 0)
 
+	// whether each open '(' starts the condition of a statement or the
+	// parameters of a function and each open '{' the body of a class, and
+	// the index of the last token ending such a header: a '//' after it
+	// starts a comment
+	var parens, braces []bool
+	header := -1
+
 	isEnd := func() bool {
 		return current >= len(runes)
 	}
@@ -62,6 +197,16 @@ func (s *Scanner) Scan() ([]Token, error) {
 		return runes[current-1]
 	}
 
+	// newline is called after consuming a '\n', tabs count as a single column
+	newline := func() {
+		line++
+		lineStart = current
+	}
+
+	column := func() int {
+		return current - lineStart + 1
+	}
+
 	isNext := func(r rune) bool {
 		if isEnd() || runes[current] != r {
 			return false
@@ -80,16 +225,163 @@ func (s *Scanner) Scan() ([]Token, error) {
 		return false
 	}
 
+	isHexDigit := func(r rune) bool {
+		return isDigit(r) || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+	}
+
+	isBinaryDigit := func(r rune) bool {
+		return r == '0' || r == '1'
+	}
+
+	// identifiers can be written in any script, numbers only with ASCII digits
 	isLetter := func(r rune) bool {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
-			return true
-		}
+		return unicode.IsLetter(r)
+	}
 
-		return false
+	// token returns a token starting at the start position
+	token := func(tokenType TokenType, lexeme string, literal string) Token {
+		return Token{tokenType, lexeme, literal, startLine + delta, startColumn, file}
 	}
 
 	addToken := func(tokenType TokenType) {
-		tokens = append(tokens, Token{tokenType, string(runes[start:current]), "", line})
+		tokens = append(tokens, token(tokenType, string(runes[start:current]), ""))
+	}
+
+	// directive scans a #line N "file" directive, which must be alone on
+	// its line, so that the next line is line N of file, or of the same
+	// file if none is given
+	directive := func() error {
+		for _, r := range runes[lineStart:start] {
+			if r != ' ' && r != '\t' && r != '\r' {
+				return scanError(startLine, startColumn, "unknown character '#'")
+			}
+		}
+
+		for unicode.IsLetter(peek()) {
+			advance()
+		}
+
+		if name := string(runes[start+1 : current]); name != "line" {
+			return scanError(startLine, startColumn, "unknown directive #%s", name)
+		}
+
+		skip := func() {
+			for peek() == ' ' || peek() == '\t' || peek() == '\r' {
+				advance()
+			}
+		}
+
+		skip()
+		digits := current
+		for isDigit(peek()) {
+			advance()
+		}
+
+		n, err := strconv.Atoi(string(runes[digits:current]))
+		if err != nil || n < 1 {
+			return scanError(line, digits-lineStart+1, "invalid line number in #line directive")
+		}
+
+		skip()
+		name := file
+		if isNext('"') {
+			quoted := current
+			for peek() != '"' {
+				if isEnd() || peek() == '\n' {
+					return scanError(line, quoted-lineStart, "unterminated file name in #line directive")
+				}
+
+				advance()
+			}
+
+			name = string(runes[quoted:current])
+			advance()
+		}
+
+		skip()
+		if !isEnd() && peek() != '\n' {
+			return scanError(line, column(), "unexpected '%s' after #line directive", string(peek()))
+		}
+
+		if comments {
+			addToken(Comment)
+		}
+
+		delta, file = n-(line+1), name
+
+		return nil
+	}
+
+	// digits consumes a run of digits, an underscore can only separate
+	// two digits
+	digits := func(valid func(rune) bool) error {
+		for valid(peek()) || peek() == '_' {
+			if advance() == '_' && !valid(peek()) {
+				return scanError(line, column()-1, "invalid '_' in number literal")
+			}
+		}
+
+		return nil
+	}
+
+	// number scans a number literal starting with the digit r, the token
+	// literal is always in decimal notation
+	number := func(r rune) error {
+		base := 10
+		if r == '0' && (peek() == 'x' || peek() == 'X') {
+			base = 16
+		} else if r == '0' && (peek() == 'b' || peek() == 'B') {
+			base = 2
+		}
+
+		if base == 10 {
+			if err := digits(isDigit); err != nil {
+				return err
+			}
+
+			if peek() == '.' && peekNext() == '_' {
+				return scanError(line, column()+1, "invalid '_' in number literal")
+			}
+
+			if peek() == '.' && isDigit(peekNext()) {
+				advance()
+
+				if err := digits(isDigit); err != nil {
+					return err
+				}
+			}
+
+			lexeme := string(runes[start:current])
+			tokens = append(tokens, token(Number, lexeme, strings.Replace(lexeme, "_", "", -1)))
+
+			return nil
+		}
+
+		advance() // the base prefix
+
+		valid := isHexDigit
+		if base == 2 {
+			valid = isBinaryDigit
+		}
+
+		if !valid(peek()) {
+			return scanError(line, column(), "missing digits after '%s'", string(runes[start:current]))
+		}
+
+		if err := digits(valid); err != nil {
+			return err
+		}
+
+		if isDigit(peek()) || isLetter(peek()) {
+			return scanError(line, column(), "invalid digit '%s' in number literal", string(peek()))
+		}
+
+		n, _ := new(big.Int).SetString(strings.Replace(string(runes[start+2:current]), "_", "", -1), base)
+		value, _ := new(big.Float).SetInt(n).Float64()
+
+		tokens = append(tokens, token(Number, string(runes[start:current]), strconv.FormatFloat(value, 'f', -1, 64)))
+
+		return nil
 	}
 
 	scanToken := func() error {
@@ -105,13 +397,14 @@ func (s *Scanner) Scan() ([]Token, error) {
 
 		case '\n':
 			{
-				line++
+				newline()
 				break
 			}
 
-		// Single-character lexeme: '(', ')', '[', ']', '.', '-', '+', ';'
+		// Single-character lexeme: '(', ')', '{', '}', '[', ']', '.', '%', ',', ';', '?', ':'
 		case '(':
 			{
+				parens = append(parens, opensHeader(tokens, header))
 				addToken(LeftParenthesis)
 				break
 			}
@@ -119,11 +412,21 @@ func (s *Scanner) Scan() ([]Token, error) {
 		case ')':
 			{
 				addToken(RightParenthesis)
+
+				if n := len(parens); n > 0 {
+					if parens[n-1] {
+						header = len(tokens) - 1
+					}
+
+					parens = parens[:n-1]
+				}
+
 				break
 			}
 
 		case '{':
 			{
+				braces = append(braces, opensClass(tokens))
 				addToken(LeftSquare)
 				break
 			}
@@ -131,30 +434,85 @@ func (s *Scanner) Scan() ([]Token, error) {
 		case '}':
 			{
 				addToken(RightSquare)
+
+				if n := len(braces); n > 0 {
+					braces = braces[:n-1]
+				}
+
+				break
+			}
+
+		case '[':
+			{
+				addToken(LeftBracket)
+				break
+			}
+
+		case ']':
+			{
+				addToken(RightBracket)
 				break
 			}
 
 		case '.':
 			{
-				addToken(Dot)
+				if peek() == '.' && peekNext() == '.' {
+					advance()
+					advance()
+					addToken(Ellipsis)
+				} else {
+					addToken(Dot)
+				}
 				break
 			}
 
 		case '-':
 			{
-				addToken(Minus)
+				if isNext('=') {
+					addToken(MinusEqual)
+				} else {
+					addToken(Minus)
+				}
+
 				break
 			}
 
 		case '+':
 			{
-				addToken(Plus)
+				if isNext('=') {
+					addToken(PlusEqual)
+				} else {
+					addToken(Plus)
+				}
+
 				break
 			}
 
 		case '*':
 			{
-				addToken(Star)
+				if isNext('*') {
+					addToken(StarStar)
+				} else if isNext('=') {
+					addToken(StarEqual)
+				} else {
+					addToken(Star)
+				}
+
+				break
+			}
+
+		case '#':
+			{
+				if err := directive(); err != nil {
+					return err
+				}
+
+				break
+			}
+
+		case '%':
+			{
+				addToken(Percent)
 				break
 			}
 
@@ -170,7 +528,27 @@ func (s *Scanner) Scan() ([]Token, error) {
 				break
 			}
 
-		// Multi-character lexeme (potentially): '/', '!', '=', '<', '>', '!=', '==', '<=', '>=', '//'
+		case '?':
+			{
+				if isNext('?') {
+					addToken(QuestionQuestion)
+				} else if peek() == '.' && !isDigit(peekNext()) {
+					advance()
+					addToken(QuestionDot)
+				} else {
+					addToken(Question)
+				}
+
+				break
+			}
+
+		case ':':
+			{
+				addToken(Colon)
+				break
+			}
+
+		// Multi-character lexeme (potentially): '-', '+', '*', '/', '!', '=', '<', '>', '-=', '+=', '*=', '**', '/=', '!=', '==', '<=', '>=', '//', '/*'
 		case '!':
 			{
 				if isNext('=') {
@@ -215,10 +593,50 @@ func (s *Scanner) Scan() ([]Token, error) {
 
 		case '/':
 			{
+				// '//' between two operands on the same line, like in
+				// 7 // 2 or f(x) // 2, is floor division, anywhere else it
+				// starts a comment: at the start or the end of a line,
+				// after a ';', a brace, a comma, an operator or the header
+				// of a statement or a function, like in if (x) // comment
 				if isNext('/') {
-					for peek() != '\n' && !isEnd() {
-						advance()
+					if endsOperand(tokens, startLine+delta, header) && !endsLine(runes[current:]) {
+						addToken(SlashSlash)
+					} else {
+						for peek() != '\n' && !isEnd() {
+							advance()
+						}
+
+						if comments {
+							addToken(Comment)
+						}
 					}
+				} else if isNext('*') {
+					// block comments can be nested
+					depth := 1
+
+					for depth > 0 {
+						if isEnd() {
+							return scanError(startLine, startColumn, "unterminated comment")
+						}
+
+						if peek() == '/' && peekNext() == '*' {
+							advance()
+							depth++
+						} else if peek() == '*' && peekNext() == '/' {
+							advance()
+							depth--
+						}
+
+						if advance() == '\n' {
+							newline()
+						}
+					}
+
+					if comments {
+						addToken(Comment)
+					}
+				} else if isNext('=') {
+					addToken(SlashEqual)
 				} else {
 					addToken(Slash)
 				}
@@ -226,56 +644,76 @@ func (s *Scanner) Scan() ([]Token, error) {
 
 		case '"':
 			{
+				var literal []rune
+				var invalid error // the string is scanned to its end anyway
+
 				for peek() != '"' && !isEnd() {
-					if peek() == '\n' {
-						line++
+					r := advance()
+
+					if r == '\n' {
+						newline()
 					}
 
-					advance()
+					if r == '\\' && !isEnd() {
+						e, ok := escapes[advance()]
+						if !ok && invalid == nil {
+							invalid = scanError(line, column()-2, "invalid escape sequence '\\%s'", string(runes[current-1]))
+						}
+
+						r = e
+					}
+
+					literal = append(literal, r)
 				}
 
-				// unterminated string
+				// reported first, the string is still a token so that
+				// the tokens after it make sense when recovering
+				if invalid != nil && isEnd() {
+					return invalid
+				}
+
+				// reported at the opening quote, the end of the input
+				// says nothing about where the quote is missing
 				if isEnd() {
-					return fmt.Errorf("error at line %d: unterminated string", line)
+					return scanError(startLine, startColumn, "unterminated string")
 				}
 
 				advance()
 
 				lexeme := string(runes[start:current])
-				literal := string(runes[start+1 : current-1]) // remove double quotes
 
-				tokens = append(tokens, Token{String, lexeme, literal, line})
+				tokens = append(tokens, token(String, lexeme, string(literal)))
+
+				if invalid != nil {
+					return invalid
+				}
 			}
 
 		default:
 			{
 				if isDigit(r) {
-					for isDigit(peek()) {
-						advance()
+					if err := number(r); err != nil {
+						return err
 					}
-
-					if peek() == '.' && isDigit(peekNext()) {
-						advance()
-
-						for isDigit(peek()) {
-							advance()
-						}
-					}
-
-					number := string(runes[start:current])
-					tokens = append(tokens, Token{Number, number, number, line})
-				} else if isLetter(r) {
-					for isLetter(peek()) || isDigit(peek()) {
+				} else if isLetter(r) || r == '_' {
+					for isLetter(peek()) || unicode.IsDigit(peek()) || peek() == '_' {
 						advance()
 					}
 
 					if t, ok := keywords[string(runes[start:current])]; ok {
-						tokens = append(tokens, Token{t, string(runes[start:current]), "", line})
+						addToken(t)
 					} else {
-						tokens = append(tokens, Token{Identifier, string(runes[start:current]), "", line})
+						// the name of a method, which may be a getter
+						member := len(braces) > 0 && braces[len(braces)-1] && startsMember(tokens)
+
+						addToken(Identifier)
+
+						if member {
+							header = len(tokens) - 1
+						}
 					}
 				} else {
-					return fmt.Errorf("unknown character '%v' at line %d", string(r), line)
+					return scanError(startLine, startColumn, "unknown character '%v'", string(r))
 				}
 			}
 		}
@@ -285,13 +723,124 @@ func (s *Scanner) Scan() ([]Token, error) {
 
 	for !isEnd() {
 		start = current
+		startLine = line
+		startColumn = column()
 		if err := scanToken(); err != nil {
-			return nil, err
+			// scanError takes the lines of the text, map them too
+			if e, ok := err.(*ScanError); ok {
+				e.Line += delta
+				e.File = file
+			}
+
+			if recovered == nil {
+				return nil, err
+			}
+
+			*recovered = append(*recovered, err)
 		}
 	}
 
 	// cannot use addToken because lexeme will get the last character
-	tokens = append(tokens, Token{Eof, "", "", line})
+	tokens = append(tokens, Token{Eof, "", "", line + delta, column(), file})
 
 	return tokens, nil
 }
+
+// significant returns the tokens without the comments ending them.
+func significant(tokens []Token) []Token {
+	for len(tokens) > 0 && tokens[len(tokens)-1].TokenType == Comment {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	return tokens
+}
+
+// endsOperand reports whether the last token ends an operand on line, so
+// that a binary operator can follow it. The token at index header ends the
+// header of a statement or a function instead.
+func endsOperand(tokens []Token, line int, header int) bool {
+	tokens = significant(tokens)
+
+	if len(tokens) == 0 || tokens[len(tokens)-1].Line != line || len(tokens)-1 == header {
+		return false
+	}
+
+	switch tokens[len(tokens)-1].TokenType {
+	case False, Identifier, Nil, Number, RightBracket, RightParenthesis, String, This, True:
+		return true
+	}
+
+	return false
+}
+
+// endsLine reports whether only blanks are left on the line of runes.
+func endsLine(runes []rune) bool {
+	for _, r := range runes {
+		switch r {
+		case ' ', '\t', '\r':
+		case '\n':
+			return true
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// opensHeader reports whether a '(' after tokens opens the condition of a
+// statement or the parameters of a function. The token at index header is
+// the name of a method.
+func opensHeader(tokens []Token, header int) bool {
+	tokens = significant(tokens)
+
+	n := len(tokens)
+	if n == 0 {
+		return false
+	}
+
+	switch tokens[n-1].TokenType {
+	case Catch, For, Fun, If, Switch, While:
+		return true
+	case Identifier:
+		// a function declaration or a static method
+		if n > 1 && (tokens[n-2].TokenType == Fun || tokens[n-2].TokenType == Class) {
+			return true
+		}
+
+		return n-1 == header
+	}
+
+	return false
+}
+
+// opensClass reports whether a '{' after tokens opens the body of a class:
+// class Name { or class Name < Superclass {.
+func opensClass(tokens []Token) bool {
+	tokens = significant(tokens)
+
+	n := len(tokens)
+	if n >= 2 && tokens[n-1].TokenType == Identifier && tokens[n-2].TokenType == Class {
+		return true
+	}
+
+	return n >= 4 && tokens[n-1].TokenType == Identifier && tokens[n-2].TokenType == Less &&
+		tokens[n-3].TokenType == Identifier && tokens[n-4].TokenType == Class
+}
+
+// startsMember reports whether a token after tokens, in the body of a
+// class, starts a member.
+func startsMember(tokens []Token) bool {
+	tokens = significant(tokens)
+
+	if len(tokens) == 0 {
+		return true
+	}
+
+	switch tokens[len(tokens)-1].TokenType {
+	case LeftSquare, RightSquare, Semicolon:
+		return true
+	}
+
+	return false
+}
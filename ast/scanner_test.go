@@ -22,24 +22,59 @@
 
 package ast
 
-import "testing"
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestScanner_Scan(t *testing.T) {
 	table := []struct {
 		in  string
 		out []TokenType
 	}{
-		{"(){}", []TokenType{LeftParenthesis, RightParenthesis, LeftSquare, RightSquare, Eof}},
-		{"+ - * / , ; ! > <", []TokenType{Plus, Minus, Star, Slash, Comma, Semicolon, Not, Greater, Less, Eof}},
+		{"(){}[]", []TokenType{LeftParenthesis, RightParenthesis, LeftSquare, RightSquare, LeftBracket, RightBracket, Eof}},
+		{"+ - * / % , ; ! > <", []TokenType{Plus, Minus, Star, Slash, Percent, Comma, Semicolon, Not, Greater, Less, Eof}},
 		{"== != >= <=", []TokenType{EqualEqual, NotEqual, GreaterEqual, LessEqual, Eof}},
+		{"+= -= *= /= **", []TokenType{PlusEqual, MinusEqual, StarEqual, SlashEqual, StarStar, Eof}},
+		{"? :", []TokenType{Question, Colon, Eof}},
+		{"... . ..", []TokenType{Ellipsis, Dot, Dot, Dot, Eof}},
 		{"// This text have to be ignored", []TokenType{Eof}},
+		{"/* This text have to be ignored */", []TokenType{Eof}},
+		{"1 /* a */ + /* b */ 2", []TokenType{Number, Plus, Number, Eof}},
+		{"/* a /* b */ c */ x", []TokenType{Identifier, Eof}},
+		{"/**/ /***/ x", []TokenType{Identifier, Eof}},
 		{"\"This is a string!\"", []TokenType{String, Eof}},
 		{"1 12 12.3", []TokenType{Number, Number, Number, Eof}},
 		{"and or true false", []TokenType{And, Or, True, False, Eof}},
+		{"break continue", []TokenType{Break, Continue, Eof}},
 		{"if else for while", []TokenType{If, Else, For, While, Eof}},
+		{"switch case default", []TokenType{Switch, Case, Default, Eof}},
 		{"fun return", []TokenType{Fun, Return, Eof}},
-		{"class var nil", []TokenType{Class, Var, Nil, Eof}},
+		{"class var const nil", []TokenType{Class, Var, Const, Nil, Eof}},
 		{"print x", []TokenType{Print, Identifier, Eof}},
+		{"import is try catch throw", []TokenType{Import, Is, Try, Catch, Throw, Eof}},
+		{"7 // 2", []TokenType{Number, SlashSlash, Number, Eof}},
+		{"x//2", []TokenType{Identifier, SlashSlash, Number, Eof}},
+		{"f(x) // 2 + a[1] // 2", []TokenType{Identifier, LeftParenthesis, Identifier, RightParenthesis, SlashSlash, Number, Plus, Identifier, LeftBracket, Number, RightBracket, SlashSlash, Number, Eof}},
+		{"x; // 2", []TokenType{Identifier, Semicolon, Eof}},
+		{"x = // 2", []TokenType{Identifier, Equal, Eof}},
+		{"f(x, // 2\ny)", []TokenType{Identifier, LeftParenthesis, Identifier, Comma, Identifier, RightParenthesis, Eof}},
+		{"x\n// 2", []TokenType{Identifier, Eof}},
+		{"x //\n2", []TokenType{Identifier, Number, Eof}},
+		{"if (x) // y", []TokenType{If, LeftParenthesis, Identifier, RightParenthesis, Eof}},
+		{"while ((x)) // y", []TokenType{While, LeftParenthesis, LeftParenthesis, Identifier, RightParenthesis, RightParenthesis, Eof}},
+		{"fun f(a) // y", []TokenType{Fun, Identifier, LeftParenthesis, Identifier, RightParenthesis, Eof}},
+		{"fun (a) // y", []TokenType{Fun, LeftParenthesis, Identifier, RightParenthesis, Eof}},
+		{"class A { m() // y", []TokenType{Class, Identifier, LeftSquare, Identifier, LeftParenthesis, RightParenthesis, Eof}},
+		{"class A < B { m // y", []TokenType{Class, Identifier, Less, Identifier, LeftSquare, Identifier, Eof}},
+		{"class A { m() { f() // 2", []TokenType{Class, Identifier, LeftSquare, Identifier, LeftParenthesis, RightParenthesis, LeftSquare, Identifier, LeftParenthesis, RightParenthesis, SlashSlash, Number, Eof}},
+		{"if (f(x) // 2) // y", []TokenType{If, LeftParenthesis, Identifier, LeftParenthesis, Identifier, RightParenthesis, SlashSlash, Number, RightParenthesis, Eof}},
+		{"__add__ _x x_1", []TokenType{Identifier, Identifier, Identifier, Eof}},
+		{"a?.b ? .5 : c ?.5 : d", []TokenType{Identifier, QuestionDot, Identifier, Question, Dot, Number, Colon, Identifier, Question, Dot, Number, Colon, Identifier, Eof}},
+		{"a ?? b ? c : d", []TokenType{Identifier, QuestionQuestion, Identifier, Question, Identifier, Colon, Identifier, Eof}},
 	}
 
 	for _, test := range table {
@@ -59,3 +94,443 @@ func TestScanner_Scan(t *testing.T) {
 		})
 	}
 }
+
+func TestScanner_BlockCommentLines(t *testing.T) {
+	scanner := Scanner{"/* one\ntwo\n/* three\n*/ four */\nx"}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if line := tokens[0].Line; line != 5 {
+		t.Errorf("want x at line 5, got %d", line)
+	}
+}
+
+func TestScanner_ScanComments(t *testing.T) {
+	scanner := Scanner{"// first\nvar x = 7 // 2; /* inner */\n/* two\nlines */ x; // last"}
+	tokens, err := scanner.ScanComments()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var comments []Token
+	for _, token := range tokens {
+		if token.TokenType == Comment {
+			comments = append(comments, token)
+		}
+	}
+
+	// the '//' after an operand is still an operator
+	want := []Token{
+		{Comment, "// first", "", 1, 1, ""},
+		{Comment, "/* inner */", "", 2, 17, ""},
+		{Comment, "/* two\nlines */", "", 3, 1, ""},
+		{Comment, "// last", "", 4, 13, ""},
+	}
+
+	if !reflect.DeepEqual(comments, want) {
+		t.Errorf("want %v, got %v", want, comments)
+	}
+
+	// without the option comments are dropped
+	plain, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(plain) != len(tokens)-len(want) {
+		t.Errorf("want %d tokens, got %d", len(tokens)-len(want), len(plain))
+	}
+}
+
+func TestScanner_UnterminatedBlockComment(t *testing.T) {
+	table := []struct {
+		in     string
+		line   int
+		column int
+	}{
+		{"/* never closed", 1, 1},
+		{"x\n  /* a\n/* b */\n", 2, 3},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			_, err := scanner.Scan()
+
+			want := fmt.Sprintf("error at line %d, col %d: unterminated comment", test.line, test.column)
+			if err == nil || err.Error() != want {
+				t.Errorf("want %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestScanner_UnterminatedString(t *testing.T) {
+	table := []struct {
+		in     string
+		line   int
+		column int
+	}{
+		{"print \"never closed", 1, 7},
+		{"var s = \"a;\nprint s;\n", 1, 9},
+		{"x;\n  \"one\ntwo", 2, 3},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			_, err := scanner.Scan()
+
+			want := fmt.Sprintf("error at line %d, col %d: unterminated string", test.line, test.column)
+			if err == nil || err.Error() != want {
+				t.Errorf("want %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestScanner_StringEscapes(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\nb"`, "a\nb"},
+		{`"a\tb\rc"`, "a\tb\rc"},
+		{`"back\\slash"`, "back\\slash"},
+		{`"say \"hi\""`, "say \"hi\""},
+		{`"nul\0"`, "nul\x00"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			tokens, err := scanner.Scan()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tokens[0].Literal != test.out {
+				t.Errorf("want %q, got %q", test.out, tokens[0].Literal)
+			}
+
+			if tokens[0].Lexeme != test.in {
+				t.Errorf("want lexeme %q, got %q", test.in, tokens[0].Lexeme)
+			}
+		})
+	}
+}
+
+func TestScanner_InvalidEscape(t *testing.T) {
+	scanner := Scanner{`"bad \q escape"`}
+	_, err := scanner.Scan()
+
+	want := `error at line 1, col 6: invalid escape sequence '\q'`
+	if err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestScanner_Columns(t *testing.T) {
+	scanner := Scanner{"var  x =\t1;\n  print \"a b\" + x;"}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][2]int{
+		{1, 1}, {1, 6}, {1, 8}, {1, 10}, {1, 11},
+		{2, 3}, {2, 9}, {2, 15}, {2, 17}, {2, 18}, {2, 19},
+	}
+
+	if len(tokens) != len(want) {
+		t.Fatalf("want %d tokens, got %d: %v", len(want), len(tokens), tokens)
+	}
+
+	for i, token := range tokens {
+		if token.Line != want[i][0] || token.Column != want[i][1] {
+			t.Errorf("want %v at %d:%d, got %d:%d", token.TokenType, want[i][0], want[i][1], token.Line, token.Column)
+		}
+	}
+}
+
+func TestScanner_UnicodeIdentifiers(t *testing.T) {
+	table := []struct {
+		in  string
+		out []Token
+	}{
+		{"var café = 1;", []Token{
+			{Var, "var", "", 1, 1, ""},
+			{Identifier, "café", "", 1, 5, ""},
+			{Equal, "=", "", 1, 10, ""},
+			{Number, "1", "1", 1, 12, ""},
+			{Semicolon, ";", "", 1, 13, ""},
+			{Eof, "", "", 1, 14, ""},
+		}},
+		{"число_2 + 数", []Token{
+			{Identifier, "число_2", "", 1, 1, ""},
+			{Plus, "+", "", 1, 9, ""},
+			{Identifier, "数", "", 1, 11, ""},
+			{Eof, "", "", 1, 12, ""},
+		}},
+		// keywords match exactly
+		{"printé print fün", []Token{
+			{Identifier, "printé", "", 1, 1, ""},
+			{Print, "print", "", 1, 8, ""},
+			{Identifier, "fün", "", 1, 14, ""},
+			{Eof, "", "", 1, 17, ""},
+		}},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			tokens, err := scanner.Scan()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(tokens, test.out) {
+				t.Errorf("want %v, got %v", test.out, tokens)
+			}
+		})
+	}
+}
+
+func TestScanner_ErrorColumn(t *testing.T) {
+	scanner := Scanner{"var x = 1;\nvar y = @;"}
+	_, err := scanner.Scan()
+
+	want := "error at line 2, col 9: unknown character '@'"
+	if err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestScanner_Numbers(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+	}{
+		{"42", "42"},
+		{"12.5", "12.5"},
+		{"1_000_000", "1000000"},
+		{"3.141_592", "3.141592"},
+		{"0xFF", "255"},
+		{"0xdead_beef", "3735928559"},
+		{"0X10", "16"},
+		{"0b1010", "10"},
+		{"0b1111_0000", "240"},
+		{"0", "0"},
+		{"0x1_0000_0000_0000_0000", "18446744073709552000"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			tokens, err := scanner.Scan()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(tokens) != 2 || tokens[0].TokenType != Number {
+				t.Fatalf("want a single number, got %v", tokens)
+			}
+
+			if tokens[0].Literal != test.out {
+				t.Errorf("want %q, got %q", test.out, tokens[0].Literal)
+			}
+
+			if tokens[0].Lexeme != test.in {
+				t.Errorf("want lexeme %q, got %q", test.in, tokens[0].Lexeme)
+			}
+		})
+	}
+}
+
+func TestScanner_InvalidNumbers(t *testing.T) {
+	table := []struct {
+		in  string
+		err string
+	}{
+		{"1_", "error at line 1, col 2: invalid '_' in number literal"},
+		{"1__000", "error at line 1, col 2: invalid '_' in number literal"},
+		{"1_.5", "error at line 1, col 2: invalid '_' in number literal"},
+		{"1._5", "error at line 1, col 3: invalid '_' in number literal"},
+		{"1.5_", "error at line 1, col 4: invalid '_' in number literal"},
+		{"0x_FF", "error at line 1, col 3: missing digits after '0x'"},
+		{"0x", "error at line 1, col 3: missing digits after '0x'"},
+		{"0b102", "error at line 1, col 5: invalid digit '2' in number literal"},
+		{"0xFG", "error at line 1, col 4: invalid digit 'G' in number literal"},
+		{"0b1_", "error at line 1, col 4: invalid '_' in number literal"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			if _, err := scanner.Scan(); err == nil || err.Error() != test.err {
+				t.Errorf("want %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestScanner_ScanLines(t *testing.T) {
+	table := []struct {
+		in  string
+		out string // lexemes, inserted semicolons as ⏎
+	}{
+		{"var x = 1\nprint x", "var x = 1 ⏎ print x ⏎"},
+		{"x;\ny;", "x ; y ;"},
+		{"a +\nb\n", "a + b ⏎"},
+		{"f(a,\nb)\n", "f ( a , b ) ⏎"},
+		{"f(\na\n)", "f ( a ) ⏎"},
+		{"[1,\n2\n]", "[ 1 , 2 ] ⏎"},
+		{"break\ncontinue\nthis\nnil\ntrue\nfalse", "break ⏎ continue ⏎ this ⏎ nil ⏎ true ⏎ false ⏎"},
+		{"return\nx", "return ⏎ x ⏎"},
+		{"if (x)\nprint x", "if ( x ) ⏎ print x ⏎"},
+		{"x; // comment\ny /* a\nb */ z", "x ; y ⏎ z ⏎"},
+		{"x // y\nz", "x // y ⏎ z ⏎"},
+		{"\"a\nb\" + c", "\"a\nb\" + c ⏎"},
+		{"map(xs, fun (x) {\nreturn x\n})", "map ( xs , fun ( x ) { return x ⏎ } ) ⏎"},
+		{"if (x)\nreturn\ny", "if ( x ) ⏎ return ⏎ y ⏎"},
+		{"{\n}\n", "{ } ⏎"},
+		{"a =\n-1", "a = - 1 ⏎"},
+		{"print", "print"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			tokens, err := scanner.ScanLines()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var lexemes []string
+			for _, token := range tokens[:len(tokens)-1] {
+				if token.Literal == insertedSemicolon {
+					lexemes = append(lexemes, "⏎")
+				} else {
+					lexemes = append(lexemes, token.Lexeme)
+				}
+			}
+
+			if got := strings.Join(lexemes, " "); got != test.out {
+				t.Errorf("want %q, got %q", test.out, got)
+			}
+		})
+	}
+}
+
+func TestScanner_ScanLinesPositions(t *testing.T) {
+	scanner := Scanner{"var s = \"a\nbc\"\nprint s"}
+	tokens, err := scanner.ScanLines()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var positions []string
+	for _, token := range tokens {
+		if token.Literal == insertedSemicolon {
+			positions = append(positions, fmt.Sprintf("%d:%d", token.Line, token.Column))
+		}
+	}
+
+	want := []string{"2:4", "3:8"}
+	if !reflect.DeepEqual(positions, want) {
+		t.Errorf("want %v, got %v", want, positions)
+	}
+}
+
+func TestScanner_ScanLinesRun(t *testing.T) {
+	table := []struct {
+		in  string
+		out string
+		err string
+	}{
+		{"var x = 1\nprint x + 1", "2\n", ""},
+		{"fun add(a, b) {\n  return a +\n    b\n}\nprint add(1,\n  2)", "3\n", ""},
+		{"fun f()\n{\n  return 1\n}\nprint f()", "1\n", ""},
+		{"class A {\n  init() {\n    this.x = 1\n  }\n}\nprint A().x", "1\n", ""},
+		{"for (var i = 0; i < 2; i = i + 1)\n  print i", "0\n1\n", ""},
+		{"var n = 0\nwhile (n < 2)\n{\n  n = n + 1\n}\nprint n", "2\n", ""},
+		{"if (true) {\n  print 1\n} else {\n  print 2\n}", "1\n", ""},
+		{"var f = fun (x) {\n  return x * 2\n}\nprint f(2)", "4\n", ""},
+		{"var m = {\"a\": 1}\nprint m[\"a\"]", "1\n", ""},
+		{"do {\n  print 1\n} while (false)", "1\n", ""},
+		{"switch (1) {\n  case 1:\n    print 1\n  default:\n    print 2\n}", "1\n", ""},
+		{"try {\n  throw 1\n} catch (e) {\n  print e\n}", "1\n", ""},
+		{"print 1; print 2\nprint 3", "1\n2\n3\n", ""},
+		{"fun f() {\n  return\n    1\n}\nprint f()", "nil\n", ""},
+		{"fun f(x) {\n  if (x > 0)\n    return\n  print \"neg\"\n}\nf(-1)\nf(1)", "neg\n", ""},
+		{"if (true) { print 1 }", "1\n", ""},
+		{"class A {\n  m() { return 2 }\n}\nprint A().m()", "2\n", ""},
+		{"fun f() { return }\nprint f()", "nil\n", ""},
+		{"fun f() { var x = 1; x = x + 1; print x }\nf()", "2\n", ""},
+		// the expression ends at the line break, before the operator
+		{"var x = 1\n  + 2\nprint x", "", "error at line 2, col 3: unknown token '+'"},
+		{"if (true) {\n  print 1\n}\nelse {\n  print 2\n}", "", "error at line 4, col 1: unknown token 'else'"},
+		{"var m = {\n  \"a\": 1\n}", "", "error at line 2, col 9: expected 'RIGHT_SQUARE'\nerror at line 3, col 1: unknown token '}'"},
+	}
+
+	for _, test := range table {
+		t.Run(test.in, func(t *testing.T) {
+			scanner := Scanner{test.in}
+			tokens, err := scanner.ScanLines()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var b bytes.Buffer
+			parser := Parser{Tokens: tokens, Lines: true}
+			stmts, err := parser.Parse()
+			if err == nil {
+				i := NewInterpreter()
+				i.SetOutput(&b)
+				err = i.Run(stmts)
+			}
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("want %q, got %v", test.err, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			} else if b.String() != test.out {
+				t.Errorf("want %q, got %q", test.out, b.String())
+			}
+		})
+	}
+}
+
+func TestScanner_LineDirective(t *testing.T) {
+	scanner := Scanner{"a\n#line 10 \"gen.dsl\"\nb\n\n  c\n#line 2\nd \"e\nf\" g"}
+	tokens, err := scanner.Scan()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var positions []string
+	for _, token := range tokens {
+		positions = append(positions, fmt.Sprintf("%s %s:%d:%d", token.Lexeme, token.File, token.Line, token.Column))
+	}
+
+	want := []string{"a :1:1", "b gen.dsl:10:1", "c gen.dsl:12:3", "d gen.dsl:2:1", "\"e\nf\" gen.dsl:2:3", "g gen.dsl:3:4", " gen.dsl:3:5"}
+	if !reflect.DeepEqual(positions, want) {
+		t.Errorf("want %q, got %q", want, positions)
+	}
+
+	// the formatter keeps the directives as comments
+	scanner = Scanner{"#line 10 \"gen.dsl\"\nx"}
+	tokens, err = scanner.ScanComments()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if tokens[0].TokenType != Comment || tokens[0].Lexeme != "#line 10 \"gen.dsl\"" {
+		t.Errorf("want a comment, got %v", tokens[0])
+	}
+}
@@ -0,0 +1,61 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// Snapshot is the state of the globals of an interpreter at some point,
+// which Restore goes back to.
+type Snapshot struct {
+	scope map[string]interface{}
+}
+
+// Snapshot captures the globals, copying their bindings but not the
+// values they hold: a list changed in place after the snapshot stays
+// changed once restored.
+func (i *Interpreter) Snapshot() *Snapshot {
+	globals := i.globals()
+
+	globals.mu.RLock()
+	defer globals.mu.RUnlock()
+
+	scope := make(map[string]interface{}, len(globals.Scope))
+	for name, v := range globals.Scope {
+		scope[name] = v
+	}
+
+	return &Snapshot{scope}
+}
+
+// Restore brings the globals back to a snapshot taken by the interpreter,
+// globals defined after it are removed. The functions defined before keep
+// seeing the globals, they are restored in place.
+func (i *Interpreter) Restore(s *Snapshot) {
+	globals := i.globals()
+
+	globals.mu.Lock()
+	defer globals.mu.Unlock()
+
+	globals.Scope = make(map[string]interface{}, len(s.scope))
+	for name, v := range s.scope {
+		globals.Scope[name] = v
+	}
+}
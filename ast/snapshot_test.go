@@ -0,0 +1,89 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInterpreter_Snapshot(t *testing.T) {
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+
+	if err := exec(i, "var x = 1; fun get() { return x; }"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := i.Snapshot()
+
+	if err := exec(i, "var x = 2; var y = 3; fun get() { return -x; }"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// restoring twice gives the same globals
+	for run := 0; run < 2; run++ {
+		i.Restore(s)
+
+		if got := global(i, "x"); got != 1.0 {
+			t.Errorf("want x = 1, got %v", got)
+		}
+
+		if err := exec(i, "print get(); x = 5; print get();"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if want := "1\n5\n1\n5\n"; b.String() != want {
+		t.Errorf("want %q, got %q", want, b.String())
+	}
+
+	want := "error at line 1, col 7: undefined variable y"
+	if err := exec(i, "print y;"); err == nil || err.Error() != want {
+		t.Errorf("want %q, got %v", want, err)
+	}
+}
+
+func TestInterpreter_SnapshotValues(t *testing.T) {
+	i := NewInterpreter()
+	i.SetOutput(&bytes.Buffer{})
+
+	if err := exec(i, "var xs = [1];"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := i.Snapshot()
+
+	if err := exec(i, "push(xs, 2);"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the binding is restored, the list it holds is the same
+	i.Restore(s)
+
+	if got := global(i, "xs").(*ListValue).String(); got != "[1, 2]" {
+		t.Errorf("want [1, 2], got %v", got)
+	}
+}
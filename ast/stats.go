@@ -0,0 +1,69 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+// Stats counts the work done by the tree-walking interpreter, it is
+// collected only if StatsMode is set and programs run on the VM are not
+// counted.
+type Stats struct {
+	Evaluations int // of expressions, nested ones included
+	Calls       int // of functions, classes and natives
+	Lookups     int // of variables in the environment, reads and writes
+	Allocations int // of lists, maps, records, instances and closures
+}
+
+// Stats returns the counts of all the runs so far, zero unless StatsMode
+// is set.
+func (i *Interpreter) Stats() Stats {
+	if i.stats == nil {
+		return Stats{}
+	}
+
+	return *i.stats
+}
+
+// the counters are nil safe, so they cost a check when disabled
+
+func (s *Stats) evaluation() {
+	if s != nil {
+		s.Evaluations++
+	}
+}
+
+func (s *Stats) call() {
+	if s != nil {
+		s.Calls++
+	}
+}
+
+func (s *Stats) lookup() {
+	if s != nil {
+		s.Lookups++
+	}
+}
+
+func (s *Stats) allocation() {
+	if s != nil {
+		s.Allocations++
+	}
+}
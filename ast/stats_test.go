@@ -0,0 +1,102 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestInterpreter_Stats(t *testing.T) {
+	source := `fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+var pair = [fib(10), fun () { return clock; }];
+print len(pair);`
+
+	i := &Interpreter{StatsMode: true}
+	i.Install(StdLib...)
+	i.SetOutput(&bytes.Buffer{})
+	if err := exec(i, source); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats := i.Stats()
+
+	// fib(10) makes 177 calls, plus the one to len
+	if stats.Calls != 178 {
+		t.Errorf("want 178 calls, got %d", stats.Calls)
+	}
+
+	// fib, the list and the lambda
+	if stats.Allocations != 3 {
+		t.Errorf("want 3 allocations, got %d", stats.Allocations)
+	}
+
+	if stats.Evaluations == 0 || stats.Lookups == 0 {
+		t.Errorf("want evaluations and lookups counted, got %+v", stats)
+	}
+
+	// counts add up across runs
+	if err := exec(i, "fib(1);"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := i.Stats().Calls; got != 179 {
+		t.Errorf("want 179 calls, got %d", got)
+	}
+}
+
+func TestInterpreter_StatsDisabled(t *testing.T) {
+	i, err := run("fun f() {} f();")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := i.Stats(); got != (Stats{}) {
+		t.Errorf("want no stats, got %+v", got)
+	}
+}
+
+func BenchmarkInterpreter_FibStats(b *testing.B) {
+	program := `fun fib(n) {
+		if (n < 2) return n;
+		return fib(n - 1) + fib(n - 2);
+	}
+	fib(15);`
+
+	stmts, err := parse(program)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		i := &Interpreter{StatsMode: true}
+		if err := i.Run(stmts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
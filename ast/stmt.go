@@ -28,14 +28,26 @@ type Stmt interface {
 
 type StmtVisitor interface {
 	visitBlock(Block) error
+	visitBreakStmt(BreakStmt) error
 	visitClassStmt(ClassStmt) error
+	visitContinueStmt(ContinueStmt) error
 	visitDeclaration(Declaration) error
+	visitDeferStmt(DeferStmt) error
+	visitDestructuring(Destructuring) error
+	visitDoWhile(DoWhile) error
+	visitEnumStmt(EnumStmt) error
+	visitForIn(ForIn) error
 	visitForStmt(ForStmt) error
 	visitFunction(Function) error
 	visitIfStmt(IfStmt) error
+	visitImportStmt(ImportStmt) error
+	visitLabeled(Labeled) error
 	visitExprStmt(ExprStmt) error
 	visitPrintStmt(PrintStmt) error
 	visitReturnStmt(ReturnStmt) error
+	visitSwitchStmt(SwitchStmt) error
+	visitThrowStmt(ThrowStmt) error
+	visitTryStmt(TryStmt) error
 	visitWhileStmt(WhileStmt) error
 }
 
@@ -47,29 +59,144 @@ func (b Block) Accept(visitor StmtVisitor) error {
 	return visitor.visitBlock(b)
 }
 
+type BreakStmt struct {
+	Token
+	Label Token // of the loop to break, the innermost one if empty
+}
+
+func (b BreakStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitBreakStmt(b)
+}
+
 type ClassStmt struct {
-	Name    Token
-	Methods []Function
+	Name       Token
+	Superclass Expr // nil unless the class inherits from another one
+	Methods    []Function
+	Statics    []Function    // methods of the class itself
+	Fields     []Declaration // set on every new instance
+	Slot       *Slot         // of the name
 }
 
 func (c ClassStmt) Accept(visitor StmtVisitor) error {
 	return visitor.visitClassStmt(c)
 }
 
-func (c ClassStmt) CreateInstance() Literal {
-	return Literal{ClassInstance{c, make(map[string]Literal)}}
+// Commented attaches to a statement the comments preceding it and the
+// ones following it on its last line, it stands for the statement when
+// visited. Without a statement it holds the comments closing a block or a
+// file. The parser only produces it from tokens scanned with comments.
+type Commented struct {
+	Stmt
+	Leading  []Token
+	Trailing []Token
+}
+
+func (c Commented) Accept(visitor StmtVisitor) error {
+	if c.Stmt == nil {
+		return nil
+	}
+
+	return c.Stmt.Accept(visitor)
+}
+
+// commentsOnly reports whether s holds comments but no statement.
+func commentsOnly(s Stmt) bool {
+	c, ok := s.(Commented)
+	return ok && c.Stmt == nil
+}
+
+// uncommented returns the statement s stands for, without its comments.
+func uncommented(s Stmt) Stmt {
+	if c, ok := s.(Commented); ok {
+		return c.Stmt
+	}
+
+	return s
+}
+
+type ContinueStmt struct {
+	Token
+	Label Token // of the loop to continue, the innermost one if empty
+}
+
+func (c ContinueStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitContinueStmt(c)
 }
 
 type Declaration struct {
 	Token
 	Expr
+	Const bool // const bindings cannot be reassigned
+	Slot  *Slot
 }
 
 func (d Declaration) Accept(visitor StmtVisitor) error {
 	return visitor.visitDeclaration(d)
 }
 
+// DeferStmt defers Deferred until the enclosing function exits, however
+// it exits. Deferred statements run in the scope they were deferred in,
+// latest first.
+type DeferStmt struct {
+	Token
+	Deferred Stmt
+}
+
+func (d DeferStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitDeferStmt(d)
+}
+
+// Destructuring declares Names bound to the elements of the list Expr
+// evaluates to, which must have as many.
+type Destructuring struct {
+	Token
+	Names []Token
+	Expr
+	Const bool
+	Slots []*Slot
+}
+
+func (d Destructuring) Accept(visitor StmtVisitor) error {
+	return visitor.visitDestructuring(d)
+}
+
+// DoWhile runs Body once, then again as long as Condition holds.
+type DoWhile struct {
+	Token
+	Body      Stmt
+	Condition Expr
+}
+
+func (d DoWhile) Accept(visitor StmtVisitor) error {
+	return visitor.visitDoWhile(d)
+}
+
+// EnumStmt declares an enum with the given members, in order.
+type EnumStmt struct {
+	Name    Token
+	Members []Token
+	Slot    *Slot // of the name
+}
+
+func (e EnumStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitEnumStmt(e)
+}
+
+// ForIn runs Body for every element of a list or key of a map, bound to
+// Name in a new scope at every iteration.
+type ForIn struct {
+	Token
+	Name     Token
+	Iterable Expr
+	Body     Stmt
+}
+
+func (f ForIn) Accept(visitor StmtVisitor) error {
+	return visitor.visitForIn(f)
+}
+
 type ForStmt struct {
+	Token
 	Init      Stmt
 	Condition Expr
 	Increment Expr
@@ -81,6 +208,7 @@ func (f ForStmt) Accept(visitor StmtVisitor) error {
 }
 
 type IfStmt struct {
+	Token
 	Condition Expr
 	Then      Stmt
 	Else      Stmt
@@ -90,6 +218,18 @@ func (i IfStmt) Accept(visitor StmtVisitor) error {
 	return visitor.visitIfStmt(i)
 }
 
+// ImportStmt is an import spliced into the program, Stmts are those of the
+// imported file, nil if it was already imported.
+type ImportStmt struct {
+	Token
+	Path  string
+	Stmts []Stmt
+}
+
+func (i ImportStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitImportStmt(i)
+}
+
 type ExprStmt struct {
 	Expr
 }
@@ -102,14 +242,30 @@ type Function struct {
 	Name      Token
 	Closure   *Environment
 	Arguments []Token
+	Defaults  []Expr // default values, nil for the required arguments
+	Variadic  bool   // the last argument collects the extra ones in a list
+	Getter    bool   // a method without arguments invoked on access
 	Body      []Stmt
+	Slot      *Slot // of the name
 }
 
 func (f Function) Accept(visitor StmtVisitor) error {
 	return visitor.visitFunction(f)
 }
 
+// Labeled is a loop with a label, which break and continue statements in
+// the loop can refer to.
+type Labeled struct {
+	Label Token
+	Loop  Stmt
+}
+
+func (l Labeled) Accept(visitor StmtVisitor) error {
+	return visitor.visitLabeled(l)
+}
+
 type PrintStmt struct {
+	Token
 	Expr
 }
 
@@ -118,6 +274,7 @@ func (p PrintStmt) Accept(visitor StmtVisitor) error {
 }
 
 type ReturnStmt struct {
+	Token
 	Expr
 }
 
@@ -125,7 +282,47 @@ func (r ReturnStmt) Accept(visitor StmtVisitor) error {
 	return visitor.visitReturnStmt(r)
 }
 
+type SwitchStmt struct {
+	Token
+	Discriminant Expr
+	Cases        []CaseClause
+	Default      Stmt // nil without a default clause
+}
+
+// CaseClause is a clause of a switch statement, the body runs when the value
+// equals the discriminant.
+type CaseClause struct {
+	Value Expr
+	Body  Block
+}
+
+func (s SwitchStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitSwitchStmt(s)
+}
+
+type ThrowStmt struct {
+	Token
+	Expr
+}
+
+func (t ThrowStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitThrowStmt(t)
+}
+
+// TryStmt runs Body and, if it throws, runs Catch with the thrown value
+// bound to Name.
+type TryStmt struct {
+	Body  Block
+	Name  Token
+	Catch Block
+}
+
+func (t TryStmt) Accept(visitor StmtVisitor) error {
+	return visitor.visitTryStmt(t)
+}
+
 type WhileStmt struct {
+	Token
 	Condition Expr
 	Body      Stmt
 }
@@ -28,10 +28,22 @@ type TokenType int
 
 const (
 	And TokenType = iota
+	Break
+	Case
+	Catch
 	Class
+	Colon
 	Comma
+	Comment
+	Const
+	Continue
+	Default
+	Defer
+	Do
 	Dot
 	Else
+	Ellipsis
+	Enum
 	Eof
 	Equal
 	EqualEqual
@@ -42,49 +54,82 @@ const (
 	GreaterEqual
 	Identifier
 	If
+	Import
+	Is
+	LeftBracket
 	LeftParenthesis
 	LeftSquare
 	Less
 	LessEqual
 	Minus
+	MinusEqual
 	Nil
 	Not
 	NotEqual
 	Number
 	Or
+	Percent
 	Plus
+	PlusEqual
 	Print
+	Question
+	QuestionDot
+	QuestionQuestion
 	Return
+	RightBracket
 	RightParenthesis
 	RightSquare
 	Semicolon
 	Slash
+	SlashEqual
+	SlashSlash
 	Star
+	StarEqual
+	StarStar
 	String
 	Super
+	Switch
 	This
+	Throw
 	True
+	Try
 	Var
 	While
+
+	tokenTypes // number of token types, must be last
 )
 
 var keywords = map[string]TokenType{
-	"and":    And,
-	"class":  Class,
-	"else":   Else,
-	"false":  False,
-	"fun":    Fun,
-	"for":    For,
-	"if":     If,
-	"nil":    Nil,
-	"or":     Or,
-	"print":  Print,
-	"return": Return,
-	"super":  Super,
-	"this":   This,
-	"true":   True,
-	"var":    Var,
-	"while":  While,
+	"and":      And,
+	"break":    Break,
+	"case":     Case,
+	"catch":    Catch,
+	"class":    Class,
+	"const":    Const,
+	"continue": Continue,
+	"default":  Default,
+	"defer":    Defer,
+	"do":       Do,
+	"else":     Else,
+	"enum":     Enum,
+	"false":    False,
+	"fun":      Fun,
+	"for":      For,
+	"if":       If,
+	"import":   Import,
+	"is":       Is,
+	"nil":      Nil,
+	"or":       Or,
+	"print":    Print,
+	"return":   Return,
+	"super":    Super,
+	"switch":   Switch,
+	"this":     This,
+	"throw":    Throw,
+	"true":     True,
+	"try":      Try,
+	"var":      Var,
+	"while":    While,
 }
 
 func (t TokenType) String() string {
@@ -95,22 +140,52 @@ func (t TokenType) String() string {
 		return "RIGHT_PARENTHESIS"
 	case LeftSquare:
 		return "LEFT_SQUARE"
+	case LeftBracket:
+		return "LEFT_BRACKET"
+	case RightBracket:
+		return "RIGHT_BRACKET"
 	case RightSquare:
 		return "RIGHT_SQUARE"
+	case Colon:
+		return "COLON"
 	case Comma:
 		return "COMMA"
+	case Comment:
+		return "COMMENT"
+	case Continue:
+		return "CONTINUE"
 	case Dot:
 		return "DOT"
 	case Minus:
 		return "MINUS"
+	case MinusEqual:
+		return "MINUS_EQUAL"
+	case Percent:
+		return "PERCENT"
 	case Plus:
 		return "PLUS"
+	case PlusEqual:
+		return "PLUS_EQUAL"
+	case Question:
+		return "QUESTION"
+	case QuestionDot:
+		return "QUESTION_DOT"
+	case QuestionQuestion:
+		return "QUESTION_QUESTION"
 	case Semicolon:
 		return "SEMICOLON"
 	case Slash:
 		return "SLASH"
+	case SlashEqual:
+		return "SLASH_EQUAL"
+	case SlashSlash:
+		return "SLASH_SLASH"
 	case Star:
 		return "STAR"
+	case StarEqual:
+		return "STAR_EQUAL"
+	case StarStar:
+		return "STAR_STAR"
 	case Not:
 		return "NOT"
 	case Equal:
@@ -135,14 +210,42 @@ func (t TokenType) String() string {
 		return "IDENTIFIER"
 	case Eof:
 		return "EOF"
+	case Ellipsis:
+		return "ELLIPSIS"
 	case And:
 		return "AND"
+	case Break:
+		return "BREAK"
+	case Case:
+		return "CASE"
+	case Default:
+		return "DEFAULT"
+	case Defer:
+		return "DEFER"
+	case Do:
+		return "DO"
+	case Switch:
+		return "SWITCH"
+	case Try:
+		return "TRY"
+	case Catch:
+		return "CATCH"
+	case Throw:
+		return "THROW"
 	case Class:
 		return "CLASS"
+	case Enum:
+		return "ENUM"
 	case Var:
 		return "VAR"
+	case Const:
+		return "CONST"
 	case If:
 		return "IF"
+	case Import:
+		return "IMPORT"
+	case Is:
+		return "IS"
 	case Else:
 		return "ELSE"
 	case For:
@@ -157,6 +260,16 @@ func (t TokenType) String() string {
 		return "FALSE"
 	case Nil:
 		return "NIL"
+	case Fun:
+		return "FUN"
+	case Or:
+		return "OR"
+	case Return:
+		return "RETURN"
+	case Super:
+		return "SUPER"
+	case This:
+		return "THIS"
 	}
 
 	return "UNKNOWN"
@@ -167,8 +280,38 @@ type Token struct {
 	Lexeme  string
 	Literal string
 	Line    int
+	Column  int
+	File    string // set by a #line directive, empty for the scanned text
 }
 
 func (t Token) String() string {
-	return fmt.Sprintf("%v %v %v %d", t.TokenType, t.Lexeme, t.Literal, t.Line)
+	return fmt.Sprintf("%v %v %v %d:%d", t.TokenType, t.Lexeme, t.Literal, t.Line, t.Column)
+}
+
+// Error is an error located at a token of the source, the parser, the
+// resolver and the interpreter return it as a ParseError or RuntimeError.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+	Token   Token
+}
+
+func (e *Error) Error() string {
+	return located(e.Token.File, e.Line, e.Column, e.Message)
+}
+
+// errorAt returns an error located at the position of the token.
+func errorAt(t Token, format string, a ...interface{}) error {
+	return &Error{t.Line, t.Column, fmt.Sprintf(format, a...), t}
+}
+
+// located formats the message of an error at a position, in file if set
+// by a #line directive.
+func located(file string, line int, column int, message string) string {
+	if file != "" {
+		return fmt.Sprintf("error in %s at line %d, col %d: %s", file, line, column, message)
+	}
+
+	return fmt.Sprintf("error at line %d, col %d: %s", line, column, message)
 }
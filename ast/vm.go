@@ -0,0 +1,402 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import "fmt"
+
+// VM runs the bytecode built by the Compiler on a stack, in the global
+// scope of an interpreter whose natives and settings it shares.
+type VM struct {
+	interpreter *Interpreter
+	stack       []interface{}
+	frames      []callFrame
+}
+
+// callFrame is a running function, base is the slot of the callee on the
+// stack, which its locals follow.
+type callFrame struct {
+	function *CompiledFunction
+	ip       int
+	base     int
+}
+
+// CompiledFunction is a function compiled to bytecode.
+type CompiledFunction struct {
+	Name  string
+	arity int
+	Chunk *Chunk
+}
+
+func NewVM(i *Interpreter) *VM {
+	return &VM{i, nil, nil}
+}
+
+func (f *CompiledFunction) Arity() int {
+	return f.arity
+}
+
+// Call runs the function on a VM of its own, for natives and interpreted
+// code calling back compiled functions.
+func (f *CompiledFunction) Call(i *Interpreter, arguments []Expr) (Literal, error) {
+	vm := NewVM(i)
+	vm.stack = append(vm.stack, f)
+
+	for _, argument := range arguments {
+		l, err := i.Evaluate(argument)
+		if err != nil {
+			return Literal{}, err
+		}
+
+		vm.stack = append(vm.stack, l.Value)
+	}
+
+	value, err := vm.call(f, 0)
+	if err != nil {
+		return Literal{}, err
+	}
+
+	return Literal{value}, nil
+}
+
+func (f *CompiledFunction) String() string {
+	if f.Name == "" {
+		return "<fn>"
+	}
+
+	return "<fn " + f.Name + ">"
+}
+
+// Run runs a chunk compiled from a program.
+func (vm *VM) Run(chunk *Chunk) error {
+	script := &CompiledFunction{"", 0, chunk}
+	vm.stack = append(vm.stack[:0], script)
+
+	_, err := vm.call(script, 0)
+
+	return err
+}
+
+func (vm *VM) push(value interface{}) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() interface{} {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+
+	return value
+}
+
+func (vm *VM) peek() interface{} {
+	return vm.stack[len(vm.stack)-1]
+}
+
+// call runs f, whose callee slot is at base, up to its return.
+func (vm *VM) call(f *CompiledFunction, base int) (interface{}, error) {
+	i := vm.interpreter
+	depth, frames := len(vm.frames), len(i.frames)
+
+	vm.frames = append(vm.frames, callFrame{f, 0, base})
+
+	value, err := vm.run(depth)
+	if err != nil {
+		// the innermost frame attaches the trace, like the interpreter
+		if e, ok := err.(*Error); ok {
+			err = i.runtimeError(e)
+		}
+
+		vm.frames = vm.frames[:depth]
+		i.frames = i.frames[:frames]
+
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// run executes instructions until the frame at depth returns.
+func (vm *VM) run(depth int) (interface{}, error) {
+	i := vm.interpreter
+	globals := i.globals()
+
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := frame.function.Chunk
+
+	for {
+		offset := frame.ip
+		op := OpCode(chunk.Code[offset])
+		frame.ip++
+
+		switch op {
+		case OpConstant:
+			{
+				vm.push(chunk.Constants[chunk.operand(frame.ip)])
+				frame.ip += 2
+			}
+		case OpNil:
+			{
+				vm.push(nil)
+			}
+		case OpTrue:
+			{
+				vm.push(true)
+			}
+		case OpFalse:
+			{
+				vm.push(false)
+			}
+		case OpPop:
+			{
+				vm.pop()
+			}
+		case OpDefineGlobal:
+			{
+				// globals are looked up by the name of their token
+				frame.ip += 2
+
+				if err := globals.Declare(Variable{chunk.Tokens[offset], nil}, Literal{vm.pop()}); err != nil {
+					return nil, err
+				}
+			}
+		case OpGetGlobal:
+			{
+				frame.ip += 2
+
+				e, err := globals.Get(Variable{chunk.Tokens[offset], nil})
+				if err != nil {
+					return nil, err
+				}
+
+				var value interface{}
+				if l, ok := e.(Literal); ok {
+					value = l.Value
+				}
+
+				vm.push(value)
+			}
+		case OpSetGlobal:
+			{
+				frame.ip += 2
+
+				if err := globals.Assign(Variable{chunk.Tokens[offset], nil}, Literal{vm.peek()}); err != nil {
+					return nil, err
+				}
+			}
+		case OpGetLocal:
+			{
+				vm.push(vm.stack[frame.base+chunk.operand(frame.ip)])
+				frame.ip += 2
+			}
+		case OpSetLocal:
+			{
+				vm.stack[frame.base+chunk.operand(frame.ip)] = vm.peek()
+				frame.ip += 2
+			}
+		case OpAdd, OpSubtract, OpMultiply, OpLess, OpLessEqual, OpGreater, OpGreaterEqual:
+			{
+				right := vm.pop()
+				left := vm.pop()
+
+				// numbers take a fast path, anything else the interpreter's
+				if l, ok := left.(float64); ok {
+					if r, ok := right.(float64); ok {
+						vm.push(arithmetic(op, l, r))
+						continue
+					}
+				}
+
+				if err := i.binary(chunk.Tokens[offset], Literal{left}, Literal{right}); err != nil {
+					return nil, err
+				}
+
+				vm.push(i.Literal.Value)
+			}
+		case OpDivide, OpFloorDivide, OpModulo, OpPower, OpEqual, OpNotEqual, OpIs:
+			{
+				right := vm.pop()
+				left := vm.pop()
+
+				if err := i.binary(chunk.Tokens[offset], Literal{left}, Literal{right}); err != nil {
+					return nil, err
+				}
+
+				vm.push(i.Literal.Value)
+			}
+		case OpNegate:
+			{
+				f, ok := vm.peek().(float64)
+				if !ok {
+					t := chunk.Tokens[offset]
+					return nil, errorAt(t, "bad operand for unary %s: %T", t.Lexeme, vm.peek())
+				}
+
+				vm.stack[len(vm.stack)-1] = -f
+			}
+		case OpNot:
+			{
+				vm.stack[len(vm.stack)-1] = !truthy(vm.peek())
+			}
+		case OpJump:
+			{
+				frame.ip = chunk.operand(frame.ip)
+			}
+		case OpJumpIfFalse:
+			{
+				if truthy(vm.peek()) {
+					frame.ip += 2
+				} else {
+					frame.ip = chunk.operand(frame.ip)
+				}
+			}
+		case OpJumpIfTrue:
+			{
+				if truthy(vm.peek()) {
+					frame.ip = chunk.operand(frame.ip)
+				} else {
+					frame.ip += 2
+				}
+			}
+		case OpJumpIfNotNil:
+			{
+				if vm.peek() != nil {
+					frame.ip = chunk.operand(frame.ip)
+				} else {
+					frame.ip += 2
+				}
+			}
+		case OpCall:
+			{
+				n := int(chunk.Code[frame.ip])
+				frame.ip++
+
+				paren := chunk.Tokens[offset]
+				base := len(vm.stack) - n - 1
+
+				f, ok := vm.stack[base].(*CompiledFunction)
+				if !ok {
+					// natives, classes and interpreted functions
+					arguments := make([]Expr, n)
+					for j := range arguments {
+						arguments[j] = Literal{vm.stack[base+1+j]}
+					}
+
+					l, err := i.call(paren, Literal{vm.stack[base]}, arguments)
+					if err != nil {
+						return nil, err
+					}
+
+					vm.stack = vm.stack[:base]
+					vm.push(l.Value)
+
+					continue
+				}
+
+				if f.arity != n {
+					return nil, errorAt(paren, "expected %d arguments but got %d", f.arity, n)
+				}
+
+				if len(i.frames) >= i.maxDepth() {
+					return nil, errorAt(paren, "stack overflow")
+				}
+
+				i.frames = append(i.frames, Frame{f.String(), paren.Line})
+
+				vm.frames = append(vm.frames, callFrame{f, 0, base})
+				frame = &vm.frames[len(vm.frames)-1]
+				chunk = f.Chunk
+			}
+		case OpReturn:
+			{
+				value := vm.pop()
+				vm.stack = vm.stack[:frame.base]
+				vm.frames = vm.frames[:len(vm.frames)-1]
+
+				if len(vm.frames) == depth {
+					return value, nil
+				}
+
+				i.frames = i.frames[:len(i.frames)-1]
+				vm.push(value)
+
+				frame = &vm.frames[len(vm.frames)-1]
+				chunk = frame.function.Chunk
+			}
+		case OpPrint:
+			{
+				s, err := i.stringify(chunk.Tokens[offset], Literal{vm.pop()})
+				if err != nil {
+					return nil, err
+				}
+
+				fmt.Fprintln(i.output(), s)
+			}
+		case OpEcho:
+			{
+				if value := vm.pop(); value != nil {
+					s, err := i.repr(chunk.Tokens[offset], value)
+					if err != nil {
+						return nil, err
+					}
+
+					fmt.Fprintln(i.output(), s)
+				}
+			}
+		default:
+			{
+				return nil, fmt.Errorf("unknown opcode %d", op)
+			}
+		}
+	}
+}
+
+// arithmetic applies the operator of op to two numbers.
+func arithmetic(op OpCode, l float64, r float64) interface{} {
+	switch op {
+	case OpAdd:
+		{
+			return l + r
+		}
+	case OpSubtract:
+		{
+			return l - r
+		}
+	case OpMultiply:
+		{
+			return l * r
+		}
+	case OpLess:
+		{
+			return l < r
+		}
+	case OpLessEqual:
+		{
+			return l <= r
+		}
+	case OpGreater:
+		{
+			return l > r
+		}
+	}
+
+	return l >= r
+}
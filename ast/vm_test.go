@@ -0,0 +1,248 @@
+//  MIT License
+//
+//  Copyright (c) 2019 Marco Pacini
+//
+//  Permission is hereby granted, free of charge, to any person obtaining a copy
+//  of this software and associated documentation files (the "Software"), to deal
+//  in the Software without restriction, including without limitation the rights
+//  to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+//  copies of the Software, and to permit persons to whom the Software is
+//  furnished to do so, subject to the following conditions:
+//
+//  The above copyright notice and this permission notice shall be included in all
+//  copies or substantial portions of the Software.
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+//  IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+//  FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+//  AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+//  LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+//  SOFTWARE.
+
+package ast
+
+import (
+	"bytes"
+	"testing"
+)
+
+// compile scans, parses and compiles a program.
+func compile(source string) (*Chunk, error) {
+	stmts, err := parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := Compiler{}
+
+	return compiler.Compile(stmts)
+}
+
+// outputs runs a program with the standard library, tree-walked or on
+// the VM, and returns what it printed and the error it failed with.
+func outputs(source string, bytecode bool) (string, string) {
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+	i.Bytecode = bytecode
+
+	if err := exec(i, source); err != nil {
+		return b.String(), err.Error()
+	}
+
+	return b.String(), ""
+}
+
+func TestVM_Output(t *testing.T) {
+	table := []string{
+		"print 1 + 2 * 3 - 4 / 2;",
+		"print 7 // 2; print -7 % 3; print 2 ** 10;",
+		`print "con" + "cat"; print "a" < "b"; print 1 == 1; print nil != false;`,
+		`print "ab" * 3; var s = "-"; s *= 2; print s;`,
+		"print !nil; print -(1 + 2); print 1 < 2 ? \"yes\" : \"no\";",
+		"print nil or 2; print 0 and 3; print false and 1; print 1 or x;",
+		`print !0; print !""; print "" ? 1 : 2; if (0) print "zero"; var n = 0; while (n < 1 and "") n = n + 1; print n;`,
+		"print nil ?? 2; print false ?? 1; print 0 ?? x; print nil ?? nil ?? 3;",
+		"var a = 1; a = a + 1; a += 3; print a;",
+		"var a = 1; { var a = 2; { var b = a + 1; print b; } print a; } print a;",
+		"var n = 0; while (n < 5) n = n + 1; print n;",
+		"var n = 10; do n = n + 1; while (n < 5); print n;",
+		"var i = 0; do { i = i + 1; if (i == 2) continue; if (i == 4) break; print i; } while (i < 10); print i;",
+		"{ var i = 0; do { var j = i; i = j + 1; if (i > 2) break; } while (true); print i; }",
+		"for (var i = 0; i < 10; i = i + 1) { if (i == 2) continue; if (i == 5) break; print i; }",
+		"{ var total = 0; for (var i = 0; i < 3; i = i + 1) { var j = i * 2; total = total + j; } print total; }",
+		"var i = 0; while (true) { { var x = i; if (x > 2) break; } i = i + 1; } print i;",
+		"fun add(a, b) { return a + b; } print add(1, 2); print add;",
+		"fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(20);",
+		"fun f() { var a = 1; { var b = 2; return a + b; } } print f(); print f;",
+		"fun nothing() {} print nothing();",
+		"fun early(x) { if (x) return; return 1; } print early(true); print early(false);",
+		"fun count(n) { for (var i = 0; i < n; i = i + 1) { if (i == 1) return i; } return -1; } print count(3);",
+		`print len("four"); print sqrt(16); print type(len);`,
+		"fun double(x) { return x * 2; } print type(double);",
+		"print 1 / 0;",
+		`print -"a";`,
+		`print 1 + "a";`,
+		"print undefined;",
+		"undefined = 1;",
+		"fun f(a) { return a; } f(1, 2);",
+		"fun f() { return 1 / 0; } fun g() { return f(); } g();",
+		"fun f() { return f(); } f();",
+		"print 1(2);",
+		`print sqrt("a");`,
+	}
+
+	for _, test := range table {
+		if _, err := compile(test); err != nil {
+			t.Errorf("%s: %v", test, err)
+			continue
+		}
+
+		out, err := outputs(test, false)
+		vmOut, vmErr := outputs(test, true)
+
+		if vmOut != out || vmErr != err {
+			t.Errorf("%s: expected %q %q, got %q %q", test, out, err, vmOut, vmErr)
+		}
+	}
+}
+
+func TestVM_Trace(t *testing.T) {
+	program := "fun f() { return 1 / 0; }\nfun g() {\n return f(); }\ng();"
+
+	interpreted := NewInterpreter()
+	compiled := NewInterpreter()
+	compiled.Bytecode = true
+
+	for _, i := range []*Interpreter{interpreted, compiled} {
+		i.SetOutput(&bytes.Buffer{})
+	}
+
+	expected, ok := exec(interpreted, program).(*RuntimeError)
+	if !ok {
+		t.Fatal("expected a runtime error")
+	}
+
+	actual, ok := exec(compiled, program).(*RuntimeError)
+	if !ok {
+		t.Fatal("expected a runtime error")
+	}
+
+	if actual.Stack() != expected.Stack() {
+		t.Errorf("expected %q, got %q", expected.Stack(), actual.Stack())
+	}
+
+	// the VM leaves no frame behind
+	if len(compiled.frames) != 0 {
+		t.Errorf("expected no frames, got %v", compiled.frames)
+	}
+}
+
+func TestVM_Callback(t *testing.T) {
+	i := NewInterpreter()
+	i.Bytecode = true
+
+	if err := exec(i, "fun double(x) { return x * 2; }"); err != nil {
+		t.Fatal(err)
+	}
+
+	double, ok := global(i, "double").(*CompiledFunction)
+	if !ok {
+		t.Fatalf("expected a compiled function, got %T", global(i, "double"))
+	}
+
+	// natives call compiled functions back
+	value, err := i.callback(double, 21.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != 42.0 {
+		t.Errorf("expected 42, got %v", value)
+	}
+}
+
+func TestVM_Unsupported(t *testing.T) {
+	table := []string{
+		"class A {}",
+		"print [1, 2];",
+		"var f = fun () { return 1; };",
+		"{ var a = 1; fun f() { return a; } }",
+		"{ fun f(n) { if (n < 1) return 0; return f(n - 1) + 1; } }",
+		"fun f(a, b = 1) { return a; }",
+		"if (true) { throw 1; }",
+	}
+
+	for _, test := range table {
+		if _, err := compile(test); err == nil {
+			t.Errorf("%s: expected an error", test)
+		}
+	}
+
+	// such programs are tree-walked
+	out, err := outputs("class A { get() { return 1; } } print A().get();", true)
+	if out != "1\n" || err != "" {
+		t.Errorf("expected 1, got %q %q", out, err)
+	}
+}
+
+func TestVM_LocalFunctions(t *testing.T) {
+	table := []string{
+		"{ fun f(n) { if (n < 1) return 0; return f(n - 1) + 1; } print f(3); }",
+		"fun fib(n) { return 100; } { fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); } print fib(10); }",
+		"{ fun f() { return 1; } fun g() { return f() + 1; } print g(); }",
+	}
+
+	for _, test := range table {
+		out, err := outputs(test, false)
+		vmOut, vmErr := outputs(test, true)
+
+		if vmOut != out || vmErr != err {
+			t.Errorf("%s: expected %q %q, got %q %q", test, out, err, vmOut, vmErr)
+		}
+	}
+}
+
+func TestVM_Echo(t *testing.T) {
+	var b bytes.Buffer
+
+	i := NewInterpreter()
+	i.SetOutput(&b)
+	i.ReplMode = true
+	i.Bytecode = true
+
+	if err := exec(i, `var a = 1; a = 2; a; "s"; nil;`); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != "2\n\"s\"\n" {
+		t.Errorf("expected 2 and \"s\", got %q", b.String())
+	}
+}
+
+func BenchmarkVM_Fib(b *testing.B) {
+	program := `fun fib(n) {
+		if (n < 2) return n;
+		return fib(n - 1) + fib(n - 2);
+	}
+	{
+		var total = 0;
+		for (var i = 0; i < 10; i = i + 1) total = total + fib(15);
+	}`
+
+	stmts, err := parse(program)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		i := &Interpreter{Bytecode: true}
+		if err := i.Run(stmts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
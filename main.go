@@ -7,52 +7,120 @@ import (
 	"github.com/marcopacini/go-lox/ast"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
+// newlines ends statements at line breaks, see ast.Scanner.ScanLines
+var newlines = flag.Bool("newlines", false, "end statements at line breaks, semicolons can be left out")
+
 func main() {
+	format := flag.Bool("fmt", false, "print the script formatted instead of running it")
+	dump := flag.Bool("dump-ast", false, "print the syntax tree of the script instead of running it")
 	flag.Parse()
 
-	if len(flag.Args()) > 1 {
-		println("usage: lox [script]")
+	if (*format || *dump) && len(flag.Args()) != 1 {
+		println("usage: lox [-fmt] [-dump-ast] [-newlines] [script [args...]]")
 		os.Exit(64)
 	}
 
-	if len(flag.Args()) == 1 {
-		runFile(flag.Arg(0))
+	if *format {
+		formatFile(flag.Arg(0))
+	} else if *dump {
+		dumpFile(flag.Arg(0))
+	} else if len(flag.Args()) > 0 {
+		runFile(flag.Arg(0), flag.Args()[1:])
 	} else {
 		runPrompt()
 	}
 }
 
-func runFile(path string) {
+// runFile runs the script at path, args are the arguments following it.
+func runFile(path string, args []string) {
 	b, err := ioutil.ReadFile(path)
 	if err != nil {
 		panic(err)
 	}
 
-	if err := run(string(b)); err != nil {
-		fmt.Println(err)
+	i := ast.NewInterpreter()
+	i.Install(ast.RegexpLib)
+	i.Files = ast.OSFileSystem{}
+	i.Args = args
+	i.Env = environ()
+
+	if err := run(i, path, string(b)); err != nil {
+		i.Report(err)
+	}
+}
+
+func formatFile(path string) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	source, err := ast.Format(string(b))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(65)
 	}
+
+	fmt.Print(source)
+}
+
+func dumpFile(path string) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	tokens, err := scan(string(b))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(65)
+	}
+
+	p := ast.Parser{Tokens: tokens, Path: path, Lines: *newlines}
+
+	stmts, err := p.Parse()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(65)
+	}
+
+	fmt.Print(ast.Dump(stmts))
 }
 
 func runPrompt() {
 	reader := bufio.NewReader(os.Stdin)
+	i := ast.NewInterpreter()
+	i.ReplMode = true
+	i.Install(ast.RegexpLib)
+	i.Files = ast.OSFileSystem{}
+	i.Env = environ()
+
+	// programs read from the same input as the prompt
+	i.SetInput(reader)
 
 	for {
 		fmt.Print("> ")
 
-		if b, err := reader.ReadString('\n'); err == nil {
-			if err := run(string(b)); err != nil {
-				fmt.Println(err)
-			}
+		b, err := reader.ReadString('\n')
+		if err != nil {
+			// end of input
+			fmt.Println()
+			return
+		}
+
+		if err := run(i, "", b); err != nil {
+			i.Report(err)
 		}
 	}
 }
 
-func run(source string) error {
-	s := ast.Scanner{source}
-
-	tokens, err := s.Scan()
+// run runs the source of the file at path, imports are relative to the
+// working directory if path is empty.
+func run(i *ast.Interpreter, path string, source string) error {
+	tokens, err := scan(source)
 	if err != nil {
 		return err
 	}
@@ -61,15 +129,13 @@ func run(source string) error {
 	//		fmt.Println(token)
 	//	}
 
-	p := ast.Parser{Tokens: tokens}
+	p := ast.Parser{Tokens: tokens, Path: path, Lines: *newlines}
 
 	stmts, err := p.Parse()
 	if err != nil {
 		return err
 	}
 
-	i := ast.Interpreter{}
-
 	if err = i.Run(stmts); err != nil {
 		return err
 	}
@@ -88,3 +154,26 @@ func run(source string) error {
 
 	return nil
 }
+
+// environ returns the environment variables of the process by name.
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if j := strings.IndexByte(kv, '='); j >= 0 {
+			env[kv[:j]] = kv[j+1:]
+		}
+	}
+
+	return env
+}
+
+// scan scans source, inserting semicolons at line breaks with -newlines.
+func scan(source string) ([]ast.Token, error) {
+	s := ast.Scanner{Text: source}
+
+	if *newlines {
+		return s.ScanLines()
+	}
+
+	return s.Scan()
+}